@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/auth"
+	"github.com/imeltsner/gator-api/internal/database"
+)
+
+// Scopes an API key can be granted. Keys without any scopes listed when created
+// get all of them. Account management, including managing keys, needs a JWT.
+const (
+	scopeReadPosts     = "posts:read"
+	scopeManageFollows = "follows:manage"
+	scopeManageFeeds   = "feeds:manage"
+)
+
+var apiKeyScopes = []string{scopeReadPosts, scopeManageFollows, scopeManageFeeds}
+
+const (
+	maxAPIKeysPerUser   = 25
+	maxAPIKeyNameLength = 50
+	// last_used_at is only written once per interval so busy keys don't write on
+	// every request
+	apiKeyUsedInterval = time.Minute
+)
+
+var errInvalidAPIKey = errors.New("invalid or expired api key")
+
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func newAPIKey(key database.ApiKey) APIKey {
+	apiKey := APIKey{
+		ID:        key.ID,
+		CreatedAt: key.CreatedAt,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    strings.Fields(key.Scopes),
+	}
+	if key.ExpiresAt.Valid {
+		apiKey.ExpiresAt = &key.ExpiresAt.Time
+	}
+	if key.LastUsedAt.Valid {
+		apiKey.LastUsedAt = &key.LastUsedAt.Time
+	}
+	return apiKey
+}
+
+// authenticate returns the user the request's bearer token belongs to. The token
+// is either a JWT from login or an API key, which is only accepted when it was
+// granted scope. Routes that pass an empty scope accept JWTs only.
+func (s *state) authenticate(r *http.Request, scope string) (uuid.UUID, error) {
+	c := s.requestCaller(r)
+	if c.err != nil {
+		return uuid.Nil, c.err
+	}
+	key := c.apiKey
+	if key == nil {
+		return c.userID, nil
+	}
+	if scope == "" {
+		return uuid.Nil, &requestError{status: http.StatusForbidden, message: "api keys can't be used here, log in instead"}
+	}
+	if !slices.Contains(strings.Fields(key.Scopes), scope) {
+		return uuid.Nil, &requestError{status: http.StatusForbidden, message: fmt.Sprintf("api key lacks the %v scope", scope)}
+	}
+
+	now := time.Now().UTC()
+	if !key.LastUsedAt.Valid || now.Sub(key.LastUsedAt.Time) > apiKeyUsedInterval {
+		err := s.db.MarkAPIKeyUsed(r.Context(), database.MarkAPIKeyUsedParams{
+			ID:         key.ID,
+			LastUsedAt: sql.NullTime{Time: now, Valid: true},
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "unable to record api key use", slog.String("api_key_id", key.ID.String()), slog.Any("error", err))
+		}
+	}
+	return key.UserID, nil
+}
+
+// lookupAPIKey returns errInvalidAPIKey for unknown and expired keys
+func (s *state) lookupAPIKey(ctx context.Context, token string) (database.ApiKey, error) {
+	key, err := s.db.GetAPIKeyByHash(ctx, auth.HashAPIKey(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.ApiKey{}, errInvalidAPIKey
+	} else if err != nil {
+		return database.ApiKey{}, err
+	}
+	if key.ExpiresAt.Valid && !time.Now().Before(key.ExpiresAt.Time) {
+		return database.ApiKey{}, errInvalidAPIKey
+	}
+	return key, nil
+}
+
+func (s *state) handlerCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	userID, err := s.authenticate(r, "")
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	params := parameters{}
+	err = decodeJSON(w, r, &params)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	v := validator{}
+	if v.required("name", params.Name) {
+		v.maxLength("name", params.Name, maxAPIKeyNameLength)
+	}
+	for _, scope := range params.Scopes {
+		if !slices.Contains(apiKeyScopes, scope) {
+			v.add("scopes", fmt.Sprintf("unknown scope %q, must be one of %v", scope, strings.Join(apiKeyScopes, ", ")))
+		}
+	}
+	if params.ExpiresAt != nil && !params.ExpiresAt.After(time.Now()) {
+		v.add("expires_at", "must be in the future")
+	}
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	keys, err := s.db.GetAPIKeysForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get api keys", err)
+		return
+	}
+	if len(keys) >= maxAPIKeysPerUser {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("a user can have at most %d api keys", maxAPIKeysPerUser), nil)
+		return
+	}
+
+	scopes := apiKeyScopes
+	if len(params.Scopes) > 0 {
+		scopes = nil
+		for _, scope := range apiKeyScopes {
+			if slices.Contains(params.Scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	var expiresAt sql.NullTime
+	if params.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: params.ExpiresAt.UTC(), Valid: true}
+	}
+
+	secret, prefix, err := auth.MakeAPIKey()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to make api key", err)
+		return
+	}
+	key, err := s.db.CreateAPIKey(r.Context(), database.CreateAPIKeyParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		UserID:    userID,
+		Name:      params.Name,
+		Prefix:    prefix,
+		HashedKey: auth.HashAPIKey(secret),
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: expiresAt,
+	})
+	if database.IsUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "an api key with this name already exists", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to create api key", err)
+		return
+	}
+
+	type response struct {
+		APIKey
+		Key string `json:"key"`
+	}
+
+	slog.InfoContext(r.Context(), "api key created", slog.String("user_id", userID.String()), slog.String("api_key_id", key.ID.String()))
+	respondWithJSON(w, http.StatusCreated, response{
+		APIKey: newAPIKey(key),
+		Key:    secret,
+	})
+}
+
+func (s *state) handlerGetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.authenticate(r, "")
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	keys, err := s.db.GetAPIKeysForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get api keys", err)
+		return
+	}
+
+	type response struct {
+		APIKeys []APIKey `json:"api_keys"`
+	}
+
+	apiKeys := make([]APIKey, len(keys))
+	for i, key := range keys {
+		apiKeys[i] = newAPIKey(key)
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		APIKeys: apiKeys,
+	})
+}
+
+func (s *state) handlerDeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	userID, err := s.authenticate(r, "")
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	deleted, err := s.db.DeleteAPIKey(r.Context(), database.DeleteAPIKeyParams{ID: id, UserID: userID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to delete api key", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "api key not found", nil)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "api key revoked", slog.String("user_id", userID.String()), slog.String("api_key_id", id.String()))
+	w.WriteHeader(http.StatusNoContent)
+}
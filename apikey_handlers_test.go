@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/auth"
+	"github.com/imeltsner/gator-api/internal/database"
+)
+
+func TestAPIKeys(t *testing.T) {
+	s, api := newTestAPI(t)
+
+	user := api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusCreated)
+	api.do(http.MethodPost, "/api/api-keys", map[string]any{"name": "cron"}, http.StatusUnauthorized)
+	login := api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusOK)
+	jwt := login["token"].(string)
+	api.token = jwt
+
+	res := api.do(http.MethodPost, "/api/api-keys", map[string]any{"name": "", "scopes": []string{"admin"}, "expires_at": time.Now().Add(-time.Hour)}, http.StatusBadRequest)
+	if details := requireErrorCode(t, res, codeValidation)["details"].([]any); len(details) != 3 {
+		t.Errorf("invalid key details: got %v, want 3", details)
+	}
+
+	full := api.do(http.MethodPost, "/api/api-keys", map[string]any{"name": "everything"}, http.StatusCreated)
+	requireKeys(t, "api key", full, "id", "created_at", "name", "prefix", "scopes", "expires_at", "last_used_at", "key")
+	fullKey := full["key"].(string)
+	if !strings.HasPrefix(fullKey, "gk_") || !strings.HasPrefix(fullKey, full["prefix"].(string)) {
+		t.Errorf("key %q: want gk_ and the prefix %q", fullKey, full["prefix"])
+	}
+	if len(full["scopes"].([]any)) != len(apiKeyScopes) {
+		t.Errorf("default scopes: got %v, want all of them", full["scopes"])
+	}
+	api.do(http.MethodPost, "/api/api-keys", map[string]any{"name": "everything"}, http.StatusConflict)
+
+	reader := api.do(http.MethodPost, "/api/api-keys", map[string]any{"name": "reader", "scopes": []string{scopeReadPosts}, "expires_at": time.Now().Add(time.Hour)}, http.StatusCreated)
+	readerKey := reader["key"].(string)
+
+	// Keys work on routes within their scopes
+	api.token = readerKey
+	api.do(http.MethodGet, "/api/posts", nil, http.StatusOK)
+	api.do(http.MethodPost, "/api/follows", map[string]any{"url": "https://example.com/rss"}, http.StatusForbidden)
+	api.token = fullKey
+	api.do(http.MethodGet, "/api/follows", nil, http.StatusOK)
+
+	// Account management needs a JWT
+	api.do(http.MethodGet, "/api/api-keys", nil, http.StatusForbidden)
+	api.do(http.MethodDelete, "/api/users/"+user["id"].(string), nil, http.StatusForbidden)
+	api.token = "gk_unknown"
+	api.do(http.MethodGet, "/api/posts", nil, http.StatusUnauthorized)
+
+	api.token = jwt
+	list := api.do(http.MethodGet, "/api/api-keys", nil, http.StatusOK)
+	keys := list["api_keys"].([]any)
+	var names []string
+	for _, k := range keys {
+		key := k.(map[string]any)
+		if _, ok := key["key"]; ok {
+			t.Errorf("listed key %v includes the secret", key["name"])
+		}
+		if key["last_used_at"] == nil {
+			t.Errorf("listed key %v: last_used_at is null after use", key["name"])
+		}
+		names = append(names, key["name"].(string))
+	}
+	if !slices.Equal(names, []string{"everything", "reader"}) {
+		t.Errorf("listed keys: got %q", names)
+	}
+
+	api.do(http.MethodDelete, "/api/api-keys/"+reader["id"].(string), nil, http.StatusNoContent)
+	api.do(http.MethodDelete, "/api/api-keys/"+reader["id"].(string), nil, http.StatusNotFound)
+	api.token = readerKey
+	api.do(http.MethodGet, "/api/posts", nil, http.StatusUnauthorized)
+
+	// Expired keys are rejected
+	expired, _, _ := auth.MakeAPIKey()
+	_, err := s.db.CreateAPIKey(context.Background(), database.CreateAPIKeyParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		UserID:    uuid.MustParse(user["id"].(string)),
+		Name:      "expired",
+		HashedKey: auth.HashAPIKey(expired),
+		Scopes:    scopeReadPosts,
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("create expired key: %v", err)
+	}
+	api.token = expired
+	api.do(http.MethodGet, "/api/posts", nil, http.StatusUnauthorized)
+}
+
+// apiKeyLookups counts how often API keys are looked up
+type apiKeyLookups struct {
+	database.Querier
+	count int
+}
+
+func (q *apiKeyLookups) GetAPIKeyByHash(ctx context.Context, hashedKey string) (database.ApiKey, error) {
+	q.count++
+	return q.Querier.GetAPIKeyByHash(ctx, hashedKey)
+}
+
+func TestAPIKeyLookups(t *testing.T) {
+	s, api := newTestAPI(t)
+	s.rateLimiters = newRateLimiters(rateLimit{requests: 100, window: time.Minute}, false)
+
+	api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusCreated)
+	api.token = api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusOK)["token"].(string)
+	api.token = api.do(http.MethodPost, "/api/api-keys", map[string]any{"name": "cron"}, http.StatusCreated)["key"].(string)
+
+	lookups := &apiKeyLookups{Querier: s.db}
+	s.db = lookups
+	api.do(http.MethodGet, "/api/follows", nil, http.StatusOK)
+	if lookups.count != 1 {
+		t.Errorf("api key lookups for one request: got %d, want 1", lookups.count)
+	}
+
+	// Routes that don't authenticate never look keys up, and made up keys are
+	// rate limited before they are
+	api.token = "gk_made_up"
+	lookups.count = 0
+	api.do(http.MethodGet, "/api/feeds", nil, http.StatusOK)
+	if lookups.count != 0 {
+		t.Errorf("api key lookups on a public route: got %d, want 0", lookups.count)
+	}
+	s.rateLimiters = newRateLimiters(rateLimit{requests: 2, window: time.Minute}, false)
+	api.do(http.MethodGet, "/api/follows", nil, http.StatusUnauthorized)
+	api.do(http.MethodGet, "/api/follows", nil, http.StatusUnauthorized)
+	api.do(http.MethodGet, "/api/follows", nil, http.StatusTooManyRequests)
+	if lookups.count != 2 {
+		t.Errorf("api key lookups past the rate limit: got %d, want 2", lookups.count)
+	}
+}
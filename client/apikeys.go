@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes an API key can be granted
+const (
+	ScopeReadPosts     = "posts:read"
+	ScopeManageFollows = "follows:manage"
+	ScopeManageFeeds   = "feeds:manage"
+)
+
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// CreatedAPIKey holds the key itself, which the server only returns once
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey creates a key for use with WithToken. No scopes grants all of
+// them, and a zero expiresAt gives a key that doesn't expire. Managing keys
+// needs a token from Login.
+func (c *Client) CreateAPIKey(ctx context.Context, name string, scopes []string, expiresAt time.Time) (CreatedAPIKey, error) {
+	req := struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes,omitempty"`
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	}{Name: name, Scopes: scopes}
+	if !expiresAt.IsZero() {
+		req.ExpiresAt = &expiresAt
+	}
+
+	var key CreatedAPIKey
+	err := c.do(ctx, http.MethodPost, "/api/api-keys", req, &key)
+	return key, err
+}
+
+func (c *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var res struct {
+		APIKeys []APIKey `json:"api_keys"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/api-keys", nil, &res)
+	return res.APIKeys, err
+}
+
+// DeleteAPIKey revokes a key
+func (c *Client) DeleteAPIKey(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, http.MethodDelete, "/api/api-keys/"+id.String(), nil, nil)
+}
@@ -0,0 +1,239 @@
+// Package client is a Go client for the gator API.
+//
+//	c := client.New("http://localhost:8080", client.WithCredentials("alice", "hunter22"))
+//	for post, err := range c.Posts(ctx, 50) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// RefreshFunc returns a new access token, e.g. by logging in again. It is called
+// when the API rejects the current token.
+type RefreshFunc func(ctx context.Context, c *Client) (string, error)
+
+// Client calls the gator API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	refresh    RefreshFunc
+	onToken    func(token string)
+
+	mu    sync.Mutex
+	token string
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the http.Client used for requests, http.DefaultClient by default
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithToken sets the access token sent with requests, a JWT from Login or an
+// API key
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRefresh sets the function used to get a new token when a request is
+// rejected with a 401. The request is retried once with the new token.
+func WithRefresh(refresh RefreshFunc) Option {
+	return func(c *Client) {
+		c.refresh = refresh
+	}
+}
+
+// WithCredentials logs in with name and password whenever a token is needed
+func WithCredentials(name, password string) Option {
+	return WithRefresh(func(ctx context.Context, c *Client) (string, error) {
+		res, err := c.Login(ctx, name, password, 0)
+		if err != nil {
+			return "", err
+		}
+		return res.Token, nil
+	})
+}
+
+// WithTokenHook calls fn whenever the client's token changes, e.g. to persist it
+func WithTokenHook(fn func(token string)) Option {
+	return func(c *Client) {
+		c.onToken = fn
+	}
+}
+
+// New returns a client for the API at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Token returns the current access token
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// SetToken replaces the access token
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	changed := c.token != token
+	c.token = token
+	c.mu.Unlock()
+
+	if changed && c.onToken != nil {
+		c.onToken(token)
+	}
+}
+
+// FieldError describes an invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is returned for any response with an error status. Code is machine
+// readable, e.g. validation_failed or not_found.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    []FieldError
+	RequestID  string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("gator: %d %v: %v", e.StatusCode, e.Code, e.Message)
+	for _, detail := range e.Details {
+		msg += fmt.Sprintf("; %v %v", detail.Field, detail.Message)
+	}
+	return msg
+}
+
+// IsStatus reports whether err is an *Error with the given status code
+func IsStatus(err error, status int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+type errorResponse struct {
+	Error struct {
+		Code    string       `json:"code"`
+		Message string       `json:"message"`
+		Details []FieldError `json:"details"`
+	} `json:"error"`
+	RequestID string `json:"request_id"`
+}
+
+func decodeError(res *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	apiErr := &Error{
+		StatusCode: res.StatusCode,
+		RequestID:  res.Header.Get("X-Request-ID"),
+	}
+
+	body := errorResponse{}
+	if err := json.Unmarshal(data, &body); err == nil && body.Error.Code != "" {
+		apiErr.Code = body.Error.Code
+		apiErr.Message = body.Error.Message
+		apiErr.Details = body.Error.Details
+		if body.RequestID != "" {
+			apiErr.RequestID = body.RequestID
+		}
+		return apiErr
+	}
+
+	apiErr.Code = strings.ReplaceAll(strings.ToLower(http.StatusText(res.StatusCode)), " ", "_")
+	apiErr.Message = strings.TrimSpace(string(data))
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(res.StatusCode)
+	}
+	return apiErr
+}
+
+// do sends a request with an optional JSON body and decodes a JSON response into
+// out, refreshing the token and retrying once if it has been rejected
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("unable to encode request: %w", err)
+		}
+	}
+
+	token := c.Token()
+	res, err := c.send(ctx, method, path, body, token)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode == http.StatusUnauthorized && c.refresh != nil && !isLogin(path) {
+		res.Body.Close()
+		token, err = c.refresh(ctx, c)
+		if err != nil {
+			return fmt.Errorf("unable to refresh token: %w", err)
+		}
+		c.SetToken(token)
+		res, err = c.send(ctx, method, path, body, token)
+		if err != nil {
+			return err
+		}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return decodeError(res)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("unable to decode response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) send(ctx context.Context, method, path string, body []byte, token string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.httpClient.Do(req)
+}
+
+func isLogin(path string) bool {
+	return path == "/api/login"
+}
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Feed struct {
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	LastFetchedAt time.Time `json:"last_fetched_at"`
+	// LastSuccessfulFetchAt is zero until the feed is fetched and parsed
+	LastSuccessfulFetchAt time.Time `json:"last_successful_fetch_at"`
+	Title                 string    `json:"title"`
+	Url                   string    `json:"url"`
+	UserID                uuid.UUID `json:"user_id"`
+	// UserName is the name of the user who added the feed, FollowerCount is how
+	// many users follow it and the recent counts cover the past week. They and Tags
+	// are only set by ListFeeds and QueryFeeds.
+	UserName            string   `json:"user_name,omitempty"`
+	FollowerCount       int64    `json:"follower_count,omitempty"`
+	RecentFollowerCount int64    `json:"recent_follower_count,omitempty"`
+	RecentPostCount     int64    `json:"recent_post_count,omitempty"`
+	Tags                []string `json:"tags,omitempty"`
+}
+
+// Tag is a tag and how many feeds have it
+type Tag struct {
+	Tag       string `json:"tag"`
+	FeedCount int64  `json:"feed_count"`
+}
+
+// Orders for FeedQuery.Sort
+const (
+	SortNewest    = "newest"
+	SortFollowers = "followers"
+	SortUpdated   = "updated"
+	// SortPopular puts the feeds gaining the most followers in the past week first
+	SortPopular = "popular"
+)
+
+// FeedQuery selects a page of feeds. Zero values use the server's defaults: the
+// newest feeds first, 20 at a time, with no search.
+type FeedQuery struct {
+	// Search matches feeds whose title or URL contains it, ignoring case
+	Search string
+	// Tag only matches feeds with the tag
+	Tag    string
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+// CreateFeed adds a feed, which the authenticated user then follows
+func (c *Client) CreateFeed(ctx context.Context, title, url string) (Feed, error) {
+	req := struct {
+		Title string `json:"title"`
+		Url   string `json:"url"`
+	}{title, url}
+
+	var feed Feed
+	err := c.do(ctx, http.MethodPost, "/api/feeds", req, &feed)
+	return feed, err
+}
+
+func (c *Client) GetFeed(ctx context.Context, id uuid.UUID) (Feed, error) {
+	var feed Feed
+	err := c.do(ctx, http.MethodGet, "/api/feeds/"+id.String(), nil, &feed)
+	return feed, err
+}
+
+// SetFeedTags replaces the tags of a feed the authenticated user added and
+// returns them as saved: lowercased and sorted
+func (c *Client) SetFeedTags(ctx context.Context, id uuid.UUID, tags []string) ([]string, error) {
+	req := struct {
+		Tags []string `json:"tags"`
+	}{tags}
+
+	var res struct {
+		Tags []string `json:"tags"`
+	}
+	err := c.do(ctx, http.MethodPut, "/api/feeds/"+id.String()+"/tags", req, &res)
+	return res.Tags, err
+}
+
+// ListTags returns every tag in use, most used first
+func (c *Client) ListTags(ctx context.Context) ([]Tag, error) {
+	var res struct {
+		Tags []Tag `json:"tags"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/tags", nil, &res)
+	return res.Tags, err
+}
+
+// QueryFeeds returns a page of feeds
+func (c *Client) QueryFeeds(ctx context.Context, q FeedQuery) ([]Feed, error) {
+	query := url.Values{}
+	if q.Search != "" {
+		query.Set("q", q.Search)
+	}
+	if q.Tag != "" {
+		query.Set("tag", q.Tag)
+	}
+	if q.Sort != "" {
+		query.Set("sort", q.Sort)
+	}
+	if q.Limit != 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Offset != 0 {
+		query.Set("offset", strconv.Itoa(q.Offset))
+	}
+	path := "/api/feeds"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var res struct {
+		Feeds []Feed `json:"feeds"`
+	}
+	err := c.do(ctx, http.MethodGet, path, nil, &res)
+	return res.Feeds, err
+}
+
+// Feeds iterates over every feed matching q from q.Offset on, fetching q.Limit
+// feeds at a time, or MaxPageSize when it's zero. Iteration stops after the first
+// error.
+func (c *Client) Feeds(ctx context.Context, q FeedQuery) iter.Seq2[Feed, error] {
+	if q.Limit <= 0 || q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+	return func(yield func(Feed, error) bool) {
+		for ; ; q.Offset += q.Limit {
+			feeds, err := c.QueryFeeds(ctx, q)
+			if err != nil {
+				yield(Feed{}, err)
+				return
+			}
+			for _, feed := range feeds {
+				if !yield(feed, nil) {
+					return
+				}
+			}
+			if len(feeds) < q.Limit {
+				return
+			}
+		}
+	}
+}
+
+// ListFeeds returns every feed, newest first
+func (c *Client) ListFeeds(ctx context.Context) ([]Feed, error) {
+	var feeds []Feed
+	for feed, err := range c.Feeds(ctx, FeedQuery{}) {
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds, nil
+}
+
+// Aggregate fetches the feed that was fetched least recently and saves its posts
+func (c *Client) Aggregate(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/agg", nil, nil)
+}
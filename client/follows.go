@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FeedFollow struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	FeedID    uuid.UUID `json:"feed_id"`
+}
+
+// FollowedFeed is a feed the authenticated user follows
+type FollowedFeed struct {
+	FeedFollow
+	Title    string `json:"title"`
+	PostedBy string `json:"posted_by"`
+}
+
+type feedURLRequest struct {
+	Url string `json:"url"`
+}
+
+// Follow follows the feed with the given URL
+func (c *Client) Follow(ctx context.Context, url string) (FeedFollow, error) {
+	var follow FeedFollow
+	err := c.do(ctx, http.MethodPost, "/api/follows", feedURLRequest{url}, &follow)
+	return follow, err
+}
+
+// FollowByID follows the feed with the given ID
+func (c *Client) FollowByID(ctx context.Context, id uuid.UUID) (FeedFollow, error) {
+	req := struct {
+		FeedID uuid.UUID `json:"feed_id"`
+	}{id}
+
+	var follow FeedFollow
+	err := c.do(ctx, http.MethodPost, "/api/follows", req, &follow)
+	return follow, err
+}
+
+func (c *Client) Following(ctx context.Context) ([]FollowedFeed, error) {
+	var res struct {
+		FeedsFollowed []FollowedFeed `json:"feeds_followed"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/follows", nil, &res)
+	return res.FeedsFollowed, err
+}
+
+// Recommendation is a feed the authenticated user doesn't follow that people who
+// follow the same feeds do
+type Recommendation struct {
+	Feed            Feed      `json:"feed"`
+	Score           float64   `json:"score"`
+	Explanation     string    `json:"explanation"`
+	BecauseFeedID   uuid.UUID `json:"because_feed_id"`
+	CommonFollowers int64     `json:"common_followers"`
+}
+
+// Recommendations returns up to limit recommended feeds, best match first, or
+// the server's default of 10 when limit is 0
+func (c *Client) Recommendations(ctx context.Context, limit int) ([]Recommendation, error) {
+	path := "/api/recommendations/feeds"
+	if limit != 0 {
+		path += "?limit=" + strconv.Itoa(limit)
+	}
+
+	var res struct {
+		Recommendations []Recommendation `json:"recommendations"`
+	}
+	err := c.do(ctx, http.MethodGet, path, nil, &res)
+	return res.Recommendations, err
+}
+
+// Unfollow unfollows the feed with the given URL. It succeeds if the feed wasn't
+// followed.
+func (c *Client) Unfollow(ctx context.Context, url string) error {
+	return c.do(ctx, http.MethodDelete, "/api/follows", feedURLRequest{url}, nil)
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxPageSize is the most posts or feeds the API returns at once
+const MaxPageSize = 100
+
+type Post struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Title       string    `json:"title"`
+	Url         string    `json:"url"`
+	Description string    `json:"description,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+	FeedID      uuid.UUID `json:"feed_id"`
+}
+
+// ListPosts returns a page of posts from followed feeds, newest first. A limit of
+// zero uses the server's default.
+func (c *Client) ListPosts(ctx context.Context, limit, offset int) ([]Post, error) {
+	query := url.Values{}
+	if limit != 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if offset != 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+	path := "/api/posts"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var res struct {
+		Posts []Post `json:"posts"`
+	}
+	err := c.do(ctx, http.MethodGet, path, nil, &res)
+	return res.Posts, err
+}
+
+// Posts iterates over every post from followed feeds, newest first, fetching
+// pageSize posts at a time. Iteration stops after the first error.
+func (c *Client) Posts(ctx context.Context, pageSize int) iter.Seq2[Post, error] {
+	pageSize = min(max(pageSize, 1), MaxPageSize)
+	return func(yield func(Post, error) bool) {
+		for offset := 0; ; offset += pageSize {
+			posts, err := c.ListPosts(ctx, pageSize, offset)
+			if err != nil {
+				yield(Post{}, err)
+				return
+			}
+			for _, post := range posts {
+				if !yield(post, nil) {
+					return
+				}
+			}
+			if len(posts) < pageSize {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type User struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+}
+
+type LoginResponse struct {
+	User
+	Token string `json:"token"`
+}
+
+// Login logs in and uses the returned token for later requests. expiresIn is
+// capped at an hour by the server, and zero asks for the maximum.
+func (c *Client) Login(ctx context.Context, name, password string, expiresIn time.Duration) (LoginResponse, error) {
+	req := struct {
+		Name      string `json:"name"`
+		Password  string `json:"password"`
+		ExpiresIn int    `json:"expires_in_seconds,omitempty"`
+	}{name, password, int(expiresIn.Seconds())}
+
+	var res LoginResponse
+	if err := c.do(ctx, http.MethodPost, "/api/login", req, &res); err != nil {
+		return LoginResponse{}, err
+	}
+	c.SetToken(res.Token)
+	return res, nil
+}
+
+func (c *Client) CreateUser(ctx context.Context, name, password string) (User, error) {
+	req := struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}{name, password}
+
+	var user User
+	err := c.do(ctx, http.MethodPost, "/api/users", req, &user)
+	return user, err
+}
+
+// GetUser gets the authenticated user
+func (c *Client) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	var user User
+	err := c.do(ctx, http.MethodGet, "/api/users/"+id.String(), nil, &user)
+	return user, err
+}
+
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	var res struct {
+		Users []User `json:"users"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/users", nil, &res)
+	return res.Users, err
+}
+
+// DeleteUser deletes the authenticated user with their feeds and follows
+func (c *Client) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, http.MethodDelete, "/api/users/"+id.String(), nil, nil)
+}
+
+// ChangePassword changes the authenticated user's password
+func (c *Client) ChangePassword(ctx context.Context, id uuid.UUID, currentPassword, newPassword string) error {
+	req := struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}{currentPassword, newPassword}
+
+	return c.do(ctx, http.MethodPut, "/api/users/"+id.String()+"/password", req, nil)
+}
+
+// ResetPassword sets a new password with a reset token from an operator
+func (c *Client) ResetPassword(ctx context.Context, token, newPassword string) error {
+	req := struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}{token, newPassword}
+
+	return c.do(ctx, http.MethodPost, "/api/password-reset", req, nil)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/imeltsner/gator-api/client"
+)
+
+// TestClient runs the client package against the real handlers
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	server := newTestServer(t)
+	feeds := newFeedServer(t)
+
+	var tokens []string
+	c := client.New(server.URL,
+		client.WithHTTPClient(server.Client()),
+		client.WithCredentials("alice", "hunter22"),
+		client.WithTokenHook(func(token string) { tokens = append(tokens, token) }),
+	)
+
+	user, err := c.CreateUser(ctx, "alice", "hunter22")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := c.CreateUser(ctx, "alice", "hunter22"); !client.IsStatus(err, http.StatusConflict) {
+		t.Errorf("CreateUser duplicate: got %v, want 409", err)
+	}
+
+	// The first authenticated call logs in through the refresh hook
+	feed, err := c.CreateFeed(ctx, "RSS", feeds.URL+"/rss.xml")
+	if err != nil {
+		t.Fatalf("CreateFeed: %v", err)
+	}
+	if len(tokens) != 1 || c.Token() != tokens[0] {
+		t.Errorf("token hook: got %d tokens, want 1", len(tokens))
+	}
+	if feed.UserID != user.ID {
+		t.Errorf("feed user: got %v, want %v", feed.UserID, user.ID)
+	}
+	if _, err := c.CreateFeed(ctx, "Atom", feeds.URL+"/atom.xml"); err != nil {
+		t.Fatalf("CreateFeed: %v", err)
+	}
+
+	// A rejected token is refreshed and the request retried
+	c.SetToken("expired")
+	got, err := c.GetUser(ctx, user.ID)
+	if err != nil || got.Name != "alice" {
+		t.Fatalf("GetUser after refresh: got %+v, %v", got, err)
+	}
+	if len(tokens) != 3 {
+		t.Errorf("token hook calls: got %d, want 3", len(tokens))
+	}
+
+	allFeeds, err := c.ListFeeds(ctx)
+	if err != nil || len(allFeeds) != 2 || allFeeds[0].UserName != "alice" {
+		t.Errorf("ListFeeds: got %+v, %v", allFeeds, err)
+	}
+	if found, err := c.QueryFeeds(ctx, client.FeedQuery{Search: "ATOM", Sort: client.SortFollowers}); err != nil || len(found) != 1 || found[0].FollowerCount != 1 {
+		t.Errorf("QueryFeeds: got %+v, %v", found, err)
+	}
+	if tags, err := c.SetFeedTags(ctx, feed.ID, []string{"News"}); err != nil || !slices.Equal(tags, []string{"news"}) {
+		t.Errorf("SetFeedTags: got %q, %v", tags, err)
+	}
+	if tags, err := c.ListTags(ctx); err != nil || len(tags) != 1 || tags[0] != (client.Tag{Tag: "news", FeedCount: 1}) {
+		t.Errorf("ListTags: got %+v, %v", tags, err)
+	}
+	if found, err := c.QueryFeeds(ctx, client.FeedQuery{Tag: "news", Sort: client.SortPopular}); err != nil || len(found) != 1 || found[0].ID != feed.ID || found[0].RecentFollowerCount != 1 {
+		t.Errorf("QueryFeeds by tag: got %+v, %v", found, err)
+	}
+	if got, err := c.GetFeed(ctx, feed.ID); err != nil || got.Url != feed.Url {
+		t.Errorf("GetFeed: got %+v, %v", got, err)
+	}
+	if users, err := c.ListUsers(ctx); err != nil || len(users) != 1 {
+		t.Errorf("ListUsers: got %+v, %v", users, err)
+	}
+
+	for range 2 {
+		if err := c.Aggregate(ctx); err != nil {
+			t.Fatalf("Aggregate: %v", err)
+		}
+	}
+
+	var titles []string
+	for post, err := range c.Posts(ctx, 2) {
+		if err != nil {
+			t.Fatalf("Posts: %v", err)
+		}
+		titles = append(titles, post.Title)
+	}
+	want := []string{"Atom entry two", "Atom entry one", "Third post", "Second & post", "First post"}
+	if !slices.Equal(titles, want) {
+		t.Errorf("Posts: got %q, want %q", titles, want)
+	}
+	for range c.Posts(ctx, 2) {
+		break
+	}
+
+	if err := c.Unfollow(ctx, feed.Url); err != nil {
+		t.Fatalf("Unfollow: %v", err)
+	}
+	following, err := c.Following(ctx)
+	if err != nil || len(following) != 1 || following[0].Title != "Atom" || following[0].PostedBy != "alice" || following[0].FeedID == feed.ID {
+		t.Errorf("Following: got %+v, %v", following, err)
+	}
+	if follow, err := c.FollowByID(ctx, feed.ID); err != nil || follow.FeedID != feed.ID {
+		t.Errorf("FollowByID: got %+v, %v", follow, err)
+	}
+
+	if recs, err := c.Recommendations(ctx, 5); err != nil || len(recs) != 0 {
+		t.Errorf("Recommendations: got %+v, %v; want none", recs, err)
+	}
+
+	// Validation errors are decoded with their details
+	_, err = c.CreateFeed(ctx, "", "ftp://example.com")
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("CreateFeed invalid: got %v, want *client.Error", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != "validation_failed" || len(apiErr.Details) != 2 || apiErr.RequestID == "" {
+		t.Errorf("CreateFeed invalid: got %+v", apiErr)
+	}
+
+	key, err := c.CreateAPIKey(ctx, "cron", []string{client.ScopeReadPosts}, time.Now().Add(time.Hour))
+	if err != nil || key.Key == "" || !slices.Equal(key.Scopes, []string{client.ScopeReadPosts}) || key.ExpiresAt == nil {
+		t.Fatalf("CreateAPIKey: got %+v, %v", key, err)
+	}
+	keyClient := client.New(server.URL, client.WithHTTPClient(server.Client()), client.WithToken(key.Key))
+	if _, err := keyClient.ListPosts(ctx, 1, 0); err != nil {
+		t.Errorf("ListPosts with an API key: %v", err)
+	}
+	if _, err := keyClient.Following(ctx); !client.IsStatus(err, http.StatusForbidden) {
+		t.Errorf("Following with a posts:read key: got %v, want 403", err)
+	}
+	if keys, err := c.ListAPIKeys(ctx); err != nil || len(keys) != 1 || keys[0].ID != key.ID {
+		t.Errorf("ListAPIKeys: got %+v, %v", keys, err)
+	}
+	if err := c.DeleteAPIKey(ctx, key.ID); err != nil {
+		t.Errorf("DeleteAPIKey: %v", err)
+	}
+
+	if err := c.ChangePassword(ctx, user.ID, "hunter22", "correct horse"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if _, err := c.Login(ctx, "alice", "correct horse", 0); err != nil {
+		t.Fatalf("Login with the new password: %v", err)
+	}
+
+	if err := c.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	// The refresh hook can't log in as a deleted user
+	c.SetToken("")
+	if _, err := c.Following(ctx); !client.IsStatus(err, http.StatusNotFound) {
+		t.Errorf("Following after delete: got %v, want the failed login's 404", err)
+	}
+}
+
+func TestClientNonJSONError(t *testing.T) {
+	server := newTestServer(t)
+	c := client.New(server.URL + "/missing")
+
+	_, err := c.ListUsers(context.Background())
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "not_found" {
+		t.Errorf("ListUsers on a missing route: got %v", err)
+	}
+}
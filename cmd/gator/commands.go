@@ -0,0 +1,678 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/client"
+	"golang.org/x/term"
+)
+
+type cli struct {
+	stdin           io.Reader
+	stdout          io.Writer
+	stderr          io.Writer
+	getenv          func(string) string
+	server          string
+	credentialsPath string
+	creds           credentials
+	input           *bufio.Reader
+}
+
+func (c *cli) dispatch(ctx context.Context, cmd string, args []string) error {
+	commands := map[string]func(context.Context, []string) error{
+		"register":       c.register,
+		"login":          c.login,
+		"logout":         c.logout,
+		"passwd":         c.passwd,
+		"reset-password": c.resetPassword,
+		"keys":           c.keys,
+		"feeds":          c.feeds,
+		"follow":         c.follow,
+		"unfollow":       c.unfollow,
+		"following":      c.following,
+		"recommend":      c.recommend,
+		"browse":         c.browse,
+		"agg":            c.aggregate,
+		"import":         c.importOPML,
+		"export":         c.exportOPML,
+	}
+	command, ok := commands[cmd]
+	if !ok {
+		fmt.Fprint(c.stderr, usage)
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+
+	err := command(ctx, args)
+	if client.IsStatus(err, http.StatusUnauthorized) && cmd != "login" && cmd != "reset-password" {
+		return fmt.Errorf("%v\nyour session has expired or you are not logged in; run gator login", err)
+	}
+	return err
+}
+
+// api returns a client using GATOR_API_KEY or the saved token. The token is only
+// sent to the server it was issued by.
+func (c *cli) api() *client.Client {
+	token := c.getenv("GATOR_API_KEY")
+	if token == "" && c.creds.Server == c.server {
+		token = c.creds.Token
+	}
+	return client.New(c.server, client.WithToken(token))
+}
+
+func (c *cli) flags(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet("gator "+name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: gator %v %v\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the command's flags and checks it was given n arguments
+func parse(fs *flag.FlagSet, args []string, n int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != n {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	return nil
+}
+
+// password reads GATOR_PASSWORD or the next line of stdin
+func (c *cli) password() (string, error) {
+	return c.secret("GATOR_PASSWORD", "Password")
+}
+
+// secret reads the environment variable env, or prompts for it on stdin. A
+// terminal doesn't echo what is typed; piped input is read a line at a time.
+func (c *cli) secret(env, prompt string) (string, error) {
+	if value := c.getenv(env); value != "" {
+		return value, nil
+	}
+	fmt.Fprintf(c.stderr, "%v: ", prompt)
+
+	var value string
+	if f, ok := c.stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		typed, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(c.stderr)
+		if err != nil {
+			return "", fmt.Errorf("unable to read %v: %v", strings.ToLower(prompt), err)
+		}
+		value = string(typed)
+	} else {
+		if c.input == nil {
+			c.input = bufio.NewReader(c.stdin)
+		}
+		line, err := c.input.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("unable to read %v: %v", strings.ToLower(prompt), err)
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+	if value == "" {
+		return "", fmt.Errorf("%v is required", strings.ToLower(prompt))
+	}
+	return value, nil
+}
+
+func (c *cli) printJSON(v any) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func (c *cli) table() *tabwriter.Writer {
+	return tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func (c *cli) register(ctx context.Context, args []string) error {
+	fs := c.flags("register", "<name>")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	password, err := c.password()
+	if err != nil {
+		return err
+	}
+
+	user, err := c.api().CreateUser(ctx, fs.Arg(0), password)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Registered %v (%v). Run gator login %v to log in.\n", user.Name, user.ID, user.Name)
+	return nil
+}
+
+func (c *cli) login(ctx context.Context, args []string) error {
+	fs := c.flags("login", "<name>")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	password, err := c.password()
+	if err != nil {
+		return err
+	}
+
+	res, err := c.api().Login(ctx, fs.Arg(0), password, 0)
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && apiErr.Code == "password_reset_required" {
+		return fmt.Errorf("%v\nask an operator for a reset token and run gator reset-password <token>", err)
+	} else if err != nil {
+		return err
+	}
+	c.creds = credentials{
+		Server: c.server,
+		UserID: res.ID,
+		Name:   res.Name,
+		Token:  res.Token,
+	}
+	if err := saveCredentials(c.credentialsPath, c.creds); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Logged in to %v as %v\n", c.server, res.Name)
+	return nil
+}
+
+func (c *cli) logout(ctx context.Context, args []string) error {
+	if err := parse(c.flags("logout", ""), args, 0); err != nil {
+		return err
+	}
+	err := os.Remove(c.credentialsPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to remove credentials: %v", err)
+	}
+	fmt.Fprintln(c.stdout, "Logged out")
+	return nil
+}
+
+// passwd reads the current password from GATOR_PASSWORD and the new one from
+// GATOR_NEW_PASSWORD, prompting on stdin for either that isn't set
+func (c *cli) passwd(ctx context.Context, args []string) error {
+	if err := parse(c.flags("passwd", ""), args, 0); err != nil {
+		return err
+	}
+	current, err := c.secret("GATOR_PASSWORD", "Current password")
+	if err != nil {
+		return err
+	}
+	password, err := c.secret("GATOR_NEW_PASSWORD", "New password")
+	if err != nil {
+		return err
+	}
+
+	err = c.api().ChangePassword(ctx, c.creds.UserID, current, password)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "Password changed")
+	return nil
+}
+
+func (c *cli) resetPassword(ctx context.Context, args []string) error {
+	fs := c.flags("reset-password", "<token>")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	password, err := c.secret("GATOR_NEW_PASSWORD", "New password")
+	if err != nil {
+		return err
+	}
+
+	err = c.api().ResetPassword(ctx, fs.Arg(0), password)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "Password reset. Run gator login <name> to log in.")
+	return nil
+}
+
+func (c *cli) keys(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "create":
+			return c.createKey(ctx, args[1:])
+		case "list":
+			return c.listKeys(ctx, args[1:])
+		case "revoke":
+			return c.revokeKey(ctx, args[1:])
+		}
+	}
+	fmt.Fprintln(c.stderr, "usage: gator keys create [-scopes list] [-expires duration] <name>\n       gator keys list [-json]\n       gator keys revoke <id>")
+	return flag.ErrHelp
+}
+
+func (c *cli) createKey(ctx context.Context, args []string) error {
+	fs := c.flags("keys create", "[-scopes list] [-expires duration] <name>")
+	scopes := fs.String("scopes", "", "comma separated scopes: posts:read, follows:manage, feeds:manage (default all)")
+	expires := fs.Duration("expires", 0, "how long the key is valid for (default forever)")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	var scopeList []string
+	if *scopes != "" {
+		scopeList = strings.Split(*scopes, ",")
+	}
+	var expiresAt time.Time
+	if *expires > 0 {
+		expiresAt = time.Now().Add(*expires)
+	}
+
+	key, err := c.api().CreateAPIKey(ctx, fs.Arg(0), scopeList, expiresAt)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "Created %v with scopes %v. The key is only shown once:\n", key.Name, strings.Join(key.Scopes, ", "))
+	fmt.Fprintln(c.stdout, key.Key)
+	return nil
+}
+
+func (c *cli) listKeys(ctx context.Context, args []string) error {
+	fs := c.flags("keys list", "[-json]")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	keys, err := c.api().ListAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return c.printJSON(keys)
+	}
+
+	optionalTime := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return formatTime(*t)
+	}
+	tw := c.table()
+	fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tSCOPES\tEXPIRES\tLAST USED")
+	for _, key := range keys {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n", key.ID, key.Name, key.Prefix, strings.Join(key.Scopes, ","), optionalTime(key.ExpiresAt), optionalTime(key.LastUsedAt))
+	}
+	return tw.Flush()
+}
+
+func (c *cli) revokeKey(ctx context.Context, args []string) error {
+	fs := c.flags("keys revoke", "<id>")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	id, err := uuid.Parse(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid key id %q", fs.Arg(0))
+	}
+
+	if err := c.api().DeleteAPIKey(ctx, id); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Revoked %v\n", id)
+	return nil
+}
+
+func (c *cli) feeds(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			return c.addFeed(ctx, args[1:])
+		case "list":
+			return c.listFeeds(ctx, args[1:])
+		case "tag":
+			return c.tagFeed(ctx, args[1:])
+		}
+	}
+	fmt.Fprintln(c.stderr, "usage: gator feeds add [-tags list] <title> <url>\n       gator feeds list [-search text] [-tag tag] [-sort newest|followers|updated|popular] [-limit n] [-json]\n       gator feeds tag <id> [tag...]")
+	return flag.ErrHelp
+}
+
+func (c *cli) addFeed(ctx context.Context, args []string) error {
+	fs := c.flags("feeds add", "[-tags list] <title> <url>")
+	tags := fs.String("tags", "", "comma-separated tags to help others find the feed")
+	if err := parse(fs, args, 2); err != nil {
+		return err
+	}
+
+	feed, err := c.api().CreateFeed(ctx, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Added and followed %v (%v)\n", feed.Title, feed.ID)
+	if *tags == "" {
+		return nil
+	}
+	saved, err := c.api().SetFeedTags(ctx, feed.ID, strings.Split(*tags, ","))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Tagged %v\n", strings.Join(saved, ", "))
+	return nil
+}
+
+func (c *cli) tagFeed(ctx context.Context, args []string) error {
+	fs := c.flags("feeds tag", "<id> [tag...]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	id, err := uuid.Parse(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid feed id %q", fs.Arg(0))
+	}
+
+	saved, err := c.api().SetFeedTags(ctx, id, fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	if len(saved) == 0 {
+		fmt.Fprintln(c.stdout, "Removed all tags")
+		return nil
+	}
+	fmt.Fprintf(c.stdout, "Tagged %v\n", strings.Join(saved, ", "))
+	return nil
+}
+
+func (c *cli) listFeeds(ctx context.Context, args []string) error {
+	fs := c.flags("feeds list", "[-search text] [-tag tag] [-sort newest|followers|updated|popular] [-limit n] [-json]")
+	search := fs.String("search", "", "only list feeds whose title or URL contains this")
+	tag := fs.String("tag", "", "only list feeds with this tag")
+	sort := fs.String("sort", client.SortNewest, "order: newest, followers (most followed), updated (recently updated) or popular (most new followers this week)")
+	limit := fs.Int("limit", 0, "number of feeds to show, or 0 for all of them")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	feeds := []client.Feed{}
+	for feed, err := range c.api().Feeds(ctx, client.FeedQuery{Search: *search, Tag: *tag, Sort: *sort, Limit: *limit}) {
+		if err != nil {
+			return err
+		}
+		feeds = append(feeds, feed)
+		if *limit > 0 && len(feeds) == *limit {
+			break
+		}
+	}
+	if *asJSON {
+		return c.printJSON(feeds)
+	}
+
+	tw := c.table()
+	fmt.Fprintln(tw, "ID\tTITLE\tURL\tADDED BY\tFOLLOWERS\tPOSTS THIS WEEK\tTAGS\tLAST FETCHED")
+	for _, feed := range feeds {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", feed.ID, feed.Title, feed.Url, feed.UserName, feed.FollowerCount, feed.RecentPostCount, strings.Join(feed.Tags, ","), formatTime(feed.LastSuccessfulFetchAt))
+	}
+	return tw.Flush()
+}
+
+func (c *cli) follow(ctx context.Context, args []string) error {
+	fs := c.flags("follow", "<url|id>")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	// Feeds are followed by ID when picked from the feeds list
+	var err error
+	if id, parseErr := uuid.Parse(fs.Arg(0)); parseErr == nil {
+		_, err = c.api().FollowByID(ctx, id)
+	} else {
+		_, err = c.api().Follow(ctx, fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Following %v\n", fs.Arg(0))
+	return nil
+}
+
+func (c *cli) unfollow(ctx context.Context, args []string) error {
+	fs := c.flags("unfollow", "<url>")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	if err := c.api().Unfollow(ctx, fs.Arg(0)); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Unfollowed %v\n", fs.Arg(0))
+	return nil
+}
+
+func (c *cli) following(ctx context.Context, args []string) error {
+	fs := c.flags("following", "[-json]")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	follows, err := c.api().Following(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return c.printJSON(follows)
+	}
+
+	tw := c.table()
+	fmt.Fprintln(tw, "TITLE\tADDED BY\tFOLLOWED SINCE")
+	for _, follow := range follows {
+		fmt.Fprintf(tw, "%v\t%v\t%v\n", follow.Title, follow.PostedBy, formatTime(follow.CreatedAt))
+	}
+	return tw.Flush()
+}
+
+func (c *cli) recommend(ctx context.Context, args []string) error {
+	fs := c.flags("recommend", "[-limit n] [-json]")
+	limit := fs.Int("limit", 10, "number of feeds to suggest")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	recommendations, err := c.api().Recommendations(ctx, *limit)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return c.printJSON(recommendations)
+	}
+	if len(recommendations) == 0 {
+		fmt.Fprintln(c.stdout, "No recommendations yet; follow some feeds and check back later")
+		return nil
+	}
+
+	tw := c.table()
+	fmt.Fprintln(tw, "ID\tTITLE\tURL\tWHY")
+	for _, rec := range recommendations {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", rec.Feed.ID, rec.Feed.Title, rec.Feed.Url, rec.Explanation)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) browse(ctx context.Context, args []string) error {
+	fs := c.flags("browse", "[-limit n] [-offset n] [-json]")
+	limit := fs.Int("limit", 10, "number of posts to show, or 0 for all of them")
+	offset := fs.Int("offset", 0, "number of posts to skip")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	api := c.api()
+	posts := []client.Post{}
+	for page := *offset; ; {
+		pageSize := client.MaxPageSize
+		if *limit > 0 {
+			pageSize = min(pageSize, *limit-len(posts))
+		}
+		batch, err := api.ListPosts(ctx, pageSize, page)
+		if err != nil {
+			return err
+		}
+		posts = append(posts, batch...)
+		page += len(batch)
+		if len(batch) < pageSize || len(posts) == *limit {
+			break
+		}
+	}
+	if *asJSON {
+		return c.printJSON(posts)
+	}
+
+	feeds, err := api.ListFeeds(ctx)
+	if err != nil {
+		return err
+	}
+	titles := map[uuid.UUID]string{}
+	for _, feed := range feeds {
+		titles[feed.ID] = feed.Title
+	}
+
+	tw := c.table()
+	fmt.Fprintln(tw, "PUBLISHED\tFEED\tTITLE\tURL")
+	for _, post := range posts {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", formatTime(post.PublishedAt), titles[post.FeedID], post.Title, post.Url)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) aggregate(ctx context.Context, args []string) error {
+	if err := parse(c.flags("agg", ""), args, 0); err != nil {
+		return err
+	}
+	if err := c.api().Aggregate(ctx); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "Fetched the next feed")
+	return nil
+}
+
+// importOPML adds each feed in the file, or follows it if it has already been
+// added, and carries on past feeds that fail
+func (c *cli) importOPML(ctx context.Context, args []string) error {
+	fs := c.flags("import", "<file>")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	feeds, err := parseOPML(file)
+	if err != nil {
+		return err
+	}
+
+	api := c.api()
+	var added, followed, existing, failed int
+	for _, feed := range feeds {
+		_, err := api.CreateFeed(ctx, feed.Title, feed.URL)
+		if client.IsStatus(err, http.StatusConflict) {
+			_, err = api.Follow(ctx, feed.URL)
+			switch {
+			case client.IsStatus(err, http.StatusConflict):
+				existing++
+				continue
+			case err == nil:
+				followed++
+				continue
+			}
+		} else if err == nil {
+			added++
+			continue
+		}
+
+		if client.IsStatus(err, http.StatusUnauthorized) {
+			return err
+		}
+		failed++
+		fmt.Fprintf(c.stderr, "unable to import %v: %v\n", feed.URL, err)
+	}
+
+	fmt.Fprintf(c.stdout, "Imported %d feeds: %d added, %d followed, %d already followed, %d failed\n",
+		len(feeds), added, followed, existing, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d feeds could not be imported", failed)
+	}
+	return nil
+}
+
+func (c *cli) exportOPML(ctx context.Context, args []string) error {
+	fs := c.flags("export", "[file]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	api := c.api()
+	follows, err := api.Following(ctx)
+	if err != nil {
+		return err
+	}
+	feeds, err := api.ListFeeds(ctx)
+	if err != nil {
+		return err
+	}
+	urls := map[uuid.UUID]string{}
+	for _, feed := range feeds {
+		urls[feed.ID] = feed.Url
+	}
+
+	var outlines []opmlFeed
+	for _, follow := range follows {
+		outlines = append(outlines, opmlFeed{Title: follow.Title, URL: urls[follow.FeedID]})
+	}
+
+	title := "gator subscriptions"
+	if c.creds.Name != "" {
+		title = fmt.Sprintf("gator subscriptions of %v", c.creds.Name)
+	}
+	if fs.NArg() == 0 {
+		return writeOPML(c.stdout, title, outlines)
+	}
+
+	file, err := os.Create(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := writeOPML(file, title, outlines); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "Exported %d feeds to %v\n", len(outlines), fs.Arg(0))
+	return nil
+}
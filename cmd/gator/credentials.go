@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// credentials are saved by login so later commands can reuse the token
+type credentials struct {
+	Server string    `json:"server"`
+	UserID uuid.UUID `json:"user_id"`
+	Name   string    `json:"name"`
+	Token  string    `json:"token"`
+}
+
+func defaultCredentialsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find config directory: %v", err)
+	}
+	return filepath.Join(dir, "gator", "credentials.json"), nil
+}
+
+// loadCredentials returns empty credentials if the file doesn't exist yet
+func loadCredentials(path string) (credentials, error) {
+	creds := credentials{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return creds, nil
+	} else if err != nil {
+		return creds, fmt.Errorf("unable to read credentials: %v", err)
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return creds, fmt.Errorf("unable to parse credentials %v: %v", path, err)
+	}
+	return creds, nil
+}
+
+// saveCredentials writes the file readable only by the current user, since it
+// holds a token
+func saveCredentials(path string, creds credentials) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("unable to create config directory: %v", err)
+	}
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("unable to save credentials: %v", err)
+	}
+	return nil
+}
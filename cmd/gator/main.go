@@ -0,0 +1,101 @@
+// Command gator is a command line client for the gator API.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+)
+
+const defaultServer = "http://localhost:8080"
+
+const usage = `usage: gator [-server URL] [-credentials FILE] <command> [flags] [args]
+
+Commands:
+  register <name>           create an account
+  login <name>              log in and save the token
+  logout                    forget the saved token
+  passwd                    change your password
+  reset-password <token>    set a new password with a reset token
+  keys create <name>        create an API key for scripts
+  keys list                 list your API keys
+  keys revoke <id>          revoke an API key
+  feeds add <title> <url>   add a feed and follow it
+  feeds list                list feeds, optionally searched, tagged and sorted
+  feeds tag <id> [tag...]   replace the tags of a feed you added
+  follow <url|id>           follow a feed
+  unfollow <url>            unfollow a feed
+  following                 list the feeds you follow
+  recommend                 suggest feeds followed by people with similar follows
+  browse                    show posts from the feeds you follow
+  agg                       fetch the feed that was fetched least recently
+  import <file>             add or follow every feed in an OPML file
+  export [file]             write the feeds you follow as OPML
+
+The password is read from GATOR_PASSWORD, and a new password from
+GATOR_NEW_PASSWORD, or prompted for on stdin. Set GATOR_API_KEY to use an
+API key instead of the saved login.
+Run "gator <command> -h" for a command's flags.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr, os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gator:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer, getenv func(string) string) error {
+	fs := flag.NewFlagSet("gator", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, usage) }
+	server := fs.String("server", getenv("GATOR_SERVER"), "API base URL (GATOR_SERVER)")
+	credentialsPath := fs.String("credentials", getenv("GATOR_CREDENTIALS"), "file the login token is saved in (GATOR_CREDENTIALS)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	if *credentialsPath == "" {
+		path, err := defaultCredentialsPath()
+		if err != nil {
+			return err
+		}
+		*credentialsPath = path
+	}
+	creds, err := loadCredentials(*credentialsPath)
+	if err != nil {
+		return err
+	}
+	if *server == "" {
+		*server = creds.Server
+	}
+	if *server == "" {
+		*server = defaultServer
+	}
+
+	c := &cli{
+		stdin:           stdin,
+		stdout:          stdout,
+		stderr:          stderr,
+		getenv:          getenv,
+		server:          *server,
+		credentialsPath: *credentialsPath,
+		creds:           creds,
+	}
+	return c.dispatch(ctx, fs.Arg(0), fs.Args()[1:])
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// fakeAPI serves just enough of the API for the commands under test
+type fakeAPI struct {
+	userID   uuid.UUID
+	password string
+	feeds    map[string]string // url to title
+	follows  map[string]bool
+	tags     map[string][]string // feed id to tags
+	requests []string
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, *httptest.Server) {
+	api := &fakeAPI{
+		userID:   uuid.New(),
+		password: "hunter22",
+		feeds:    map[string]string{"https://existing.example.com/rss": "Existing"},
+		follows:  map[string]bool{},
+		tags:     map[string][]string{},
+	}
+	feedID := func(url string) uuid.UUID { return uuid.NewSHA1(uuid.Nil, []byte(url)) }
+	mux := http.NewServeMux()
+	respond := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+	authed := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			api.requests = append(api.requests, r.Method+" "+r.URL.Path)
+			if r.Header.Get("Authorization") != "Bearer token-1" {
+				respond(w, http.StatusUnauthorized, map[string]any{"error": map[string]any{"code": "unauthorized", "message": "unable to validate jwt"}})
+				return
+			}
+			next(w, r)
+		}
+	}
+	conflict := map[string]any{"error": map[string]any{"code": "conflict", "message": "already exists"}}
+
+	mux.HandleFunc("POST /api/login", func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Name, Password string }
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Password != api.password {
+			respond(w, http.StatusUnauthorized, map[string]any{"error": map[string]any{"code": "unauthorized", "message": "incorrect password"}})
+			return
+		}
+		respond(w, http.StatusOK, map[string]any{"id": api.userID, "name": req.Name, "token": "token-1"})
+	})
+	mux.HandleFunc("PUT /api/users/{id}/password", authed(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			CurrentPassword string `json:"current_password"`
+			NewPassword     string `json:"new_password"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.PathValue("id") != api.userID.String() || req.CurrentPassword != api.password {
+			respond(w, http.StatusForbidden, map[string]any{"error": map[string]any{"code": "forbidden", "message": "incorrect current password"}})
+			return
+		}
+		api.password = req.NewPassword
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	mux.HandleFunc("POST /api/api-keys", authed(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name   string   `json:"name"`
+			Scopes []string `json:"scopes"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		respond(w, http.StatusCreated, map[string]any{"id": uuid.New(), "name": req.Name, "scopes": req.Scopes, "key": "gk_secret"})
+	}))
+	mux.HandleFunc("POST /api/feeds", authed(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Title, Url string }
+		json.NewDecoder(r.Body).Decode(&req)
+		if _, ok := api.feeds[req.Url]; ok {
+			respond(w, http.StatusConflict, conflict)
+			return
+		}
+		api.feeds[req.Url] = req.Title
+		api.follows[req.Url] = true
+		respond(w, http.StatusCreated, map[string]any{"id": feedID(req.Url), "title": req.Title, "url": req.Url})
+	}))
+	mux.HandleFunc("POST /api/follows", authed(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Url    string
+			FeedID uuid.UUID `json:"feed_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		for url := range api.feeds {
+			if req.FeedID == feedID(url) {
+				req.Url = url
+			}
+		}
+		if api.follows[req.Url] {
+			respond(w, http.StatusConflict, conflict)
+			return
+		}
+		api.follows[req.Url] = true
+		respond(w, http.StatusCreated, map[string]any{"id": uuid.New()})
+	}))
+	mux.HandleFunc("PUT /api/feeds/{id}/tags", authed(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Tags []string }
+		json.NewDecoder(r.Body).Decode(&req)
+		api.tags[r.PathValue("id")] = req.Tags
+		respond(w, http.StatusOK, map[string]any{"tags": req.Tags})
+	}))
+	mux.HandleFunc("GET /api/feeds", func(w http.ResponseWriter, r *http.Request) {
+		feeds := []map[string]any{}
+		for url, title := range api.feeds {
+			if strings.Contains(url, r.URL.Query().Get("q")) {
+				feeds = append(feeds, map[string]any{"id": feedID(url), "title": title, "url": url, "user_name": "alice", "follower_count": 1})
+			}
+		}
+		respond(w, http.StatusOK, map[string]any{"feeds": feeds})
+	})
+	mux.HandleFunc("GET /api/recommendations/feeds", authed(func(w http.ResponseWriter, r *http.Request) {
+		url := "https://existing.example.com/rss"
+		rec := map[string]any{
+			"feed":        map[string]any{"id": feedID(url), "title": api.feeds[url], "url": url},
+			"explanation": "followed by 2 people who follow New",
+		}
+		respond(w, http.StatusOK, map[string]any{"recommendations": []any{rec}})
+	}))
+	mux.HandleFunc("GET /api/follows", authed(func(w http.ResponseWriter, r *http.Request) {
+		var follows []map[string]any
+		for url := range api.follows {
+			follows = append(follows, map[string]any{"feed_id": feedID(url), "title": api.feeds[url], "posted_by": "alice"})
+		}
+		respond(w, http.StatusOK, map[string]any{"feeds_followed": follows})
+	}))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return api, server
+}
+
+type testCLI struct {
+	t           *testing.T
+	server      string
+	credentials string
+	env         map[string]string
+}
+
+func (c *testCLI) run(stdin string, args ...string) (string, string, error) {
+	c.t.Helper()
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	args = append([]string{"-server", c.server, "-credentials", c.credentials}, args...)
+	err := run(context.Background(), args, strings.NewReader(stdin), stdout, stderr, func(key string) string { return c.env[key] })
+	return stdout.String(), stderr.String(), err
+}
+
+func TestLoginSavesCredentials(t *testing.T) {
+	api, server := newFakeAPI(t)
+	cli := &testCLI{t: t, server: server.URL, credentials: filepath.Join(t.TempDir(), "gator", "credentials.json")}
+
+	if _, _, err := cli.run("wrong\n", "login", "alice"); err == nil {
+		t.Fatal("login with the wrong password: got nil error")
+	}
+	if _, err := os.Stat(cli.credentials); !os.IsNotExist(err) {
+		t.Errorf("credentials after failed login: got %v, want not exist", err)
+	}
+
+	// Commands fail with a hint until the user logs in
+	_, _, err := cli.run("", "following")
+	if err == nil || !strings.Contains(err.Error(), "gator login") {
+		t.Errorf("following before login: got %v, want a hint to log in", err)
+	}
+
+	out, _, err := cli.run("hunter22\n", "login", "alice")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if !strings.Contains(out, "Logged in") {
+		t.Errorf("login output: got %q", out)
+	}
+	info, err := os.Stat(cli.credentials)
+	if err != nil {
+		t.Fatalf("credentials: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("credentials permissions: got %v, want 0600", perm)
+	}
+	creds, err := loadCredentials(cli.credentials)
+	if err != nil || creds.Token != "token-1" || creds.UserID != api.userID || creds.Server != server.URL {
+		t.Errorf("saved credentials: got %+v, %v", creds, err)
+	}
+
+	// The server defaults to the one logged in to
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	err = run(context.Background(), []string{"-credentials", cli.credentials, "following", "-json"}, strings.NewReader(""), stdout, stderr, func(string) string { return "" })
+	if err != nil {
+		t.Fatalf("following with saved server: %v (%s)", err, stderr)
+	}
+
+	if _, _, err := cli.run("", "logout"); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	if _, err := os.Stat(cli.credentials); !os.IsNotExist(err) {
+		t.Errorf("credentials after logout: got %v, want not exist", err)
+	}
+}
+
+func TestPasswd(t *testing.T) {
+	api, server := newFakeAPI(t)
+	cli := &testCLI{t: t, server: server.URL, credentials: filepath.Join(t.TempDir(), "credentials.json")}
+	if _, _, err := cli.run("hunter22\n", "login", "alice"); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	// Both passwords are read from one stdin
+	if _, _, err := cli.run("wrong\ncorrect horse\n", "passwd"); err == nil {
+		t.Error("passwd with the wrong current password: got nil error")
+	}
+	out, _, err := cli.run("hunter22\ncorrect horse\n", "passwd")
+	if err != nil {
+		t.Fatalf("passwd: %v", err)
+	}
+	if !strings.Contains(out, "Password changed") || api.password != "correct horse" {
+		t.Errorf("passwd: got output %q and password %q", out, api.password)
+	}
+
+	cli.env = map[string]string{"GATOR_PASSWORD": "correct horse", "GATOR_NEW_PASSWORD": "battery staple"}
+	if _, _, err := cli.run("", "passwd"); err != nil || api.password != "battery staple" {
+		t.Errorf("passwd from the environment: got password %q, %v", api.password, err)
+	}
+}
+
+func TestAPIKeyFromEnvironment(t *testing.T) {
+	api, server := newFakeAPI(t)
+	cli := &testCLI{t: t, server: server.URL, credentials: filepath.Join(t.TempDir(), "credentials.json")}
+
+	cli.env = map[string]string{"GATOR_API_KEY": "token-1"}
+	if _, _, err := cli.run("", "following"); err != nil {
+		t.Fatalf("following with GATOR_API_KEY: %v", err)
+	}
+
+	// Only the key goes to stdout so it can be captured
+	out, _, err := cli.run("", "keys", "create", "-scopes", "posts:read,follows:manage", "cron")
+	if err != nil {
+		t.Fatalf("keys create: %v", err)
+	}
+	if out != "gk_secret\n" {
+		t.Errorf("keys create output: got %q", out)
+	}
+	if want := "POST /api/api-keys"; api.requests[len(api.requests)-1] != want {
+		t.Errorf("last request: got %v, want %v", api.requests[len(api.requests)-1], want)
+	}
+}
+
+func TestImportExportOPML(t *testing.T) {
+	api, server := newFakeAPI(t)
+	dir := t.TempDir()
+	cli := &testCLI{
+		t:           t,
+		server:      server.URL,
+		credentials: filepath.Join(dir, "credentials.json"),
+		env:         map[string]string{"GATOR_PASSWORD": "hunter22"},
+	}
+	if _, _, err := cli.run("", "login", "alice"); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	opmlPath := filepath.Join(dir, "subscriptions.opml")
+	os.WriteFile(opmlPath, []byte(`<opml version="2.0"><body>
+		<outline text="New" xmlUrl="https://new.example.com/rss"/>
+		<outline text="Existing" xmlUrl="https://existing.example.com/rss"/>
+	</body></opml>`), 0o644)
+
+	out, _, err := cli.run("", "import", opmlPath)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if !strings.Contains(out, "1 added, 1 followed, 0 already followed") {
+		t.Errorf("import output: got %q", out)
+	}
+	if !api.follows["https://new.example.com/rss"] || !api.follows["https://existing.example.com/rss"] {
+		t.Errorf("follows after import: got %v", api.follows)
+	}
+
+	out, _, err = cli.run("", "import", opmlPath)
+	if err != nil || !strings.Contains(out, "0 added, 0 followed, 2 already followed") {
+		t.Errorf("second import: got %q, %v", out, err)
+	}
+
+	out, _, err = cli.run("", "export")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	feeds, err := parseOPML(strings.NewReader(out))
+	if err != nil || len(feeds) != 2 {
+		t.Fatalf("exported OPML: got %+v, %v", feeds, err)
+	}
+	for _, feed := range feeds {
+		if !api.follows[feed.URL] {
+			t.Errorf("exported feed %+v is not followed", feed)
+		}
+	}
+
+	out, _, err = cli.run("", "feeds", "list")
+	if err != nil || !strings.Contains(out, "TITLE") || !strings.Contains(out, "https://new.example.com/rss") {
+		t.Errorf("feeds list: got %q, %v", out, err)
+	}
+	out, _, err = cli.run("", "feeds", "list", "-search", "new.example")
+	if err != nil || strings.Count(out, "\n") != 2 || !strings.Contains(out, "https://new.example.com/rss") {
+		t.Errorf("feeds list -search: got %q, %v", out, err)
+	}
+}
+
+func TestFeedTagsAndFollowByID(t *testing.T) {
+	api, server := newFakeAPI(t)
+	dir := t.TempDir()
+	cli := &testCLI{
+		t:           t,
+		server:      server.URL,
+		credentials: filepath.Join(dir, "credentials.json"),
+		env:         map[string]string{"GATOR_PASSWORD": "hunter22"},
+	}
+	if _, _, err := cli.run("", "login", "alice"); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	out, _, err := cli.run("", "feeds", "add", "-tags", "go,news", "New", "https://new.example.com/rss")
+	if err != nil || !strings.Contains(out, "Tagged go, news") {
+		t.Errorf("feeds add -tags: got %q, %v", out, err)
+	}
+	id := uuid.NewSHA1(uuid.Nil, []byte("https://new.example.com/rss")).String()
+	if got := api.tags[id]; len(got) != 2 || got[0] != "go" || got[1] != "news" {
+		t.Errorf("tags after feeds add: got %q", got)
+	}
+	out, _, err = cli.run("", "feeds", "tag", id)
+	if err != nil || !strings.Contains(out, "Removed all tags") || len(api.tags[id]) != 0 {
+		t.Errorf("feeds tag: got %q, %v, tags %q", out, err, api.tags[id])
+	}
+	if _, _, err := cli.run("", "feeds", "tag", "nope", "go"); err == nil {
+		t.Error("feeds tag with an invalid id succeeded")
+	}
+
+	out, _, err = cli.run("", "recommend")
+	if err != nil || !strings.Contains(out, "Existing") || !strings.Contains(out, "followed by 2 people who follow New") {
+		t.Errorf("recommend: got %q, %v", out, err)
+	}
+
+	existing := uuid.NewSHA1(uuid.Nil, []byte("https://existing.example.com/rss")).String()
+	if _, _, err := cli.run("", "follow", existing); err != nil || !api.follows["https://existing.example.com/rss"] {
+		t.Errorf("follow by id: got %v, follows %v", err, api.follows)
+	}
+}
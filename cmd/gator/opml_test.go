@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const sampleOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="1.0">
+  <head><title>Subscriptions</title></head>
+  <body>
+    <outline text="Go blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom"/>
+    <outline text="News">
+      <outline text="HN" title="Hacker News" type="rss" xmlUrl="https://news.ycombinator.com/rss"/>
+      <outline text="Not a feed" htmlUrl="https://example.com"/>
+    </outline>
+  </body>
+</opml>`
+
+func TestParseOPML(t *testing.T) {
+	feeds, err := parseOPML(strings.NewReader(sampleOPML))
+	if err != nil {
+		t.Fatalf("parseOPML: %v", err)
+	}
+	want := []opmlFeed{
+		{Title: "Go blog", URL: "https://go.dev/blog/feed.atom"},
+		{Title: "Hacker News", URL: "https://news.ycombinator.com/rss"},
+	}
+	if !slices.Equal(feeds, want) {
+		t.Errorf("parseOPML: got %+v, want %+v", feeds, want)
+	}
+
+	if _, err := parseOPML(strings.NewReader("not xml")); err == nil {
+		t.Error("parseOPML of invalid input: got nil error")
+	}
+}
+
+func TestWriteOPMLRoundTrip(t *testing.T) {
+	feeds := []opmlFeed{
+		{Title: "Tom & Jerry's <feed>", URL: "https://example.com/rss?a=1&b=2"},
+		{Title: "Other", URL: "https://other.example.com/atom"},
+	}
+	buf := &bytes.Buffer{}
+	if err := writeOPML(buf, "export", feeds); err != nil {
+		t.Fatalf("writeOPML: %v", err)
+	}
+	got, err := parseOPML(buf)
+	if err != nil {
+		t.Fatalf("parseOPML: %v", err)
+	}
+	if !slices.Equal(got, feeds) {
+		t.Errorf("round trip: got %+v, want %+v", got, feeds)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type openAPIDocument struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func loadOpenAPI(t *testing.T) (openAPIDocument, any) {
+	t.Helper()
+	var doc openAPIDocument
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("parse openapi.json: %v", err)
+	}
+	var raw any
+	json.Unmarshal(openAPISpec, &raw)
+	return doc, raw
+}
+
+// TestOpenAPICoversRoutes fails when a route is registered without being
+// documented, or documented without being registered
+func TestOpenAPICoversRoutes(t *testing.T) {
+	doc, _ := loadOpenAPI(t)
+	if !strings.HasPrefix(doc.OpenAPI, "3.1") {
+		t.Errorf("openapi version: got %q, want 3.1.x", doc.OpenAPI)
+	}
+
+	s := newTestState()
+	var registered []string
+	for _, pattern := range s.newMux().patterns {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			t.Errorf("route %q has no method", pattern)
+			continue
+		}
+		// {$} only stops a pattern ending in a slash from matching the subtree
+		path = strings.TrimSuffix(path, "{$}")
+		// OpenAPI has no wildcards that match the rest of the path
+		path = strings.ReplaceAll(path, "...}", "}")
+		registered = append(registered, strings.ToLower(method)+" "+path)
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("route %q is missing from api/openapi.json", pattern)
+		}
+	}
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" || method == "summary" || method == "description" {
+				continue
+			}
+			if !slices.Contains(registered, method+" "+path) {
+				t.Errorf("api/openapi.json documents %v %v, which is not registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestOpenAPIReferences(t *testing.T) {
+	_, raw := loadOpenAPI(t)
+
+	var walk func(node any)
+	walk = func(node any) {
+		switch v := node.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				if !resolves(raw, ref) {
+					t.Errorf("unresolved reference %q", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(raw)
+}
+
+func resolves(doc any, ref string) bool {
+	path, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return false
+	}
+	node := doc
+	for _, key := range strings.Split(path, "/") {
+		obj, ok := node.(map[string]any)
+		if !ok {
+			return false
+		}
+		if node, ok = obj[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func TestServeDocs(t *testing.T) {
+	server := newTestServer(t)
+
+	for path, contentType := range map[string]string{
+		"/api/openapi.json": "application/json",
+		"/api/docs":         "text/html; charset=utf-8",
+	} {
+		res, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %v: %v", path, err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != contentType {
+			t.Errorf("GET %v: got %d %q, want 200 %q", path, res.StatusCode, res.Header.Get("Content-Type"), contentType)
+		}
+	}
+}
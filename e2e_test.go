@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/database/memory"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestServer boots the full mux against the in-memory store
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	s := &state{
+		db:           memory.New(),
+		keys:         newTestKeySet(),
+		bcryptCost:   bcrypt.MinCost,
+		fetchTimeout: 5 * time.Second,
+	}
+	server := httptest.NewServer(s.routes())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestAPI is newTestServer with access to the state behind it
+func newTestAPI(t *testing.T) (*state, *apiClient) {
+	t.Helper()
+	s := &state{
+		db:         memory.New(),
+		keys:       newTestKeySet(),
+		bcryptCost: bcrypt.MinCost,
+	}
+	server := httptest.NewServer(s.routes())
+	t.Cleanup(server.Close)
+	return s, &apiClient{t: t, baseURL: server.URL}
+}
+
+// newFeedServer serves the RSS and Atom fixtures in testdata, plus a feed that
+// cannot be parsed
+func newFeedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /rss.xml", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/rss.xml")
+	})
+	mux.HandleFunc("GET /atom.xml", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/atom.xml")
+	})
+	mux.HandleFunc("GET /dates.xml", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/dates.xml")
+	})
+	mux.HandleFunc("GET /broken.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("this is not a feed"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+type apiClient struct {
+	t       *testing.T
+	baseURL string
+	token   string
+}
+
+// do sends a JSON request, checks the status code and decodes any JSON response
+func (c *apiClient) do(method, path string, body any, wantStatus int) map[string]any {
+	c.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatalf("marshal request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		c.t.Fatalf("create request: %v", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatalf("%v %v: %v", method, path, err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		c.t.Fatalf("read response body: %v", err)
+	}
+
+	if res.StatusCode != wantStatus {
+		c.t.Fatalf("%v %v: got status %d, want %d (body %s)", method, path, res.StatusCode, wantStatus, data)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+		c.t.Fatalf("%v %v: got content type %q, want application/json", method, path, ct)
+	}
+	decoded := map[string]any{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		c.t.Fatalf("%v %v: response is not a single JSON object: %v (body %s)", method, path, err, data)
+	}
+	return decoded
+}
+
+func requireKeys(t *testing.T, what string, obj map[string]any, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		if _, ok := obj[key]; !ok {
+			t.Errorf("%v: missing key %q in %v", what, key, obj)
+		}
+	}
+}
+
+func requireErrorCode(t *testing.T, res map[string]any, code string) map[string]any {
+	t.Helper()
+	body, ok := res["error"].(map[string]any)
+	if !ok {
+		t.Fatalf("error: got %v, want an object", res["error"])
+	}
+	requireKeys(t, "error", body, "code", "message")
+	if body["code"] != code {
+		t.Errorf("error code: got %v, want %v", body["code"], code)
+	}
+	return body
+}
+
+func postTitles(t *testing.T, res map[string]any) []string {
+	t.Helper()
+	posts, ok := res["posts"].([]any)
+	if !ok {
+		t.Fatalf("posts: got %T, want array", res["posts"])
+	}
+	var titles []string
+	for _, post := range posts {
+		p := post.(map[string]any)
+		requireKeys(t, "post", p, "id", "created_at", "updated_at", "title", "url", "published_at", "feed_id")
+		titles = append(titles, p["title"].(string))
+	}
+	return titles
+}
+
+func TestEndToEnd(t *testing.T) {
+	api := &apiClient{t: t, baseURL: newTestServer(t).URL}
+	feeds := newFeedServer(t)
+
+	// Register and log in
+	user := api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusCreated)
+	requireKeys(t, "user", user, "id", "created_at", "updated_at", "name")
+	if _, ok := user["hashed_password"]; ok {
+		t.Errorf("user response leaks hashed_password")
+	}
+	api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "other-password"}, http.StatusConflict)
+
+	login := api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "hunter22", "expires_in_seconds": 600}, http.StatusOK)
+	requireKeys(t, "login", login, "id", "name", "token")
+	userID := login["id"].(string)
+
+	api.token = login["token"].(string)
+
+	rssURL := feeds.URL + "/rss.xml"
+	feed := api.do(http.MethodPost, "/api/feeds", map[string]any{"title": "RSS", "url": rssURL}, http.StatusCreated)
+	requireKeys(t, "feed", feed, "id", "created_at", "updated_at", "last_fetched_at", "title", "url", "user_id")
+	if feed["user_id"] != userID {
+		t.Errorf("feed user_id: got %v, want %v", feed["user_id"], userID)
+	}
+	api.do(http.MethodPost, "/api/feeds", map[string]any{"title": "Atom", "url": feeds.URL + "/atom.xml"}, http.StatusCreated)
+	api.do(http.MethodGet, "/api/feeds/"+feed["id"].(string), nil, http.StatusOK)
+
+	allFeeds := api.do(http.MethodGet, "/api/feeds", nil, http.StatusOK)
+	listed := allFeeds["feeds"].([]any)
+	if len(listed) != 2 {
+		t.Fatalf("feeds: got %d, want 2", len(listed))
+	}
+	requireKeys(t, "listed feed", listed[0].(map[string]any), "id", "title", "url", "user_id", "user_name", "follower_count")
+
+	following := api.do(http.MethodGet, "/api/follows", nil, http.StatusOK)
+	followed := following["feeds_followed"].([]any)
+	if len(followed) != 2 {
+		t.Fatalf("feeds followed after adding feeds: got %d, want 2", len(followed))
+	}
+	requireKeys(t, "followed feed", followed[0].(map[string]any), "id", "created_at", "updated_at", "user_id", "feed_id", "title", "posted_by")
+
+	// Aggregate both feeds and browse the newest posts first
+	api.do(http.MethodPost, "/api/agg", nil, http.StatusOK)
+	api.do(http.MethodPost, "/api/agg", nil, http.StatusOK)
+
+	posts := api.do(http.MethodGet, "/api/posts?limit=10", nil, http.StatusOK)
+	want := []string{"Atom entry two", "Atom entry one", "Third post", "Second & post", "First post"}
+	if got := postTitles(t, posts); !slices.Equal(got, want) {
+		t.Errorf("posts: got %q, want %q", got, want)
+	}
+	posts = api.do(http.MethodGet, "/api/posts?limit=2&offset=1", nil, http.StatusOK)
+	if got := postTitles(t, posts); !slices.Equal(got, want[1:3]) {
+		t.Errorf("second page of posts: got %q, want %q", got, want[1:3])
+	}
+
+	// Unfollow the RSS feed and its posts disappear
+	api.do(http.MethodDelete, "/api/follows", map[string]any{"url": rssURL}, http.StatusNoContent)
+	posts = api.do(http.MethodGet, "/api/posts?limit=10", nil, http.StatusOK)
+	want = []string{"Atom entry two", "Atom entry one"}
+	if got := postTitles(t, posts); !slices.Equal(got, want) {
+		t.Errorf("posts after unfollow: got %q, want %q", got, want)
+	}
+
+	// Following it again brings them back
+	follow := api.do(http.MethodPost, "/api/follows", map[string]any{"url": rssURL}, http.StatusCreated)
+	requireKeys(t, "follow", follow, "id", "created_at", "updated_at", "user_id", "feed_id")
+	api.do(http.MethodPost, "/api/follows", map[string]any{"url": feeds.URL + "/missing.xml"}, http.StatusNotFound)
+
+	// A feed that can't be parsed fails aggregation with a single error body
+	api.do(http.MethodPost, "/api/feeds", map[string]any{"title": "Broken", "url": feeds.URL + "/broken.xml"}, http.StatusCreated)
+	aggErr := api.do(http.MethodPost, "/api/agg", nil, http.StatusInternalServerError)
+	requireKeys(t, "aggregate error", aggErr, "error", "request_id")
+	requireErrorCode(t, aggErr, "internal_server_error")
+
+	// Unfollowing twice is idempotent, and unknown feeds are reported once
+	api.do(http.MethodDelete, "/api/follows", map[string]any{"url": rssURL}, http.StatusNoContent)
+	api.do(http.MethodDelete, "/api/follows", map[string]any{"url": rssURL}, http.StatusNoContent)
+	unfollowErr := api.do(http.MethodDelete, "/api/follows", map[string]any{"url": feeds.URL + "/missing.xml"}, http.StatusNotFound)
+	requireErrorCode(t, unfollowErr, "not_found")
+
+	// Deleting the user removes their feeds
+	api.do(http.MethodDelete, "/api/users/"+userID, nil, http.StatusNoContent)
+	allFeeds = api.do(http.MethodGet, "/api/feeds", nil, http.StatusOK)
+	if n := len(allFeeds["feeds"].([]any)); n != 0 {
+		t.Errorf("feeds after deleting user: got %d, want 0", n)
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	server := newTestServer(t)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/users/not-a-uuid", nil)
+	req.Header.Set(requestIDHeader, "trace-123")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer res.Body.Close()
+
+	if got := res.Header.Get(requestIDHeader); got != "trace-123" {
+		t.Errorf("response %v: got %q, want trace-123", requestIDHeader, got)
+	}
+	body := map[string]any{}
+	json.NewDecoder(res.Body).Decode(&body)
+	if body["request_id"] != "trace-123" {
+		t.Errorf("error body request_id: got %v, want trace-123", body["request_id"])
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	api := &apiClient{t: t, baseURL: newTestServer(t).URL}
+	api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusCreated)
+
+	// Requests without credentials are unauthorized, not server errors
+	feedBody := map[string]any{"title": "RSS", "url": "https://example.com/rss"}
+	followBody := map[string]any{"url": "https://example.com/rss"}
+	for _, route := range []struct {
+		method, path string
+		body         any
+	}{
+		{http.MethodPost, "/api/feeds", feedBody},
+		{http.MethodGet, "/api/follows", nil},
+		{http.MethodPost, "/api/follows", followBody},
+		{http.MethodDelete, "/api/follows", followBody},
+		{http.MethodGet, "/api/posts", nil},
+		{http.MethodDelete, "/api/users/" + uuid.NewString(), nil},
+	} {
+		res := api.do(route.method, route.path, route.body, http.StatusUnauthorized)
+		requireErrorCode(t, res, "unauthorized")
+	}
+
+	login := api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusOK)
+	api.token = login["token"].(string)
+
+	res := api.do(http.MethodGet, "/api/feeds/not-a-uuid", nil, http.StatusBadRequest)
+	details := requireErrorCode(t, res, "validation_failed")["details"].([]any)
+	if field := details[0].(map[string]any)["field"]; field != "id" {
+		t.Errorf("invalid id detail field: got %v, want id", field)
+	}
+	api.do(http.MethodGet, "/api/feeds/"+uuid.NewString(), nil, http.StatusNotFound)
+	api.do(http.MethodGet, "/api/users/"+uuid.NewString(), nil, http.StatusForbidden)
+
+	res = api.do(http.MethodPost, "/api/feeds", map[string]any{"title": "", "url": "ftp://example.com/rss"}, http.StatusBadRequest)
+	details = requireErrorCode(t, res, "validation_failed")["details"].([]any)
+	if len(details) != 2 {
+		t.Errorf("feed validation details: got %v, want title and url", details)
+	}
+	api.do(http.MethodPost, "/api/feeds", feedBody, http.StatusCreated)
+	api.do(http.MethodPost, "/api/feeds", feedBody, http.StatusConflict)
+	api.do(http.MethodPost, "/api/follows", followBody, http.StatusConflict)
+
+	res = api.do(http.MethodGet, "/api/posts?limit=1000", nil, http.StatusBadRequest)
+	requireErrorCode(t, res, "validation_failed")
+	api.do(http.MethodGet, "/api/posts", nil, http.StatusOK)
+}
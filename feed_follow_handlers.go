@@ -1,13 +1,12 @@
 package main
 
 import (
-	"context"
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/imeltsner/gator-api/internal/auth"
 	"github.com/imeltsner/gator-api/internal/database"
 )
 
@@ -21,33 +20,54 @@ type FeedFollow struct {
 
 func (s *state) handlerFollow(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Url string `json:"url"`
+		Url    string `json:"url"`
+		FeedID string `json:"feed_id"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err := decodeJSON(w, r, &params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to decode params", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
-	authToken, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to parse auth header", err)
+	// Feeds are followed by URL or by ID, but not both
+	v := validator{}
+	var feedID uuid.UUID
+	switch {
+	case params.FeedID != "" && params.Url != "":
+		v.add("feed_id", "must not be set with url")
+	case params.FeedID != "":
+		feedID, err = uuid.Parse(params.FeedID)
+		if err != nil {
+			v.add("feed_id", "must be a UUID")
+		}
+	default:
+		v.feedURL("url", params.Url)
+	}
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(authToken, s.jwtSecret)
+	userID, err := s.authenticate(r, scopeManageFollows)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "unable to validate jwt", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
-	feed, err := s.db.GetFeedByURL(context.Background(), params.Url)
-	if err != nil {
+	var feed database.Feed
+	if feedID != uuid.Nil {
+		feed, err = s.db.GetFeedByID(r.Context(), feedID)
+	} else {
+		feed, err = s.db.GetFeedByURL(r.Context(), params.Url)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
 		respondWithError(w, http.StatusNotFound, "feed not found", err)
 		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get feed", err)
+		return
 	}
 
 	feedFollowParams := database.CreateFeedFollowParams{
@@ -58,8 +78,11 @@ func (s *state) handlerFollow(w http.ResponseWriter, r *http.Request) {
 		FeedID:    feed.ID,
 	}
 
-	feedFollow, err := s.db.CreateFeedFollow(context.Background(), feedFollowParams)
-	if err != nil {
+	feedFollow, err := s.db.CreateFeedFollow(r.Context(), feedFollowParams)
+	if database.IsUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "feed already followed", err)
+		return
+	} else if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "unable to create feed follow entry", err)
 		return
 	}
@@ -74,28 +97,22 @@ func (s *state) handlerFollow(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *state) handlerFollowing(w http.ResponseWriter, r *http.Request) {
-	authToken, err := auth.GetBearerToken(r.Header)
+	userID, err := s.authenticate(r, scopeManageFollows)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to parse auth header", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(authToken, s.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "unable to validate jwt", err)
-		return
-	}
-
-	feeds, err := s.db.GetFeedFollowsForUser(context.Background(), userID)
+	feeds, err := s.db.GetFeedFollowsForUser(r.Context(), userID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "unable to get follows", err)
 		return
 	}
 
 	type feedFollowForUser struct {
-		feedFollow FeedFollow
-		Title      string `json:"title"`
-		PostedBy   string `json:"posted_by"`
+		FeedFollow
+		Title    string `json:"title"`
+		PostedBy string `json:"posted_by"`
 	}
 	type response struct {
 		FeedsFollowed []feedFollowForUser `json:"feeds_followed"`
@@ -103,7 +120,7 @@ func (s *state) handlerFollowing(w http.ResponseWriter, r *http.Request) {
 	feedsFollowed := make([]feedFollowForUser, len(feeds))
 	for i, feed := range feeds {
 		feedsFollowed[i] = feedFollowForUser{
-			feedFollow: FeedFollow{
+			FeedFollow: FeedFollow{
 				ID:        feed.ID,
 				CreatedAt: feed.CreatedAt,
 				UpdatedAt: feed.UpdatedAt,
@@ -123,39 +140,43 @@ func (s *state) handlerUnfollow(w http.ResponseWriter, r *http.Request) {
 		Url string `json:"url"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err := decodeJSON(w, r, &params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to decode params", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
-	authToken, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to parse auth header", err)
+	v := validator{}
+	v.feedURL("url", params.Url)
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(authToken, s.jwtSecret)
+	userID, err := s.authenticate(r, scopeManageFollows)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "unable to validate jwt", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
-	feed, err := s.db.GetFeedByURL(context.Background(), params.Url)
-	if err != nil {
+	feed, err := s.db.GetFeedByURL(r.Context(), params.Url)
+	if errors.Is(err, sql.ErrNoRows) {
 		respondWithError(w, http.StatusNotFound, "feed not found", err)
 		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get feed", err)
+		return
 	}
 
 	deleteParams := database.DeleteFeedFollowParams{
 		UserID: userID,
 		FeedID: feed.ID,
 	}
-	err = s.db.DeleteFeedFollow(context.Background(), deleteParams)
+	err = s.db.DeleteFeedFollow(r.Context(), deleteParams)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "unable to unfollow", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to unfollow", err)
+		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -1,31 +1,73 @@
 package main
 
 import (
-	"context"
-	"encoding/json"
-	"log"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/imeltsner/gator-api/internal/auth"
 	"github.com/imeltsner/gator-api/internal/database"
 )
 
 type Feed struct {
-	ID            uuid.UUID `json:"id"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	LastFetchedAt time.Time `json:"last_fetched_at"`
-	Title         string    `json:"title"`
-	Url           string    `json:"url"`
-	UserID        uuid.UUID `json:"user_id"`
+	ID                    uuid.UUID `json:"id"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+	LastFetchedAt         time.Time `json:"last_fetched_at"`
+	LastSuccessfulFetchAt time.Time `json:"last_successful_fetch_at"`
+	Title                 string    `json:"title"`
+	Url                   string    `json:"url"`
+	UserID                uuid.UUID `json:"user_id"`
 }
 
+func newFeed(feed database.Feed) Feed {
+	return Feed{
+		ID:                    feed.ID,
+		CreatedAt:             feed.CreatedAt,
+		UpdatedAt:             feed.UpdatedAt,
+		LastFetchedAt:         feed.LastFetchedAt.Time,
+		LastSuccessfulFetchAt: feed.LastSuccessfulFetchAt.Time,
+		Title:                 feed.Title,
+		Url:                   feed.Url,
+		UserID:                feed.UserID,
+	}
+}
+
+// FeedListing is a feed as listed by GET /api/feeds, with its creator's name and
+// tags and how active it has been in the past recentWindow
+type FeedListing struct {
+	Feed
+	UserName            string   `json:"user_name"`
+	Tags                []string `json:"tags"`
+	FollowerCount       int64    `json:"follower_count"`
+	RecentFollowerCount int64    `json:"recent_follower_count"`
+	RecentPostCount     int64    `json:"recent_post_count"`
+}
+
+// Orders for GET /api/feeds
+const (
+	feedSortNewest    = "newest"
+	feedSortFollowers = "followers"
+	feedSortUpdated   = "updated"
+	// the most followers gained in the past recentWindow, then the most followers
+	feedSortPopular = "popular"
+)
+
+// recentWindow is the period GET /api/feeds counts recent posts and followers over
+const recentWindow = 7 * 24 * time.Hour
+
+const maxFeedSearchLength = 200
+
 func (s *state) handlerAggregate(w http.ResponseWriter, r *http.Request) {
-	err := s.scrapeFeeds()
+	err := s.scrapeFeeds(r.Context())
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "unable to scrape feeds", err)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -37,23 +79,24 @@ func (s *state) handlerAddFeed(w http.ResponseWriter, r *http.Request) {
 		Url   string `json:"url"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err := decodeJSON(w, r, &params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to decode params", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
-	authToken, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to parse auth header", err)
+	v := validator{}
+	v.required("title", params.Title)
+	v.feedURL("url", params.Url)
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
 		return
 	}
 
-	id, err := auth.ValidateJWT(authToken, s.jwtSecret)
+	id, err := s.authenticate(r, scopeManageFeeds)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "unable to validate jwt", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
@@ -67,7 +110,10 @@ func (s *state) handlerAddFeed(w http.ResponseWriter, r *http.Request) {
 	}
 
 	feed, err := s.db.CreateFeed(r.Context(), feedParams)
-	if err != nil {
+	if database.IsUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "feed already exists", err)
+		return
+	} else if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "unable to create feed", err)
 		return
 	}
@@ -86,77 +132,188 @@ func (s *state) handlerAddFeed(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Feed created successfully with name %v at url %v\n", feed.Title, feed.Url)
-	respondWithJSON(w, http.StatusCreated, Feed{
-		ID:            feed.ID,
-		CreatedAt:     feed.CreatedAt,
-		UpdatedAt:     feed.UpdatedAt,
-		LastFetchedAt: feed.LastFetchedAt.Time,
-		Title:         feed.Title,
-		Url:           feed.Url,
-		UserID:        feed.UserID,
-	})
+	slog.InfoContext(r.Context(), "feed created", slog.String("feed_id", feed.ID.String()), slog.String("title", feed.Title), slog.String("url", feed.Url))
+	respondWithJSON(w, http.StatusCreated, newFeed(feed))
 }
 
 func (s *state) handlerGetFeed(w http.ResponseWriter, r *http.Request) {
-	idString := r.PathValue("id")
-	feedID, err := uuid.Parse(idString)
+	feedID, err := pathID(r, "id")
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to parse feed id", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
 	feed, err := s.db.GetFeedByID(r.Context(), feedID)
-	if err != nil {
+	if errors.Is(err, sql.ErrNoRows) {
 		respondWithError(w, http.StatusNotFound, "feed not found", err)
 		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get feed", err)
+		return
 	}
 
-	respondWithJSON(w, http.StatusOK, Feed{
-		ID:            feed.ID,
-		CreatedAt:     feed.CreatedAt,
-		UpdatedAt:     feed.UpdatedAt,
-		LastFetchedAt: feed.LastFetchedAt.Time,
-		Title:         feed.Title,
-		Url:           feed.Url,
-		UserID:        feed.UserID,
-	})
+	respondWithJSON(w, http.StatusOK, newFeed(feed))
 }
 
 func (s *state) handlerGetFeeds(w http.ResponseWriter, r *http.Request) {
-	feeds, err := s.db.GetFeeds(context.Background())
+	query := r.URL.Query()
+	v := validator{}
+	limit := v.queryInt(query, "limit", defaultFeedsLimit)
+	v.between("limit", limit, 1, maxFeedsLimit)
+	offset := v.queryInt(query, "offset", 0)
+	if offset < 0 {
+		v.add("offset", "must not be negative")
+	}
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = feedSortNewest
+	}
+	v.oneOf("sort", sort, feedSortNewest, feedSortFollowers, feedSortUpdated, feedSortPopular)
+	search := strings.TrimSpace(query.Get("q"))
+	v.maxLength("q", search, maxFeedSearchLength)
+	tag := query.Get("tag")
+	if tag != "" {
+		tag = v.feedTag("tag", tag)
+	}
+	if err := v.queryErr(); err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	rows, err := s.db.ListFeeds(r.Context(), database.ListFeedsParams{
+		Since:  time.Now().UTC().Add(-recentWindow),
+		Search: search,
+		Tag:    tag,
+		Sort:   sort,
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "unable to get feeds", err)
 		return
 	}
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	feedTags, err := s.db.GetTagsForFeeds(r.Context(), ids)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get feed tags", err)
+		return
+	}
+	tags := map[uuid.UUID][]string{}
+	for _, feedTag := range feedTags {
+		tags[feedTag.FeedID] = append(tags[feedTag.FeedID], feedTag.Tag)
+	}
 
 	type response struct {
-		Feeds []Feed   `json:"feeds"`
-		Users []string `json:"users"`
+		Feeds []FeedListing `json:"feeds"`
 	}
-	allFeeds := make([]Feed, len(feeds))
-	userNames := make([]string, len(feeds))
-
-	for i, feed := range feeds {
-		user, err := s.db.GetUserNameByID(context.Background(), feed.UserID)
-		if err != nil {
-			respondWithError(w, http.StatusNotFound, "user not found", err)
-			return
+	feeds := make([]FeedListing, len(rows))
+	for i, row := range rows {
+		feeds[i] = FeedListing{
+			Feed: newFeed(database.Feed{
+				ID:                    row.ID,
+				CreatedAt:             row.CreatedAt,
+				UpdatedAt:             row.UpdatedAt,
+				Title:                 row.Title,
+				Url:                   row.Url,
+				UserID:                row.UserID,
+				LastFetchedAt:         row.LastFetchedAt,
+				Seq:                   row.Seq,
+				LastSuccessfulFetchAt: row.LastSuccessfulFetchAt,
+			}),
+			UserName:            row.UserName,
+			Tags:                append([]string{}, tags[row.ID]...),
+			FollowerCount:       row.FollowerCount,
+			RecentFollowerCount: row.RecentFollowerCount,
+			RecentPostCount:     row.RecentPostCount,
 		}
-		allFeeds[i] = Feed{
-			ID:            feed.ID,
-			CreatedAt:     feed.CreatedAt,
-			UpdatedAt:     feed.UpdatedAt,
-			LastFetchedAt: feed.LastFetchedAt.Time,
-			Title:         feed.Title,
-			Url:           feed.Url,
-			UserID:        feed.UserID,
+	}
+
+	respondWithJSON(w, http.StatusOK, response{Feeds: feeds})
+}
+
+func (s *state) handlerSetFeedTags(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Tags []string `json:"tags"`
+	}
+
+	feedID, err := pathID(r, "id")
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+	params := parameters{}
+	err = decodeJSON(w, r, &params)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	v := validator{}
+	tags := []string{}
+	for _, tag := range params.Tags {
+		tag = v.feedTag("tags", tag)
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
 		}
-		userNames[i] = user
+	}
+	if len(tags) > maxFeedTags {
+		v.add("tags", fmt.Sprintf("must have at most %d tags", maxFeedTags))
+	}
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
+		return
 	}
 
-	respondWithJSON(w, http.StatusOK, response{
-		Feeds: allFeeds,
-		Users: userNames,
-	})
+	userID, err := s.authenticate(r, scopeManageFeeds)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	feed, err := s.db.GetFeedByID(r.Context(), feedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "feed not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get feed", err)
+		return
+	}
+	if feed.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "only the user who added a feed can tag it", nil)
+		return
+	}
+
+	err = s.db.SetFeedTags(r.Context(), database.SetFeedTagsParams{FeedID: feedID, Tags: tags})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to set feed tags", err)
+		return
+	}
+
+	slices.Sort(tags)
+	slog.InfoContext(r.Context(), "feed tagged", slog.String("feed_id", feedID.String()), slog.Any("tags", tags))
+	respondWithJSON(w, http.StatusOK, map[string][]string{"tags": tags})
+}
+
+func (s *state) handlerGetTags(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.ListTags(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get tags", err)
+		return
+	}
+
+	type tag struct {
+		Tag       string `json:"tag"`
+		FeedCount int64  `json:"feed_count"`
+	}
+	type response struct {
+		Tags []tag `json:"tags"`
+	}
+	tags := make([]tag, len(rows))
+	for i, row := range rows {
+		tags[i] = tag{Tag: row.Tag, FeedCount: row.FeedCount}
+	}
+	respondWithJSON(w, http.StatusOK, response{Tags: tags})
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const zeroTime = "0001-01-01T00:00:00Z"
+
+func listedTitles(t *testing.T, res map[string]any) []string {
+	t.Helper()
+	var titles []string
+	for _, feed := range res["feeds"].([]any) {
+		titles = append(titles, feed.(map[string]any)["title"].(string))
+	}
+	return titles
+}
+
+func TestHandlerGetFeeds(t *testing.T) {
+	_, api := newTestAPI(t)
+
+	// alice adds three feeds and bob follows the first
+	api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusCreated)
+	api.token = api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusOK)["token"].(string)
+	for _, feed := range []struct{ title, url string }{
+		{"Go Blog", "https://go.dev/blog/feed.atom"},
+		{"Rust Blog", "https://blog.rust-lang.org/feed.xml"},
+		{"Changelog", "https://changelog.com/feed"},
+	} {
+		api.do(http.MethodPost, "/api/feeds", map[string]any{"title": feed.title, "url": feed.url}, http.StatusCreated)
+	}
+	api.do(http.MethodPost, "/api/users", map[string]any{"name": "bob", "password": "hunter22"}, http.StatusCreated)
+	api.token = api.do(http.MethodPost, "/api/login", map[string]any{"name": "bob", "password": "hunter22"}, http.StatusOK)["token"].(string)
+	api.do(http.MethodPost, "/api/follows", map[string]any{"url": "https://go.dev/blog/feed.atom"}, http.StatusCreated)
+
+	res := api.do(http.MethodGet, "/api/feeds", nil, http.StatusOK)
+	if got, want := listedTitles(t, res), []string{"Changelog", "Rust Blog", "Go Blog"}; !slices.Equal(got, want) {
+		t.Errorf("newest: got %q, want %q", got, want)
+	}
+	goBlog := res["feeds"].([]any)[2].(map[string]any)
+	if goBlog["user_name"] != "alice" || goBlog["follower_count"] != 2.0 {
+		t.Errorf("listed feed: got %v, want alice's feed with 2 followers", goBlog)
+	}
+
+	res = api.do(http.MethodGet, "/api/feeds?sort=followers&limit=1", nil, http.StatusOK)
+	if got, want := listedTitles(t, res), []string{"Go Blog"}; !slices.Equal(got, want) {
+		t.Errorf("most followed: got %q, want %q", got, want)
+	}
+	res = api.do(http.MethodGet, "/api/feeds?limit=2&offset=2", nil, http.StatusOK)
+	if got, want := listedTitles(t, res), []string{"Go Blog"}; !slices.Equal(got, want) {
+		t.Errorf("second page: got %q, want %q", got, want)
+	}
+	res = api.do(http.MethodGet, "/api/feeds?q=BLOG", nil, http.StatusOK)
+	if got, want := listedTitles(t, res), []string{"Rust Blog", "Go Blog"}; !slices.Equal(got, want) {
+		t.Errorf("search: got %q, want %q", got, want)
+	}
+	res = api.do(http.MethodGet, "/api/feeds?q=changelog.com", nil, http.StatusOK)
+	if got, want := listedTitles(t, res), []string{"Changelog"}; !slices.Equal(got, want) {
+		t.Errorf("search by url: got %q, want %q", got, want)
+	}
+
+	res = api.do(http.MethodGet, "/api/feeds?limit=0&offset=-1&sort=oldest", nil, http.StatusBadRequest)
+	details := requireErrorCode(t, res, "validation_failed")["details"].([]any)
+	if len(details) != 3 {
+		t.Errorf("invalid query: got %v, want errors for limit, offset and sort", details)
+	}
+	res = api.do(http.MethodGet, "/api/feeds?limit=ten", nil, http.StatusBadRequest)
+	requireErrorCode(t, res, "validation_failed")
+}
+
+func TestFeedDirectory(t *testing.T) {
+	s, api := newTestAPI(t)
+	s.fetchTimeout = 5 * time.Second
+	feeds := newFeedServer(t)
+
+	api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusCreated)
+	alice := api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusOK)["token"].(string)
+	api.token = alice
+	rss := api.do(http.MethodPost, "/api/feeds", map[string]any{"title": "RSS", "url": feeds.URL + "/rss.xml"}, http.StatusCreated)
+	atom := api.do(http.MethodPost, "/api/feeds", map[string]any{"title": "Atom", "url": feeds.URL + "/atom.xml"}, http.StatusCreated)
+	api.do(http.MethodPost, "/api/feeds", map[string]any{"title": "Broken", "url": feeds.URL + "/broken.xml"}, http.StatusCreated)
+
+	// Creators tag their feeds
+	res := api.do(http.MethodPut, "/api/feeds/"+rss["id"].(string)+"/tags", map[string]any{"tags": []string{" News", "go", "news"}}, http.StatusOK)
+	if got := res["tags"].([]any); len(got) != 2 || got[0] != "go" || got[1] != "news" {
+		t.Errorf("set tags: got %v, want [go news]", got)
+	}
+	api.do(http.MethodPut, "/api/feeds/"+atom["id"].(string)+"/tags", map[string]any{"tags": []string{"go"}}, http.StatusOK)
+	res = api.do(http.MethodPut, "/api/feeds/"+atom["id"].(string)+"/tags", map[string]any{"tags": []string{"no spaces", ""}}, http.StatusBadRequest)
+	if details := requireErrorCode(t, res, "validation_failed")["details"].([]any); len(details) != 2 {
+		t.Errorf("invalid tags: got %v, want 2 errors", details)
+	}
+	api.do(http.MethodPut, "/api/feeds/"+uuid.NewString()+"/tags", map[string]any{"tags": []string{"go"}}, http.StatusNotFound)
+
+	tags := api.do(http.MethodGet, "/api/tags", nil, http.StatusOK)["tags"].([]any)
+	if len(tags) != 2 || tags[0].(map[string]any)["tag"] != "go" || tags[0].(map[string]any)["feed_count"] != 2.0 {
+		t.Errorf("tags: got %v, want go on 2 feeds then news", tags)
+	}
+
+	// Only successful fetches are recorded as such
+	for range 3 {
+		s.scrapeFeeds(context.Background())
+	}
+
+	// bob finds the Atom feed in the directory and follows it by ID
+	api.token = ""
+	api.do(http.MethodPost, "/api/users", map[string]any{"name": "bob", "password": "hunter22"}, http.StatusCreated)
+	api.token = api.do(http.MethodPost, "/api/login", map[string]any{"name": "bob", "password": "hunter22"}, http.StatusOK)["token"].(string)
+	api.do(http.MethodPut, "/api/feeds/"+atom["id"].(string)+"/tags", map[string]any{"tags": []string{"mine"}}, http.StatusForbidden)
+	follow := api.do(http.MethodPost, "/api/follows", map[string]any{"feed_id": atom["id"]}, http.StatusCreated)
+	if follow["feed_id"] != atom["id"] {
+		t.Errorf("follow by id: got %v, want feed %v", follow, atom["id"])
+	}
+	api.do(http.MethodPost, "/api/follows", map[string]any{"feed_id": atom["id"]}, http.StatusConflict)
+	api.do(http.MethodPost, "/api/follows", map[string]any{"feed_id": uuid.NewString()}, http.StatusNotFound)
+	res = api.do(http.MethodPost, "/api/follows", map[string]any{"feed_id": "nope"}, http.StatusBadRequest)
+	requireErrorCode(t, res, "validation_failed")
+	res = api.do(http.MethodPost, "/api/follows", map[string]any{"feed_id": atom["id"], "url": atom["url"]}, http.StatusBadRequest)
+	requireErrorCode(t, res, "validation_failed")
+
+	res = api.do(http.MethodGet, "/api/feeds?sort=popular", nil, http.StatusOK)
+	if got, want := listedTitles(t, res), []string{"Atom", "Broken", "RSS"}; !slices.Equal(got, want) {
+		t.Errorf("popular: got %q, want %q", got, want)
+	}
+	byTitle := map[string]map[string]any{}
+	for _, feed := range res["feeds"].([]any) {
+		byTitle[feed.(map[string]any)["title"].(string)] = feed.(map[string]any)
+	}
+	if feed := byTitle["Atom"]; feed["recent_follower_count"] != 2.0 || feed["recent_post_count"] != 2.0 || feed["tags"].([]any)[0] != "go" {
+		t.Errorf("Atom listing: got %v, want 2 recent followers and posts, tagged go", feed)
+	}
+	if feed := byTitle["RSS"]; feed["recent_post_count"] != 3.0 || feed["last_successful_fetch_at"] == zeroTime {
+		t.Errorf("RSS listing: got %v, want 3 recent posts and a successful fetch", feed)
+	}
+	if feed := byTitle["Broken"]; feed["last_fetched_at"] == zeroTime || feed["last_successful_fetch_at"] != zeroTime || len(feed["tags"].([]any)) != 0 {
+		t.Errorf("Broken listing: got %v, want a failed fetch and no tags", feed)
+	}
+
+	res = api.do(http.MethodGet, "/api/feeds?tag=NEWS", nil, http.StatusOK)
+	if got, want := listedTitles(t, res), []string{"RSS"}; !slices.Equal(got, want) {
+		t.Errorf("tagged news: got %q, want %q", got, want)
+	}
+	api.do(http.MethodGet, "/api/feeds?tag=a+b", nil, http.StatusBadRequest)
+}
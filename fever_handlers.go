@@ -0,0 +1,454 @@
+package main
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/database"
+)
+
+const (
+	feverAPIVersion = 3
+	// feverItemsLimit is how many items one request returns, as in Fever
+	feverItemsLimit = 50
+	// Every followed feed is in the one group, since gator has no folders. Group 0
+	// is Fever's Kindling, which is also every feed.
+	feverGroupID    = 1
+	feverGroupTitle = "All"
+)
+
+type FeverGroup struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+type FeverFeedsGroup struct {
+	GroupID int64  `json:"group_id"`
+	FeedIDs string `json:"feed_ids"`
+}
+
+type FeverFeed struct {
+	ID                int64  `json:"id"`
+	FaviconID         int64  `json:"favicon_id"`
+	Title             string `json:"title"`
+	Url               string `json:"url"`
+	SiteUrl           string `json:"site_url"`
+	IsSpark           int    `json:"is_spark"`
+	LastUpdatedOnTime int64  `json:"last_updated_on_time"`
+}
+
+type FeverItem struct {
+	ID            int64  `json:"id"`
+	FeedID        int64  `json:"feed_id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	Html          string `json:"html"`
+	Url           string `json:"url"`
+	IsSaved       int    `json:"is_saved"`
+	IsRead        int    `json:"is_read"`
+	CreatedOnTime int64  `json:"created_on_time"`
+}
+
+// feverAPIKey is the key Fever clients send for a user: the hex MD5 of
+// "name:password"
+func feverAPIKey(name, password string) string {
+	sum := md5.Sum([]byte(name + ":" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+// handlerFever serves the Fever API. The query string names what to return, e.g.
+// ?api&items&since_id=10, and the form carries the api_key along with any mark
+// action. An unknown key gets auth 0 rather than an error, as Fever clients expect.
+func (s *state) handlerFever(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+	err := r.ParseForm()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "unable to parse form", err)
+		return
+	}
+
+	res := map[string]any{"api_version": feverAPIVersion, "auth": 0}
+	userID, err := s.db.GetUserIDByFeverAPIKey(r.Context(), strings.ToLower(r.Form.Get("api_key")))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithJSON(w, http.StatusOK, res)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get fever api key", err)
+		return
+	}
+	res["auth"] = 1
+
+	marked, err := s.feverMark(r, userID)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	feeds, err := s.db.GetFollowedFeeds(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get feeds", err)
+		return
+	}
+	var lastRefreshed int64
+	for _, feed := range feeds {
+		if feed.LastFetchedAt.Valid {
+			lastRefreshed = max(lastRefreshed, feed.LastFetchedAt.Time.Unix())
+		}
+	}
+	res["last_refreshed_on_time"] = lastRefreshed
+
+	has := func(flag string) bool {
+		_, ok := r.Form[flag]
+		return ok
+	}
+	if has("groups") || has("feeds") {
+		res["feeds_groups"] = []FeverFeedsGroup{{GroupID: feverGroupID, FeedIDs: feverFeedIDs(feeds)}}
+	}
+	if has("groups") {
+		res["groups"] = []FeverGroup{{ID: feverGroupID, Title: feverGroupTitle}}
+	}
+	if has("feeds") {
+		feverFeeds := make([]FeverFeed, len(feeds))
+		for i, feed := range feeds {
+			feverFeeds[i] = newFeverFeed(feed)
+		}
+		res["feeds"] = feverFeeds
+	}
+	if has("favicons") {
+		res["favicons"] = []any{}
+	}
+	if has("links") {
+		res["links"] = []any{}
+	}
+
+	if has("items") {
+		items, err := s.feverItems(r, userID)
+		if err != nil {
+			respondWithRequestError(w, err)
+			return
+		}
+		total, err := s.db.CountUserItems(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "unable to count items", err)
+			return
+		}
+		res["items"] = items
+		res["total_items"] = total
+	}
+
+	// Marking an item returns the list it changed
+	if has("unread_item_ids") || marked == "read" || marked == "unread" {
+		seqs, err := s.db.GetUnreadItemSeqs(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "unable to get unread items", err)
+			return
+		}
+		res["unread_item_ids"] = joinSeqs(seqs)
+	}
+	if has("saved_item_ids") || marked == "saved" || marked == "unsaved" {
+		seqs, err := s.db.GetSavedItemSeqs(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "unable to get saved items", err)
+			return
+		}
+		res["saved_item_ids"] = joinSeqs(seqs)
+	}
+
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// feverMark applies the request's mark action, if any, and returns what items
+// were marked as
+func (s *state) feverMark(r *http.Request, userID uuid.UUID) (string, error) {
+	mark, as := r.Form.Get("mark"), r.Form.Get("as")
+	if mark == "" {
+		return "", nil
+	}
+
+	v := validator{}
+	id := formInt(&v, r, "id")
+	if err := v.err(); err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+
+	switch mark {
+	case "item":
+		var err error
+		switch as {
+		case "read", "unread":
+			_, err = s.db.SetItemRead(r.Context(), database.SetItemReadParams{
+				ReadAt: sql.NullTime{Time: now, Valid: as == "read"},
+				UserID: userID,
+				Seq:    id,
+			})
+		case "saved", "unsaved":
+			_, err = s.db.SetItemSaved(r.Context(), database.SetItemSavedParams{
+				SavedAt: sql.NullTime{Time: now, Valid: as == "saved"},
+				UserID:  userID,
+				Seq:     id,
+			})
+		default:
+			return "", &requestError{status: http.StatusBadRequest, code: codeValidation, message: "as must be read, unread, saved or unsaved"}
+		}
+		if err != nil {
+			return "", &requestError{status: http.StatusInternalServerError, message: "unable to mark item", err: err}
+		}
+		return as, nil
+
+	case "feed", "group":
+		if as != "read" {
+			return "", &requestError{status: http.StatusBadRequest, code: codeValidation, message: "feeds and groups can only be marked as read"}
+		}
+		before := formInt(&v, r, "before")
+		if err := v.err(); err != nil {
+			return "", err
+		}
+		feedSeq := id
+		if mark == "group" {
+			if id != 0 && id != feverGroupID {
+				// Sparks and unknown groups have no feeds
+				return as, nil
+			}
+			feedSeq = 0
+		} else if id <= 0 {
+			return as, nil
+		}
+		err := s.db.MarkItemsRead(r.Context(), database.MarkItemsReadParams{
+			ReadAt:        now,
+			UserID:        userID,
+			FeedSeq:       feedSeq,
+			CreatedBefore: time.Unix(before, 0).UTC(),
+		})
+		if err != nil {
+			return "", &requestError{status: http.StatusInternalServerError, message: "unable to mark items read", err: err}
+		}
+		return as, nil
+
+	default:
+		return "", &requestError{status: http.StatusBadRequest, code: codeValidation, message: "mark must be item, feed or group"}
+	}
+}
+
+// feverItems returns the items named by with_ids, those before max_id newest
+// first, or those after since_id oldest first
+func (s *state) feverItems(r *http.Request, userID uuid.UUID) ([]FeverItem, error) {
+	v := validator{}
+	items := []FeverItem{}
+
+	if withIDs := r.Form.Get("with_ids"); withIDs != "" {
+		ids := strings.Split(withIDs, ",")
+		if len(ids) > feverItemsLimit {
+			v.add("with_ids", "must list at most "+strconv.Itoa(feverItemsLimit)+" ids")
+			return nil, v.err()
+		}
+		for _, id := range ids {
+			seq, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+			if err != nil {
+				v.add("with_ids", "must be a comma separated list of integers")
+				return nil, v.err()
+			}
+			item, err := s.db.GetUserItem(r.Context(), database.GetUserItemParams{UserID: userID, Seq: seq})
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			} else if err != nil {
+				return nil, &requestError{status: http.StatusInternalServerError, message: "unable to get items", err: err}
+			}
+			items = append(items, newFeverItem(database.GetUserItemsRow(item)))
+		}
+		return items, nil
+	}
+
+	params := database.GetUserItemsParams{UserID: userID, Limit: feverItemsLimit}
+	if r.Form.Has("max_id") {
+		// max_id=0 asks for the newest items, like no upper bound
+		params.BeforeSeq = max(formInt(&v, r, "max_id"), 0)
+		params.Descending = true
+	} else if r.Form.Has("since_id") {
+		params.AfterSeq = formInt(&v, r, "since_id")
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.GetUserItems(r.Context(), params)
+	if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to get items", err: err}
+	}
+	for _, row := range rows {
+		items = append(items, newFeverItem(row))
+	}
+	return items, nil
+}
+
+// formInt parses the named form value as an integer, recording an error in v
+// when it is missing or malformed
+func formInt(v *validator, r *http.Request, name string) int64 {
+	n, err := strconv.ParseInt(r.Form.Get(name), 10, 64)
+	if err != nil {
+		v.add(name, "must be an integer")
+	}
+	return n
+}
+
+func newFeverFeed(feed database.Feed) FeverFeed {
+	feverFeed := FeverFeed{
+		ID:      feed.Seq,
+		Title:   feed.Title,
+		Url:     feed.Url,
+		SiteUrl: siteURL(feed.Url),
+	}
+	if feed.LastFetchedAt.Valid {
+		feverFeed.LastUpdatedOnTime = feed.LastFetchedAt.Time.Unix()
+	}
+	return feverFeed
+}
+
+// siteURL guesses the address of the site a feed belongs to from the feed's URL
+func siteURL(feedURL string) string {
+	u, err := url.Parse(feedURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func newFeverItem(row database.GetUserItemsRow) FeverItem {
+	item := FeverItem{
+		ID:            row.Seq,
+		FeedID:        row.FeedSeq,
+		Title:         row.Title,
+		Html:          row.Description.String,
+		Url:           row.Url,
+		CreatedOnTime: row.CreatedAt.Unix(),
+	}
+	if row.PublishedAt.Valid {
+		item.CreatedOnTime = row.PublishedAt.Time.Unix()
+	}
+	if row.ReadAt.Valid {
+		item.IsRead = 1
+	}
+	if row.SavedAt.Valid {
+		item.IsSaved = 1
+	}
+	return item
+}
+
+func feverFeedIDs(feeds []database.Feed) string {
+	seqs := make([]int64, len(feeds))
+	for i, feed := range feeds {
+		seqs[i] = feed.Seq
+	}
+	return joinSeqs(seqs)
+}
+
+func joinSeqs(seqs []int64) string {
+	ids := make([]string, len(seqs))
+	for i, seq := range seqs {
+		ids[i] = strconv.FormatInt(seq, 10)
+	}
+	return strings.Join(ids, ",")
+}
+
+// handlerSetFeverPassword sets the password Fever clients log in with. Fever
+// sends an unsalted MD5 of the name and password, so it is a separate password
+// from the account's and stored in that form.
+func (s *state) handlerSetFeverPassword(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	authID, err := s.authenticate(r, "")
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	if authID != id {
+		respondWithError(w, http.StatusForbidden, "mismatched id", nil)
+		return
+	}
+
+	params := parameters{}
+	err = decodeJSON(w, r, &params)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	user, err := s.db.GetUserByID(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "user not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get user", err)
+		return
+	}
+
+	v := validator{}
+	v.password("password", params.Password, user.Name)
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	err = s.db.SetFeverAPIKey(r.Context(), database.SetFeverAPIKeyParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now().UTC(),
+		ApiKey:    feverAPIKey(user.Name, params.Password),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to set fever password", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "fever password set", slog.String("user_id", user.ID.String()))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *state) handlerDeleteFeverPassword(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	authID, err := s.authenticate(r, "")
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	if authID != id {
+		respondWithError(w, http.StatusForbidden, "mismatched id", nil)
+		return
+	}
+
+	deleted, err := s.db.DeleteFeverAPIKey(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to delete fever password", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "fever password not set", nil)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "fever password removed", slog.String("user_id", id.String()))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fever posts form to the Fever endpoint with the query flags in query
+func fever(t *testing.T, baseURL, query string, form url.Values) map[string]any {
+	t.Helper()
+	res, err := http.PostForm(baseURL+"/fever/?api&"+query, form)
+	if err != nil {
+		t.Fatalf("fever %v: %v", query, err)
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("fever %v: got status %d (body %s)", query, res.StatusCode, data)
+	}
+	body := map[string]any{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("fever %v: response is not JSON: %s", query, data)
+	}
+	return body
+}
+
+func feverItemIDs(t *testing.T, res map[string]any) []string {
+	t.Helper()
+	items, ok := res["items"].([]any)
+	if !ok {
+		t.Fatalf("items: got %T, want array", res["items"])
+	}
+	var ids []string
+	for _, item := range items {
+		i := item.(map[string]any)
+		requireKeys(t, "item", i, "id", "feed_id", "title", "author", "html", "url", "is_saved", "is_read", "created_on_time")
+		ids = append(ids, strconv.Itoa(int(i["id"].(float64))))
+	}
+	return ids
+}
+
+func TestFever(t *testing.T) {
+	api := &apiClient{t: t, baseURL: newTestServer(t).URL}
+	feeds := newFeedServer(t)
+
+	user := api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusCreated)
+	userID := user["id"].(string)
+	login := api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusOK)
+	api.token = login["token"].(string)
+	api.do(http.MethodPost, "/api/feeds", map[string]any{"title": "RSS", "url": feeds.URL + "/rss.xml"}, http.StatusCreated)
+	api.do(http.MethodPost, "/api/feeds", map[string]any{"title": "Atom", "url": feeds.URL + "/atom.xml"}, http.StatusCreated)
+	api.do(http.MethodPost, "/api/agg", nil, http.StatusOK)
+	api.do(http.MethodPost, "/api/agg", nil, http.StatusOK)
+
+	key := url.Values{"api_key": {feverAPIKey("alice", "fever-pass")}}
+	if res := fever(t, api.baseURL, "", key); res["auth"] != float64(0) || res["api_version"] != float64(3) {
+		t.Errorf("before the fever password is set: got %v, want auth 0", res)
+	}
+
+	api.do(http.MethodPut, "/api/users/"+userID+"/fever", map[string]any{"password": "short"}, http.StatusBadRequest)
+	api.do(http.MethodPut, "/api/users/"+userID+"/fever", map[string]any{"password": "fever-pass"}, http.StatusNoContent)
+	if res := fever(t, api.baseURL, "", url.Values{"api_key": {feverAPIKey("alice", "hunter22")}}); res["auth"] != float64(0) {
+		t.Errorf("account password as the fever password: got auth %v, want 0", res["auth"])
+	}
+	res := fever(t, api.baseURL, "groups&feeds&favicons&links", key)
+	requireKeys(t, "fever", res, "api_version", "auth", "last_refreshed_on_time", "groups", "feeds_groups", "feeds", "favicons", "links")
+	if res["auth"] != float64(1) || res["last_refreshed_on_time"] == float64(0) {
+		t.Errorf("fever auth: got %v", res)
+	}
+	feverFeeds := res["feeds"].([]any)
+	if len(feverFeeds) != 2 {
+		t.Fatalf("fever feeds: got %d, want 2", len(feverFeeds))
+	}
+	rss := feverFeeds[0].(map[string]any)
+	requireKeys(t, "feed", rss, "id", "favicon_id", "title", "url", "site_url", "is_spark", "last_updated_on_time")
+	if rss["title"] != "RSS" || rss["site_url"] != feeds.URL {
+		t.Errorf("fever feed: got %v", rss)
+	}
+	feedsGroups := res["feeds_groups"].([]any)[0].(map[string]any)
+	wantFeedIDs := strconv.Itoa(int(rss["id"].(float64))) + "," + strconv.Itoa(int(feverFeeds[1].(map[string]any)["id"].(float64)))
+	if feedsGroups["group_id"] != float64(1) || feedsGroups["feed_ids"] != wantFeedIDs {
+		t.Errorf("feeds_groups: got %v, want group 1 with %v", feedsGroups, wantFeedIDs)
+	}
+
+	// Items page forwards by since_id and backwards by max_id
+	res = fever(t, api.baseURL, "items", key)
+	all := feverItemIDs(t, res)
+	if len(all) != 5 || res["total_items"] != float64(5) {
+		t.Fatalf("items: got %v of %v, want 5", all, res["total_items"])
+	}
+	if got := feverItemIDs(t, fever(t, api.baseURL, "items&since_id="+all[2], key)); !slices.Equal(got, all[3:]) {
+		t.Errorf("items since %v: got %v, want %v", all[2], got, all[3:])
+	}
+	if got := feverItemIDs(t, fever(t, api.baseURL, "items&max_id="+all[2], key)); !slices.Equal(got, []string{all[1], all[0]}) {
+		t.Errorf("items before %v: got %v, want %v", all[2], got, []string{all[1], all[0]})
+	}
+	if got := feverItemIDs(t, fever(t, api.baseURL, "items&with_ids="+all[4]+","+all[0]+",999999", key)); !slices.Equal(got, []string{all[4], all[0]}) {
+		t.Errorf("items with ids: got %v, want %v", got, []string{all[4], all[0]})
+	}
+
+	// Marking items updates the unread and saved lists
+	res = fever(t, api.baseURL, "", url.Values{"api_key": key["api_key"], "mark": {"item"}, "as": {"read"}, "id": {all[0]}})
+	if want := strings.Join(all[1:], ","); res["unread_item_ids"] != want {
+		t.Errorf("unread after marking %v read: got %v, want %v", all[0], res["unread_item_ids"], want)
+	}
+	res = fever(t, api.baseURL, "", url.Values{"api_key": key["api_key"], "mark": {"item"}, "as": {"saved"}, "id": {all[1]}})
+	if res["saved_item_ids"] != all[1] {
+		t.Errorf("saved after saving %v: got %v", all[1], res["saved_item_ids"])
+	}
+	item := fever(t, api.baseURL, "items&with_ids="+all[1], key)["items"].([]any)[0].(map[string]any)
+	if item["is_saved"] != float64(1) || item["is_read"] != float64(0) {
+		t.Errorf("saved item: got %v", item)
+	}
+	res = fever(t, api.baseURL, "", url.Values{"api_key": key["api_key"], "mark": {"item"}, "as": {"unread"}, "id": {all[0]}})
+	if want := strings.Join(all, ","); res["unread_item_ids"] != want {
+		t.Errorf("unread after marking %v unread: got %v, want %v", all[0], res["unread_item_ids"], want)
+	}
+
+	// Marking a feed or group read only affects items stored before the cutoff
+	before := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	fever(t, api.baseURL, "", url.Values{"api_key": key["api_key"], "mark": {"group"}, "as": {"read"}, "id": {"0"}, "before": {before}})
+	if res := fever(t, api.baseURL, "unread_item_ids", key); res["unread_item_ids"] != strings.Join(all, ",") {
+		t.Errorf("unread after marking older items read: got %v", res["unread_item_ids"])
+	}
+	after := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	fever(t, api.baseURL, "", url.Values{"api_key": key["api_key"], "mark": {"feed"}, "as": {"read"}, "id": {strconv.Itoa(int(rss["id"].(float64)))}, "before": {after}})
+	res = fever(t, api.baseURL, "unread_item_ids", key)
+	if unread := strings.Split(res["unread_item_ids"].(string), ","); len(unread) != 2 {
+		t.Errorf("unread after marking the RSS feed read: got %v, want the 2 atom items", unread)
+	}
+	fever(t, api.baseURL, "", url.Values{"api_key": key["api_key"], "mark": {"group"}, "as": {"read"}, "id": {"1"}, "before": {after}})
+	if res := fever(t, api.baseURL, "unread_item_ids", key); res["unread_item_ids"] != "" {
+		t.Errorf("unread after marking the group read: got %v, want none", res["unread_item_ids"])
+	}
+
+	// Removing the fever password logs clients out
+	api.do(http.MethodDelete, "/api/users/"+userID+"/fever", nil, http.StatusNoContent)
+	api.do(http.MethodDelete, "/api/users/"+userID+"/fever", nil, http.StatusNotFound)
+	if res := fever(t, api.baseURL, "items", key); res["auth"] != float64(0) || res["items"] != nil {
+		t.Errorf("after removing the fever password: got %v, want auth 0 and nothing else", res)
+	}
+}
+
+func TestFeverRejects(t *testing.T) {
+	_, api := newTestAPI(t)
+	alice := api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusCreated)
+	bob := api.do(http.MethodPost, "/api/users", map[string]any{"name": "bob", "password": "hunter22"}, http.StatusCreated)
+	login := api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusOK)
+	api.token = login["token"].(string)
+
+	api.do(http.MethodPut, "/api/users/"+bob["id"].(string)+"/fever", map[string]any{"password": "fever-pass"}, http.StatusForbidden)
+	api.do(http.MethodPut, "/api/users/"+alice["id"].(string)+"/fever", map[string]any{"password": "fever-pass"}, http.StatusNoContent)
+
+	tests := []struct {
+		name string
+		form url.Values
+	}{
+		{"unknown mark", url.Values{"mark": {"everything"}, "as": {"read"}, "id": {"1"}}},
+		{"unknown as", url.Values{"mark": {"item"}, "as": {"starred"}, "id": {"1"}}},
+		{"feed marked unread", url.Values{"mark": {"feed"}, "as": {"unread"}, "id": {"1"}, "before": {"0"}}},
+		{"feed without before", url.Values{"mark": {"feed"}, "as": {"read"}, "id": {"1"}}},
+		{"malformed id", url.Values{"mark": {"item"}, "as": {"read"}, "id": {"one"}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.form.Set("api_key", feverAPIKey("alice", "fever-pass"))
+			res, err := http.PostForm(api.baseURL+"/fever/?api", tc.form)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusBadRequest {
+				t.Errorf("got status %d, want 400", res.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/imeltsner/gator-api/internal/database"
+)
+
+const (
+	graphqlDefaultFirst = 20
+	graphqlMaxFirst     = 100
+	graphqlMaxDepth     = 12
+	// graphqlBatchWait is how long a loader waits for sibling resolvers to ask
+	// for more keys before it queries
+	graphqlBatchWait = 2 * time.Millisecond
+)
+
+//go:embed api/schema.graphql
+var graphqlSchemaSDL string
+
+// Resolvers in a list run in parallel up to the limit, so a page resolves as one
+// batch at each level
+var graphqlSchema = graphql.MustParseSchema(graphqlSchemaSDL, &graphqlResolver{},
+	graphql.MaxDepth(graphqlMaxDepth),
+	graphql.MaxParallelism(graphqlMaxFirst),
+)
+
+func (s *state) handlerGraphQL(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Query         string         `json:"query"`
+		OperationName string         `json:"operationName"`
+		Variables     map[string]any `json:"variables"`
+		Extensions    map[string]any `json:"extensions"`
+	}
+
+	params := parameters{}
+	err := decodeJSON(w, r, &params)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	v := validator{}
+	v.required("query", params.Query)
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	userID, err := s.authenticate(r, "")
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), graphqlRequestKey{}, s.newGraphQLRequest(userID))
+	respondWithJSON(w, http.StatusOK, graphqlSchema.Exec(ctx, params.Query, params.OperationName, params.Variables))
+}
+
+type graphqlRequestKey struct{}
+
+// graphqlRequest is what resolvers share while resolving one request
+type graphqlRequest struct {
+	s      *state
+	viewer uuid.UUID
+	users  *batchLoader[uuid.UUID, database.User]
+	feeds  *batchLoader[uuid.UUID, database.Feed]
+
+	followedOnce sync.Once
+	followed     map[uuid.UUID]bool
+	followedErr  error
+}
+
+func (s *state) newGraphQLRequest(viewer uuid.UUID) *graphqlRequest {
+	return &graphqlRequest{
+		s:      s,
+		viewer: viewer,
+		users: newBatchLoader(graphqlBatchWait, func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]database.User, error) {
+			users, err := s.db.GetUsersByIDs(ctx, ids)
+			byID := make(map[uuid.UUID]database.User, len(users))
+			for _, user := range users {
+				byID[user.ID] = user
+			}
+			return byID, err
+		}),
+		feeds: newBatchLoader(graphqlBatchWait, func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]database.Feed, error) {
+			feeds, err := s.db.GetFeedsByIDs(ctx, ids)
+			byID := make(map[uuid.UUID]database.Feed, len(feeds))
+			for _, feed := range feeds {
+				byID[feed.ID] = feed
+			}
+			return byID, err
+		}),
+	}
+}
+
+func graphqlRequestFrom(ctx context.Context) *graphqlRequest {
+	return ctx.Value(graphqlRequestKey{}).(*graphqlRequest)
+}
+
+// follows reports whether the viewer follows feedID. The viewer's follows are
+// loaded once per request.
+func (req *graphqlRequest) follows(ctx context.Context, feedID uuid.UUID) (bool, error) {
+	req.followedOnce.Do(func() {
+		follows, err := req.s.db.GetFeedFollowsForUser(ctx, req.viewer)
+		req.followed, req.followedErr = map[uuid.UUID]bool{}, err
+		for _, follow := range follows {
+			req.followed[follow.FeedID] = true
+		}
+	})
+	return req.followed[feedID], req.followedErr
+}
+
+func (req *graphqlRequest) user(ctx context.Context, id uuid.UUID) (*userResolver, error) {
+	user, found, err := req.users.load(ctx, id)
+	if err != nil {
+		return nil, graphqlError(ctx, "unable to get user", err)
+	} else if !found {
+		return nil, nil
+	}
+	return &userResolver{user: user}, nil
+}
+
+func (req *graphqlRequest) feed(ctx context.Context, id uuid.UUID) (*feedResolver, error) {
+	feed, found, err := req.feeds.load(ctx, id)
+	if err != nil {
+		return nil, graphqlError(ctx, "unable to get feed", err)
+	} else if !found {
+		return nil, nil
+	}
+	return &feedResolver{feed: feed}, nil
+}
+
+// posts returns a page of the posts the viewer can see, from feedSeq only unless
+// it is 0
+func (req *graphqlRequest) posts(ctx context.Context, args connectionArgs, feedSeq int64) (*connection[*postResolver], error) {
+	first, err := args.first()
+	if err != nil {
+		return nil, err
+	}
+	params := database.GetUserItemsParams{
+		UserID:     req.viewer,
+		FeedSeq:    feedSeq,
+		Descending: true,
+		Limit:      int32(first + 1),
+	}
+	if args.After != nil {
+		after, err := decodeCursor(*args.After)
+		if err != nil {
+			return nil, err
+		}
+		params.BeforeSeq, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+	}
+
+	rows, err := req.s.db.GetUserItems(ctx, params)
+	if err != nil {
+		return nil, graphqlError(ctx, "unable to get posts", err)
+	}
+	posts := make([]*postResolver, len(rows))
+	for i, row := range rows {
+		posts[i] = &postResolver{item: row}
+	}
+	return newConnection(posts, first, func(p *postResolver) string { return strconv.FormatInt(p.item.Seq, 10) }), nil
+}
+
+// graphqlError logs err and returns msg for the response, which shouldn't leak
+// database errors
+func graphqlError(ctx context.Context, msg string, err error) error {
+	slog.ErrorContext(ctx, msg, slog.Any("error", err))
+	return errors.New(msg)
+}
+
+type connectionArgs struct {
+	First *int32
+	After *string
+}
+
+func (args connectionArgs) first() (int, error) {
+	if args.First == nil {
+		return graphqlDefaultFirst, nil
+	}
+	if *args.First < 1 || *args.First > graphqlMaxFirst {
+		return 0, fmt.Errorf("first must be between 1 and %d", graphqlMaxFirst)
+	}
+	return int(*args.First), nil
+}
+
+type connection[T any] struct {
+	edges    []*edge[T]
+	pageInfo *pageInfo
+}
+
+type edge[T any] struct {
+	cursor string
+	node   T
+}
+
+type pageInfo struct {
+	hasNextPage bool
+	endCursor   *string
+}
+
+func (c *connection[T]) Edges() []*edge[T]   { return c.edges }
+func (c *connection[T]) PageInfo() *pageInfo { return c.pageInfo }
+func (e *edge[T]) Cursor() string            { return e.cursor }
+func (e *edge[T]) Node() T                   { return e.node }
+func (p *pageInfo) HasNextPage() bool        { return p.hasNextPage }
+func (p *pageInfo) EndCursor() *string       { return p.endCursor }
+
+// newConnection makes a connection of the first items, which may hold one more
+// to tell whether there is a next page. key gives the unique value an item's
+// cursor is made from.
+func newConnection[T any](items []T, first int, key func(T) string) *connection[T] {
+	c := &connection[T]{edges: []*edge[T]{}, pageInfo: &pageInfo{hasNextPage: len(items) > first}}
+	for _, item := range items[:min(len(items), first)] {
+		c.edges = append(c.edges, &edge[T]{cursor: encodeCursor(key(item)), node: item})
+	}
+	if len(c.edges) > 0 {
+		c.pageInfo.endCursor = &c.edges[len(c.edges)-1].cursor
+	}
+	return c
+}
+
+// paginate returns the page of items after the args' cursor. items must already
+// be in order.
+func paginate[T any](items []T, args connectionArgs, key func(T) string) (*connection[T], error) {
+	first, err := args.first()
+	if err != nil {
+		return nil, err
+	}
+	if args.After != nil {
+		after, err := decodeCursor(*args.After)
+		if err != nil {
+			return nil, err
+		}
+		i := slices.IndexFunc(items, func(item T) bool { return key(item) == after })
+		if i < 0 {
+			return nil, errors.New("invalid cursor")
+		}
+		items = items[i+1:]
+	}
+	return newConnection(items, first, key), nil
+}
+
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", errors.New("invalid cursor")
+	}
+	return string(key), nil
+}
+
+func parseGraphQLID(id graphql.ID) (uuid.UUID, error) {
+	parsed, err := uuid.Parse(string(id))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid id %q", id)
+	}
+	return parsed, nil
+}
+
+type graphqlResolver struct{}
+
+func (*graphqlResolver) Viewer(ctx context.Context) (*userResolver, error) {
+	req := graphqlRequestFrom(ctx)
+	user, err := req.user(ctx, req.viewer)
+	if err == nil && user == nil {
+		return nil, errors.New("user not found")
+	}
+	return user, err
+}
+
+func (*graphqlResolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	id, err := parseGraphQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return graphqlRequestFrom(ctx).user(ctx, id)
+}
+
+func (*graphqlResolver) Users(ctx context.Context, args connectionArgs) (*connection[*userResolver], error) {
+	users, err := graphqlRequestFrom(ctx).s.db.GetUsers(ctx)
+	if err != nil {
+		return nil, graphqlError(ctx, "unable to get users", err)
+	}
+	slices.SortFunc(users, func(a, b database.User) int { return cmp.Compare(a.Name, b.Name) })
+	resolvers := make([]*userResolver, len(users))
+	for i, user := range users {
+		resolvers[i] = &userResolver{user: user}
+	}
+	return paginate(resolvers, args, func(u *userResolver) string { return u.user.Name })
+}
+
+func (*graphqlResolver) Feed(ctx context.Context, args struct{ ID graphql.ID }) (*feedResolver, error) {
+	id, err := parseGraphQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return graphqlRequestFrom(ctx).feed(ctx, id)
+}
+
+func (*graphqlResolver) Feeds(ctx context.Context, args connectionArgs) (*connection[*feedResolver], error) {
+	feeds, err := graphqlRequestFrom(ctx).s.db.GetFeeds(ctx)
+	if err != nil {
+		return nil, graphqlError(ctx, "unable to get feeds", err)
+	}
+	slices.SortFunc(feeds, func(a, b database.Feed) int { return cmp.Compare(a.Seq, b.Seq) })
+	resolvers := make([]*feedResolver, len(feeds))
+	for i, feed := range feeds {
+		resolvers[i] = &feedResolver{feed: feed}
+	}
+	return paginate(resolvers, args, func(f *feedResolver) string { return strconv.FormatInt(f.feed.Seq, 10) })
+}
+
+func (*graphqlResolver) Follows(ctx context.Context, args connectionArgs) (*connection[*followResolver], error) {
+	req := graphqlRequestFrom(ctx)
+	follows, err := req.s.db.GetFeedFollowsForUser(ctx, req.viewer)
+	if err != nil {
+		return nil, graphqlError(ctx, "unable to get follows", err)
+	}
+	slices.SortFunc(follows, func(a, b database.GetFeedFollowsForUserRow) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID.String(), b.ID.String()))
+	})
+	resolvers := make([]*followResolver, len(follows))
+	for i, follow := range follows {
+		resolvers[i] = &followResolver{follow: follow}
+	}
+	return paginate(resolvers, args, func(f *followResolver) string { return f.follow.ID.String() })
+}
+
+func (*graphqlResolver) Posts(ctx context.Context, args struct {
+	First  *int32
+	After  *string
+	FeedID *graphql.ID
+}) (*connection[*postResolver], error) {
+	req := graphqlRequestFrom(ctx)
+	page := connectionArgs{First: args.First, After: args.After}
+	if args.FeedID == nil {
+		return req.posts(ctx, page, 0)
+	}
+
+	id, err := parseGraphQLID(*args.FeedID)
+	if err != nil {
+		return nil, err
+	}
+	feed, err := req.feed(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if feed == nil {
+		return nil, errors.New("feed not found")
+	}
+	return req.posts(ctx, page, feed.feed.Seq)
+}
+
+type userResolver struct {
+	user database.User
+}
+
+func (u *userResolver) ID() graphql.ID          { return graphql.ID(u.user.ID.String()) }
+func (u *userResolver) CreatedAt() graphql.Time { return graphql.Time{Time: u.user.CreatedAt} }
+func (u *userResolver) UpdatedAt() graphql.Time { return graphql.Time{Time: u.user.UpdatedAt} }
+func (u *userResolver) Name() string            { return u.user.Name }
+
+type feedResolver struct {
+	feed database.Feed
+}
+
+func (f *feedResolver) ID() graphql.ID          { return graphql.ID(f.feed.ID.String()) }
+func (f *feedResolver) CreatedAt() graphql.Time { return graphql.Time{Time: f.feed.CreatedAt} }
+func (f *feedResolver) UpdatedAt() graphql.Time { return graphql.Time{Time: f.feed.UpdatedAt} }
+func (f *feedResolver) Title() string           { return f.feed.Title }
+func (f *feedResolver) Url() string             { return f.feed.Url }
+
+func (f *feedResolver) LastFetchedAt() *graphql.Time {
+	if !f.feed.LastFetchedAt.Valid {
+		return nil
+	}
+	return &graphql.Time{Time: f.feed.LastFetchedAt.Time}
+}
+
+func (f *feedResolver) Owner(ctx context.Context) (*userResolver, error) {
+	owner, err := graphqlRequestFrom(ctx).user(ctx, f.feed.UserID)
+	if err == nil && owner == nil {
+		return nil, errors.New("feed owner not found")
+	}
+	return owner, err
+}
+
+func (f *feedResolver) Followed(ctx context.Context) (bool, error) {
+	followed, err := graphqlRequestFrom(ctx).follows(ctx, f.feed.ID)
+	if err != nil {
+		return false, graphqlError(ctx, "unable to get follows", err)
+	}
+	return followed, nil
+}
+
+func (f *feedResolver) Posts(ctx context.Context, args connectionArgs) (*connection[*postResolver], error) {
+	return graphqlRequestFrom(ctx).posts(ctx, args, f.feed.Seq)
+}
+
+type followResolver struct {
+	follow database.GetFeedFollowsForUserRow
+}
+
+func (f *followResolver) ID() graphql.ID          { return graphql.ID(f.follow.ID.String()) }
+func (f *followResolver) CreatedAt() graphql.Time { return graphql.Time{Time: f.follow.CreatedAt} }
+
+func (f *followResolver) Feed(ctx context.Context) (*feedResolver, error) {
+	feed, err := graphqlRequestFrom(ctx).feed(ctx, f.follow.FeedID)
+	if err == nil && feed == nil {
+		return nil, errors.New("feed not found")
+	}
+	return feed, err
+}
+
+func (f *followResolver) User(ctx context.Context) (*userResolver, error) {
+	user, err := graphqlRequestFrom(ctx).user(ctx, f.follow.UserID)
+	if err == nil && user == nil {
+		return nil, errors.New("user not found")
+	}
+	return user, err
+}
+
+type postResolver struct {
+	item database.GetUserItemsRow
+}
+
+func (p *postResolver) ID() graphql.ID          { return graphql.ID(p.item.ID.String()) }
+func (p *postResolver) CreatedAt() graphql.Time { return graphql.Time{Time: p.item.CreatedAt} }
+func (p *postResolver) Title() string           { return p.item.Title }
+func (p *postResolver) Url() string             { return p.item.Url }
+func (p *postResolver) Read() bool              { return p.item.ReadAt.Valid }
+func (p *postResolver) Saved() bool             { return p.item.SavedAt.Valid }
+
+func (p *postResolver) Description() *string {
+	if !p.item.Description.Valid {
+		return nil
+	}
+	return &p.item.Description.String
+}
+
+func (p *postResolver) PublishedAt() *graphql.Time {
+	if !p.item.PublishedAt.Valid {
+		return nil
+	}
+	return &graphql.Time{Time: p.item.PublishedAt.Time}
+}
+
+func (p *postResolver) Feed(ctx context.Context) (*feedResolver, error) {
+	feed, err := graphqlRequestFrom(ctx).feed(ctx, p.item.FeedID)
+	if err == nil && feed == nil {
+		return nil, errors.New("feed not found")
+	}
+	return feed, err
+}
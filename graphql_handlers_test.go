@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/database"
+	"github.com/imeltsner/gator-api/internal/database/memory"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// countingStore counts the batch lookups GraphQL resolvers make
+type countingStore struct {
+	database.Querier
+	users, feeds atomic.Int32
+}
+
+func (s *countingStore) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]database.User, error) {
+	s.users.Add(1)
+	return s.Querier.GetUsersByIDs(ctx, ids)
+}
+
+func (s *countingStore) GetFeedsByIDs(ctx context.Context, ids []uuid.UUID) ([]database.Feed, error) {
+	s.feeds.Add(1)
+	return s.Querier.GetFeedsByIDs(ctx, ids)
+}
+
+// graphqlQuery runs query with variables and returns its data, failing on any error
+func graphqlQuery(t *testing.T, api *apiClient, query string, variables map[string]any) map[string]any {
+	t.Helper()
+	res := api.do(http.MethodPost, "/graphql", map[string]any{"query": query, "variables": variables}, http.StatusOK)
+	if res["errors"] != nil {
+		t.Fatalf("graphql: got errors %v", res["errors"])
+	}
+	return res["data"].(map[string]any)
+}
+
+// edges returns a connection's nodes and its pageInfo, if it was selected
+func edges(t *testing.T, conn any) ([]map[string]any, map[string]any) {
+	t.Helper()
+	c := conn.(map[string]any)
+	var nodes []map[string]any
+	for _, e := range c["edges"].([]any) {
+		nodes = append(nodes, e.(map[string]any)["node"].(map[string]any))
+	}
+	page, _ := c["pageInfo"].(map[string]any)
+	return nodes, page
+}
+
+func TestGraphQL(t *testing.T) {
+	store := &countingStore{Querier: memory.New()}
+	s := &state{
+		db:           store,
+		keys:         newTestKeySet(),
+		bcryptCost:   bcrypt.MinCost,
+		fetchTimeout: 5 * time.Second,
+	}
+	server := httptest.NewServer(s.routes())
+	t.Cleanup(server.Close)
+	api := &apiClient{t: t, baseURL: server.URL}
+	feeds := newFeedServer(t)
+
+	res := api.do(http.MethodPost, "/graphql", map[string]any{"query": "{ viewer { name } }"}, http.StatusUnauthorized)
+	requireErrorCode(t, res, "unauthorized")
+
+	// bob adds the feeds and alice follows them
+	api.do(http.MethodPost, "/api/users", map[string]any{"name": "bob", "password": "hunter22"}, http.StatusCreated)
+	api.token = api.do(http.MethodPost, "/api/login", map[string]any{"name": "bob", "password": "hunter22"}, http.StatusOK)["token"].(string)
+	rss := api.do(http.MethodPost, "/api/feeds", map[string]any{"title": "RSS", "url": feeds.URL + "/rss.xml"}, http.StatusCreated)
+	api.do(http.MethodPost, "/api/feeds", map[string]any{"title": "Atom", "url": feeds.URL + "/atom.xml"}, http.StatusCreated)
+	api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusCreated)
+	api.token = api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusOK)["token"].(string)
+	api.do(http.MethodPost, "/api/follows", map[string]any{"url": feeds.URL + "/rss.xml"}, http.StatusCreated)
+	api.do(http.MethodPost, "/api/agg", nil, http.StatusOK)
+	api.do(http.MethodPost, "/api/agg", nil, http.StatusOK)
+
+	data := graphqlQuery(t, api, `{ viewer { name } users { edges { node { name } } } }`, nil)
+	if name := data["viewer"].(map[string]any)["name"]; name != "alice" {
+		t.Errorf("viewer: got %v, want alice", name)
+	}
+	if users, _ := edges(t, data["users"]); len(users) != 2 || users[0]["name"] != "alice" || users[1]["name"] != "bob" {
+		t.Errorf("users: got %v, want alice and bob", users)
+	}
+
+	// Every feed's owner and every post's feed resolves with one lookup each
+	store.users.Store(0)
+	store.feeds.Store(0)
+	data = graphqlQuery(t, api, `{
+		feeds { edges { node { title followed owner { name } } } }
+		posts { edges { node { id title feed { title owner { name } } } } }
+	}`, nil)
+	feedNodes, _ := edges(t, data["feeds"])
+	if len(feedNodes) != 2 || feedNodes[0]["title"] != "RSS" || feedNodes[0]["followed"] != true || feedNodes[1]["followed"] != false {
+		t.Errorf("feeds: got %v", feedNodes)
+	}
+	for _, feed := range feedNodes {
+		if owner := feed["owner"].(map[string]any)["name"]; owner != "bob" {
+			t.Errorf("feed owner: got %v, want bob", owner)
+		}
+	}
+	posts, _ := edges(t, data["posts"])
+	if len(posts) != 3 {
+		t.Fatalf("posts: got %d, want the 3 from the followed feed", len(posts))
+	}
+	for _, post := range posts {
+		if feed := post["feed"].(map[string]any); feed["title"] != "RSS" || feed["owner"].(map[string]any)["name"] != "bob" {
+			t.Errorf("post feed: got %v", feed)
+		}
+	}
+	if users, feeds := store.users.Load(), store.feeds.Load(); users > 2 || feeds != 1 {
+		t.Errorf("batch lookups: got %d for users and %d for feeds, want at most 2 and 1", users, feeds)
+	}
+
+	// Pages of posts follow the cursor to the end
+	query := `query($after: String) { posts(first: 2, after: $after) { edges { node { id } } pageInfo { hasNextPage endCursor } } }`
+	first, page := edges(t, graphqlQuery(t, api, query, nil)["posts"])
+	if len(first) != 2 || page["hasNextPage"] != true {
+		t.Fatalf("first page: got %v, %v", first, page)
+	}
+	second, page := edges(t, graphqlQuery(t, api, query, map[string]any{"after": page["endCursor"]})["posts"])
+	if len(second) != 1 || page["hasNextPage"] != false || second[0]["id"] != posts[2]["id"] {
+		t.Errorf("second page: got %v, %v", second, page)
+	}
+
+	// Nested posts and follows
+	data = graphqlQuery(t, api, `query($id: ID!) {
+		feed(id: $id) { posts(first: 1) { edges { node { title read saved } } } }
+		follows { edges { node { user { name } feed { url } } } }
+	}`, map[string]any{"id": rss["id"]})
+	if nested, _ := edges(t, data["feed"].(map[string]any)["posts"]); len(nested) != 1 || nested[0]["read"] != false {
+		t.Errorf("feed posts: got %v", nested)
+	}
+	follows, _ := edges(t, data["follows"])
+	if len(follows) != 1 || follows[0]["user"].(map[string]any)["name"] != "alice" || follows[0]["feed"].(map[string]any)["url"] != feeds.URL+"/rss.xml" {
+		t.Errorf("follows: got %v", follows)
+	}
+	if feed := graphqlQuery(t, api, `{ feed(id: "`+uuid.NewString()+`") { title } }`, nil)["feed"]; feed != nil {
+		t.Errorf("unknown feed: got %v, want null", feed)
+	}
+
+	// Query errors are reported in the response
+	res = api.do(http.MethodPost, "/graphql", map[string]any{"query": `{ posts(first: 1000) { edges { cursor } } }`}, http.StatusOK)
+	if res["errors"] == nil {
+		t.Errorf("first over the limit: got %v, want errors", res)
+	}
+	res = api.do(http.MethodPost, "/graphql", map[string]any{"query": `{ posts(after: "nope") { edges { cursor } } }`}, http.StatusOK)
+	if res["errors"] == nil {
+		t.Errorf("bad cursor: got %v, want errors", res)
+	}
+	res = api.do(http.MethodPost, "/graphql", map[string]any{"query": `{ feeds { edges { node { nope } } } }`}, http.StatusOK)
+	if res["errors"] == nil || res["data"] != nil {
+		t.Errorf("unknown field: got %v, want errors", res)
+	}
+}
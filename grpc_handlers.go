@@ -0,0 +1,510 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	gatorv1 "github.com/imeltsner/gator-api/api/gator/v1"
+	"github.com/imeltsner/gator-api/internal/database"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// watchPostsPoll is how often WatchPosts looks for posts saved by other server
+	// processes, which don't wake it
+	watchPostsPoll  = 30 * time.Second
+	watchPostsBatch = 100
+)
+
+// postNotifier wakes WatchPosts streams when posts are saved. The zero value is
+// ready to use.
+type postNotifier struct {
+	mu   sync.Mutex
+	wake chan struct{}
+}
+
+// wait returns a channel that is closed the next time posts are saved
+func (n *postNotifier) wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.wake == nil {
+		n.wake = make(chan struct{})
+	}
+	return n.wake
+}
+
+func (n *postNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.wake != nil {
+		close(n.wake)
+		n.wake = nil
+	}
+}
+
+type grpcUserServer struct {
+	gatorv1.UnimplementedUserServiceServer
+	s *state
+}
+
+func (g *grpcUserServer) CreateUser(ctx context.Context, req *gatorv1.CreateUserRequest) (*gatorv1.User, error) {
+	user, err := g.s.createUser(ctx, req.Name, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	return newGRPCUser(user), nil
+}
+
+func (g *grpcUserServer) Login(ctx context.Context, req *gatorv1.LoginRequest) (*gatorv1.LoginResponse, error) {
+	v := validator{}
+	v.required("name", req.Name)
+	v.required("password", req.Password)
+	if req.ExpiresInSeconds < 0 {
+		v.add("expires_in_seconds", "must not be negative")
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	user, err := g.s.checkLogin(ctx, req.Name, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	token, err := g.s.keys.MakeJWT(user.ID, loginExpiry(int(req.ExpiresInSeconds)))
+	if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to make jwt", err: err}
+	}
+
+	return &gatorv1.LoginResponse{User: newGRPCUser(user), Token: token}, nil
+}
+
+func (g *grpcUserServer) GetUser(ctx context.Context, req *gatorv1.GetUserRequest) (*gatorv1.User, error) {
+	id, err := grpcSelf(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := g.s.db.GetUserByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &requestError{status: http.StatusNotFound, message: "user not found", err: err}
+	} else if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to get user", err: err}
+	}
+	return newGRPCUser(user), nil
+}
+
+func (g *grpcUserServer) ListUsers(ctx context.Context, req *gatorv1.ListUsersRequest) (*gatorv1.ListUsersResponse, error) {
+	users, err := g.s.db.GetUsers(ctx)
+	if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to get users", err: err}
+	}
+
+	res := &gatorv1.ListUsersResponse{Users: make([]*gatorv1.User, len(users))}
+	for i, user := range users {
+		res.Users[i] = newGRPCUser(user)
+	}
+	return res, nil
+}
+
+func (g *grpcUserServer) DeleteUser(ctx context.Context, req *gatorv1.DeleteUserRequest) (*gatorv1.DeleteUserResponse, error) {
+	id, err := grpcSelf(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.s.db.DeleteUser(ctx, id)
+	if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to delete user", err: err}
+	}
+	return &gatorv1.DeleteUserResponse{}, nil
+}
+
+func (g *grpcUserServer) ChangePassword(ctx context.Context, req *gatorv1.ChangePasswordRequest) (*gatorv1.ChangePasswordResponse, error) {
+	id, err := grpcSelf(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := g.s.db.GetUserByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &requestError{status: http.StatusNotFound, message: "user not found", err: err}
+	} else if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to get user", err: err}
+	}
+
+	// Like the HTTP API, users without a password set their first one without
+	// a current password
+	hasPassword := user.HashedPassword != unsetPassword
+	v := validator{}
+	if hasPassword {
+		v.required("current_password", req.CurrentPassword)
+	}
+	v.password("new_password", req.NewPassword, user.Name)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	if hasPassword {
+		// Guessing the current password here counts towards the login lockout
+		if wait := g.s.logins.locked(user.Name); wait > 0 {
+			return nil, &requestError{status: http.StatusTooManyRequests, message: "too many failed logins, try again later"}
+		}
+		err = bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(req.CurrentPassword))
+		if err != nil {
+			g.s.logins.fail(user.Name)
+			return nil, &requestError{status: http.StatusForbidden, message: "incorrect current password", err: err}
+		}
+		g.s.logins.succeed(user.Name)
+	}
+
+	err = g.s.setPassword(ctx, user, req.NewPassword)
+	if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to change password", err: err}
+	}
+
+	slog.InfoContext(ctx, "password changed", slog.String("user_id", user.ID.String()))
+	return &gatorv1.ChangePasswordResponse{}, nil
+}
+
+type grpcFeedServer struct {
+	gatorv1.UnimplementedFeedServiceServer
+	s *state
+}
+
+func (g *grpcFeedServer) CreateFeed(ctx context.Context, req *gatorv1.CreateFeedRequest) (*gatorv1.Feed, error) {
+	v := validator{}
+	v.required("title", req.Title)
+	v.feedURL("url", req.Url)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	userID := grpcUserID(ctx)
+	feed, err := g.s.db.CreateFeed(ctx, database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Title:     req.Title,
+		Url:       req.Url,
+		UserID:    userID,
+	})
+	if database.IsUniqueViolation(err) {
+		return nil, &requestError{status: http.StatusConflict, message: "feed already exists", err: err}
+	} else if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to create feed", err: err}
+	}
+
+	_, err = g.s.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		UserID:    userID,
+		FeedID:    feed.ID,
+	})
+	if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to create feed follow entry", err: err}
+	}
+
+	slog.InfoContext(ctx, "feed created", slog.String("feed_id", feed.ID.String()), slog.String("title", feed.Title), slog.String("url", feed.Url))
+	return newGRPCFeed(feed), nil
+}
+
+func (g *grpcFeedServer) GetFeed(ctx context.Context, req *gatorv1.GetFeedRequest) (*gatorv1.Feed, error) {
+	id, err := grpcID("id", req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	feed, err := g.s.db.GetFeedByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &requestError{status: http.StatusNotFound, message: "feed not found", err: err}
+	} else if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to get feed", err: err}
+	}
+	return newGRPCFeed(feed), nil
+}
+
+func (g *grpcFeedServer) ListFeeds(ctx context.Context, req *gatorv1.ListFeedsRequest) (*gatorv1.ListFeedsResponse, error) {
+	feeds, err := g.s.db.GetFeeds(ctx)
+	if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to get feeds", err: err}
+	}
+
+	res := &gatorv1.ListFeedsResponse{Feeds: make([]*gatorv1.Feed, len(feeds))}
+	seen := map[uuid.UUID]bool{}
+	var ownerIDs []uuid.UUID
+	for i, feed := range feeds {
+		res.Feeds[i] = newGRPCFeed(feed)
+		if !seen[feed.UserID] {
+			seen[feed.UserID] = true
+			ownerIDs = append(ownerIDs, feed.UserID)
+		}
+	}
+
+	owners, err := g.s.db.GetUsersByIDs(ctx, ownerIDs)
+	if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to get users", err: err}
+	}
+	for _, owner := range owners {
+		res.Owners = append(res.Owners, newGRPCUser(owner))
+	}
+	return res, nil
+}
+
+type grpcFollowServer struct {
+	gatorv1.UnimplementedFollowServiceServer
+	s *state
+}
+
+func (g *grpcFollowServer) CreateFollow(ctx context.Context, req *gatorv1.CreateFollowRequest) (*gatorv1.Follow, error) {
+	feed, err := g.feedByURL(ctx, req.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	follow, err := g.s.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		UserID:    grpcUserID(ctx),
+		FeedID:    feed.ID,
+	})
+	if database.IsUniqueViolation(err) {
+		return nil, &requestError{status: http.StatusConflict, message: "feed already followed", err: err}
+	} else if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to create feed follow entry", err: err}
+	}
+
+	return &gatorv1.Follow{
+		Id:        follow.ID.String(),
+		CreatedAt: timestamppb.New(follow.CreatedAt),
+		UpdatedAt: timestamppb.New(follow.UpdatedAt),
+		UserId:    follow.UserID.String(),
+		FeedId:    follow.FeedID.String(),
+	}, nil
+}
+
+func (g *grpcFollowServer) ListFollows(ctx context.Context, req *gatorv1.ListFollowsRequest) (*gatorv1.ListFollowsResponse, error) {
+	follows, err := g.s.db.GetFeedFollowsForUser(ctx, grpcUserID(ctx))
+	if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to get follows", err: err}
+	}
+
+	res := &gatorv1.ListFollowsResponse{Follows: make([]*gatorv1.Follow, len(follows))}
+	for i, follow := range follows {
+		res.Follows[i] = &gatorv1.Follow{
+			Id:        follow.ID.String(),
+			CreatedAt: timestamppb.New(follow.CreatedAt),
+			UpdatedAt: timestamppb.New(follow.UpdatedAt),
+			UserId:    follow.UserID.String(),
+			FeedId:    follow.FeedID.String(),
+			FeedTitle: follow.FeedTitle,
+			PostedBy:  follow.UserName,
+		}
+	}
+	return res, nil
+}
+
+func (g *grpcFollowServer) DeleteFollow(ctx context.Context, req *gatorv1.DeleteFollowRequest) (*gatorv1.DeleteFollowResponse, error) {
+	feed, err := g.feedByURL(ctx, req.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.s.db.DeleteFeedFollow(ctx, database.DeleteFeedFollowParams{
+		UserID: grpcUserID(ctx),
+		FeedID: feed.ID,
+	})
+	if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to unfollow", err: err}
+	}
+	return &gatorv1.DeleteFollowResponse{}, nil
+}
+
+func (g *grpcFollowServer) feedByURL(ctx context.Context, feedURL string) (database.Feed, error) {
+	v := validator{}
+	v.feedURL("url", feedURL)
+	if err := v.err(); err != nil {
+		return database.Feed{}, err
+	}
+
+	feed, err := g.s.db.GetFeedByURL(ctx, feedURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Feed{}, &requestError{status: http.StatusNotFound, message: "feed not found", err: err}
+	} else if err != nil {
+		return database.Feed{}, &requestError{status: http.StatusInternalServerError, message: "unable to get feed", err: err}
+	}
+	return feed, nil
+}
+
+type grpcPostServer struct {
+	gatorv1.UnimplementedPostServiceServer
+	s        *state
+	shutdown <-chan struct{}
+}
+
+func (g *grpcPostServer) ListPosts(ctx context.Context, req *gatorv1.ListPostsRequest) (*gatorv1.ListPostsResponse, error) {
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = defaultPostsLimit
+	}
+	v := validator{}
+	v.between("limit", limit, 1, maxPostsLimit)
+	if req.Offset < 0 {
+		v.add("offset", "must not be negative")
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	posts, err := g.s.db.GetPostsForUser(ctx, database.GetPostsForUserParams{
+		UserID: grpcUserID(ctx),
+		Limit:  int32(limit),
+		Offset: req.Offset,
+	})
+	if err != nil {
+		return nil, &requestError{status: http.StatusInternalServerError, message: "unable to get posts", err: err}
+	}
+
+	res := &gatorv1.ListPostsResponse{Posts: make([]*gatorv1.Post, len(posts))}
+	for i, post := range posts {
+		res.Posts[i] = newGRPCPost(post)
+	}
+	return res, nil
+}
+
+func (g *grpcPostServer) WatchPosts(req *gatorv1.WatchPostsRequest, stream gatorv1.PostService_WatchPostsServer) error {
+	ctx := stream.Context()
+	userID := grpcUserID(ctx)
+
+	// Only posts saved from now on are sent
+	newest, err := g.s.db.GetUserItems(ctx, database.GetUserItemsParams{UserID: userID, Descending: true, Limit: 1})
+	if err != nil {
+		return &requestError{status: http.StatusInternalServerError, message: "unable to get posts", err: err}
+	}
+	var afterSeq int64
+	if len(newest) > 0 {
+		afterSeq = newest[0].Seq
+	}
+	// Tell the client the watch has started
+	err = stream.SendHeader(metadata.MD{})
+	if err != nil {
+		return err
+	}
+
+	poll := time.NewTicker(watchPostsPoll)
+	defer poll.Stop()
+	for {
+		// Wait on the notifier from before the query so posts saved during it
+		// aren't missed
+		wake := g.s.newPosts.wait()
+		items, err := g.s.db.GetUserItems(ctx, database.GetUserItemsParams{UserID: userID, AfterSeq: afterSeq, Limit: watchPostsBatch})
+		if err != nil {
+			return &requestError{status: http.StatusInternalServerError, message: "unable to get posts", err: err}
+		}
+		for _, item := range items {
+			err = stream.Send(newGRPCPost(database.Post{
+				ID:          item.ID,
+				CreatedAt:   item.CreatedAt,
+				UpdatedAt:   item.UpdatedAt,
+				Title:       item.Title,
+				Url:         item.Url,
+				Description: item.Description,
+				PublishedAt: item.PublishedAt,
+				FeedID:      item.FeedID,
+			}))
+			if err != nil {
+				return err
+			}
+			afterSeq = item.Seq
+		}
+		if len(items) == watchPostsBatch {
+			continue
+		}
+
+		select {
+		case <-wake:
+		case <-poll.C:
+		case <-ctx.Done():
+			return nil
+		case <-g.shutdown:
+			return nil
+		}
+	}
+}
+
+// grpcID parses the named field as a UUID
+func grpcID(field, value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, &requestError{
+			status:  http.StatusBadRequest,
+			code:    codeValidation,
+			message: "invalid request",
+			details: []fieldError{{Field: field, Message: "must be a UUID"}},
+			err:     err,
+		}
+	}
+	return id, nil
+}
+
+// grpcSelf parses id, which must be the caller's
+func grpcSelf(ctx context.Context, id string) (uuid.UUID, error) {
+	parsed, err := grpcID("id", id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if parsed != grpcUserID(ctx) {
+		return uuid.Nil, &requestError{status: http.StatusForbidden, message: "mismatched id"}
+	}
+	return parsed, nil
+}
+
+func newGRPCUser(user database.User) *gatorv1.User {
+	return &gatorv1.User{
+		Id:        user.ID.String(),
+		CreatedAt: timestamppb.New(user.CreatedAt),
+		UpdatedAt: timestamppb.New(user.UpdatedAt),
+		Name:      user.Name,
+	}
+}
+
+func newGRPCFeed(feed database.Feed) *gatorv1.Feed {
+	return &gatorv1.Feed{
+		Id:            feed.ID.String(),
+		CreatedAt:     timestamppb.New(feed.CreatedAt),
+		UpdatedAt:     timestamppb.New(feed.UpdatedAt),
+		LastFetchedAt: grpcTime(feed.LastFetchedAt),
+		Title:         feed.Title,
+		Url:           feed.Url,
+		UserId:        feed.UserID.String(),
+	}
+}
+
+func newGRPCPost(post database.Post) *gatorv1.Post {
+	return &gatorv1.Post{
+		Id:          post.ID.String(),
+		CreatedAt:   timestamppb.New(post.CreatedAt),
+		UpdatedAt:   timestamppb.New(post.UpdatedAt),
+		Title:       post.Title,
+		Url:         post.Url,
+		Description: post.Description.String,
+		PublishedAt: grpcTime(post.PublishedAt),
+		FeedId:      post.FeedID.String(),
+	}
+}
+
+func grpcTime(t sql.NullTime) *timestamppb.Timestamp {
+	if !t.Valid {
+		return nil
+	}
+	return timestamppb.New(t.Time)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	gatorv1 "github.com/imeltsner/gator-api/api/gator/v1"
+	"github.com/imeltsner/gator-api/internal/database/memory"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestGRPC serves the gRPC API over an in-memory connection and returns the
+// state behind it and a connection to it
+func newTestGRPC(t *testing.T) (*state, *grpc.ClientConn) {
+	t.Helper()
+	s := &state{
+		db:           memory.New(),
+		keys:         newTestKeySet(),
+		bcryptCost:   bcrypt.MinCost,
+		fetchTimeout: 5 * time.Second,
+	}
+	shutdown := make(chan struct{})
+	server := s.newGRPCServer(shutdown)
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(func() {
+		close(shutdown)
+		server.GracefulStop()
+	})
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, conn
+}
+
+func requireCode(t *testing.T, what string, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Errorf("%v: got code %v (%v), want %v", what, got, err, want)
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	s, conn := newTestGRPC(t)
+	users := gatorv1.NewUserServiceClient(conn)
+	feeds := gatorv1.NewFeedServiceClient(conn)
+	follows := gatorv1.NewFollowServiceClient(conn)
+	posts := gatorv1.NewPostServiceClient(conn)
+	feedServer := newFeedServer(t)
+	ctx := context.Background()
+
+	_, err := users.CreateUser(ctx, &gatorv1.CreateUserRequest{Name: "alice", Password: "short"})
+	requireCode(t, "weak password", err, codes.InvalidArgument)
+	if details := status.Convert(err).Details(); len(details) != 1 || details[0].(*errdetails.BadRequest).FieldViolations[0].Field != "password" {
+		t.Errorf("weak password details: got %v", details)
+	}
+
+	user, err := users.CreateUser(ctx, &gatorv1.CreateUserRequest{Name: "alice", Password: "hunter22"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	_, err = users.CreateUser(ctx, &gatorv1.CreateUserRequest{Name: "alice", Password: "hunter22"})
+	requireCode(t, "duplicate user", err, codes.AlreadyExists)
+	_, err = users.Login(ctx, &gatorv1.LoginRequest{Name: "alice", Password: "wrong-password"})
+	requireCode(t, "wrong password", err, codes.Unauthenticated)
+
+	_, err = follows.ListFollows(ctx, &gatorv1.ListFollowsRequest{})
+	requireCode(t, "no token", err, codes.Unauthenticated)
+	bad := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer nope")
+	_, err = follows.ListFollows(bad, &gatorv1.ListFollowsRequest{})
+	requireCode(t, "bad token", err, codes.Unauthenticated)
+
+	login, err := users.Login(ctx, &gatorv1.LoginRequest{Name: "alice", Password: "hunter22"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	authed := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+login.Token)
+
+	if got, err := users.GetUser(authed, &gatorv1.GetUserRequest{Id: user.Id}); err != nil || got.Name != "alice" {
+		t.Errorf("GetUser: got %v, %v", got, err)
+	}
+	_, err = users.GetUser(authed, &gatorv1.GetUserRequest{Id: "00000000-0000-0000-0000-000000000000"})
+	requireCode(t, "someone else", err, codes.PermissionDenied)
+
+	rss, err := feeds.CreateFeed(authed, &gatorv1.CreateFeedRequest{Title: "RSS", Url: feedServer.URL + "/rss.xml"})
+	if err != nil {
+		t.Fatalf("CreateFeed: %v", err)
+	}
+	atom, err := feeds.CreateFeed(authed, &gatorv1.CreateFeedRequest{Title: "Atom", Url: feedServer.URL + "/atom.xml"})
+	if err != nil {
+		t.Fatalf("CreateFeed: %v", err)
+	}
+	if rss.UserId != user.Id || rss.LastFetchedAt != nil {
+		t.Errorf("new feed: got %v", rss)
+	}
+	list, err := feeds.ListFeeds(ctx, &gatorv1.ListFeedsRequest{})
+	if err != nil || len(list.Feeds) != 2 || len(list.Owners) != 1 || list.Owners[0].Name != "alice" {
+		t.Errorf("ListFeeds: got %v, %v", list, err)
+	}
+	_, err = feeds.GetFeed(ctx, &gatorv1.GetFeedRequest{Id: "nope"})
+	requireCode(t, "bad feed id", err, codes.InvalidArgument)
+
+	// Adding a feed follows it; unfollowing and following again works by URL
+	_, err = follows.DeleteFollow(authed, &gatorv1.DeleteFollowRequest{Url: atom.Url})
+	if err != nil {
+		t.Fatalf("DeleteFollow: %v", err)
+	}
+	following, err := follows.ListFollows(authed, &gatorv1.ListFollowsRequest{})
+	if err != nil || len(following.Follows) != 1 || following.Follows[0].FeedTitle != "RSS" || following.Follows[0].PostedBy != "alice" {
+		t.Errorf("ListFollows: got %v, %v", following, err)
+	}
+	_, err = follows.CreateFollow(authed, &gatorv1.CreateFollowRequest{Url: rss.Url})
+	requireCode(t, "follow twice", err, codes.AlreadyExists)
+
+	// New posts arrive on the stream as feeds are fetched, and only from followed
+	// feeds
+	watchCtx, cancel := context.WithTimeout(authed, 10*time.Second)
+	defer cancel()
+	watch, err := posts.WatchPosts(watchCtx, &gatorv1.WatchPostsRequest{})
+	if err != nil {
+		t.Fatalf("WatchPosts: %v", err)
+	}
+	if _, err := watch.Header(); err != nil {
+		t.Fatalf("WatchPosts header: %v", err)
+	}
+	for range 2 {
+		if err := s.scrapeFeeds(ctx); err != nil {
+			t.Fatalf("scrapeFeeds: %v", err)
+		}
+	}
+	for i := range 3 {
+		post, err := watch.Recv()
+		if err != nil {
+			t.Fatalf("post %d: %v", i, err)
+		}
+		if post.FeedId != rss.Id {
+			t.Errorf("post %d: got feed %v, want %v", i, post.FeedId, rss.Id)
+		}
+	}
+
+	page, err := posts.ListPosts(authed, &gatorv1.ListPostsRequest{Limit: 2})
+	if err != nil || len(page.Posts) != 2 {
+		t.Errorf("ListPosts: got %v, %v", page, err)
+	}
+	_, err = posts.ListPosts(authed, &gatorv1.ListPostsRequest{Limit: 1000})
+	requireCode(t, "limit over the maximum", err, codes.InvalidArgument)
+
+	_, err = users.DeleteUser(authed, &gatorv1.DeleteUserRequest{Id: user.Id})
+	if err != nil {
+		t.Errorf("DeleteUser: %v", err)
+	}
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	s, conn := newTestGRPC(t)
+	s.rateLimiters = newRateLimiters(rateLimit{requests: 100, window: time.Minute}, false)
+	users := gatorv1.NewUserServiceClient(conn)
+	ctx := context.Background()
+
+	// Sign ups share the five a minute allowed over HTTP
+	for i := range 5 {
+		_, err := users.CreateUser(ctx, &gatorv1.CreateUserRequest{Name: "alice", Password: "short"})
+		requireCode(t, fmt.Sprintf("sign up %d", i), err, codes.InvalidArgument)
+	}
+	_, err := users.CreateUser(ctx, &gatorv1.CreateUserRequest{Name: "alice", Password: "hunter22"})
+	requireCode(t, "sign up over the limit", err, codes.ResourceExhausted)
+
+	// Other methods have their own limits
+	_, err = users.Login(ctx, &gatorv1.LoginRequest{Name: "alice", Password: "hunter22"})
+	requireCode(t, "login", err, codes.NotFound)
+}
@@ -0,0 +1,228 @@
+package main
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative api/gator/v1/gator.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	gatorv1 "github.com/imeltsner/gator-api/api/gator/v1"
+	"github.com/imeltsner/gator-api/internal/auth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcPublicMethods can be called without a token, like their HTTP routes
+var grpcPublicMethods = map[string]bool{
+	gatorv1.UserService_CreateUser_FullMethodName: true,
+	gatorv1.UserService_Login_FullMethodName:      true,
+	gatorv1.UserService_ListUsers_FullMethodName:  true,
+	gatorv1.FeedService_GetFeed_FullMethodName:    true,
+	gatorv1.FeedService_ListFeeds_FullMethodName:  true,
+}
+
+// grpcRateLimitRoutes shares the tighter limits of routeRateLimits with the
+// methods equivalent to those routes; other methods share the default limit
+var grpcRateLimitRoutes = map[string]string{
+	gatorv1.UserService_CreateUser_FullMethodName: "POST /api/users",
+	gatorv1.UserService_Login_FullMethodName:      "POST /api/login",
+}
+
+// grpcCodes maps the statuses of requestErrors to gRPC codes
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusInternalServerError: codes.Internal,
+}
+
+// newGRPCServer serves the gRPC API from the same state as the HTTP API. Streams
+// end when shutdown is closed so the server can stop gracefully.
+func (s *state) newGRPCServer(shutdown <-chan struct{}) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.grpcUnaryInterceptor),
+		grpc.ChainStreamInterceptor(s.grpcStreamInterceptor),
+	)
+	gatorv1.RegisterUserServiceServer(server, &grpcUserServer{s: s})
+	gatorv1.RegisterFeedServiceServer(server, &grpcFeedServer{s: s})
+	gatorv1.RegisterFollowServiceServer(server, &grpcFollowServer{s: s})
+	gatorv1.RegisterPostServiceServer(server, &grpcPostServer{s: s, shutdown: shutdown})
+	return server
+}
+
+func (s *state) grpcUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	ctx, err := s.grpcContext(ctx, info.FullMethod)
+	if limitErr := s.grpcRateLimit(ctx, info.FullMethod); limitErr != nil {
+		err = limitErr
+	}
+	var res any
+	if err == nil {
+		res, err = handler(ctx, req)
+	}
+	err = grpcError(ctx, err)
+	logGRPC(ctx, info.FullMethod, err, start)
+	return res, err
+}
+
+func (s *state) grpcStreamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, err := s.grpcContext(stream.Context(), info.FullMethod)
+	if limitErr := s.grpcRateLimit(ctx, info.FullMethod); limitErr != nil {
+		err = limitErr
+	}
+	if err == nil {
+		err = handler(srv, &grpcServerStream{ServerStream: stream, ctx: ctx})
+	}
+	err = grpcError(ctx, err)
+	logGRPC(ctx, info.FullMethod, err, start)
+	return err
+}
+
+// grpcServerStream replaces a stream's context with one carrying the caller
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcContext adds the request ID and, unless the method is public, the user
+// authenticated by the authorization metadata to ctx
+func (s *state) grpcContext(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	requestID := ""
+	if ids := md.Get(requestIDHeader); len(ids) > 0 && validRequestID(ids[0]) {
+		requestID = ids[0]
+	} else {
+		requestID = uuid.NewString()
+	}
+	ctx = contextWithRequestID(ctx, requestID)
+	if grpcPublicMethods[method] {
+		return ctx, nil
+	}
+
+	header := http.Header{}
+	for _, value := range md.Get("authorization") {
+		header.Add("Authorization", value)
+	}
+	token, err := auth.GetBearerToken(header)
+	if err != nil {
+		return ctx, &requestError{status: http.StatusUnauthorized, message: "unable to parse auth metadata", err: err}
+	}
+	if auth.IsAPIKey(token) {
+		return ctx, &requestError{status: http.StatusForbidden, message: "api keys can't be used here, log in instead"}
+	}
+	userID, err := s.keys.ValidateJWT(token)
+	if err != nil {
+		return ctx, &requestError{status: http.StatusUnauthorized, message: "unable to validate jwt", err: err}
+	}
+	return context.WithValue(ctx, userIDKey, userID), nil
+}
+
+// grpcRateLimit takes a token from the caller's bucket for method, keyed like
+// middlewareRateLimit by user ID when the call is authenticated and by the peer's
+// address otherwise
+func (s *state) grpcRateLimit(ctx context.Context, method string) error {
+	if s.rateLimiters == nil {
+		return nil
+	}
+	limiter, ok := s.rateLimiters.routes[grpcRateLimitRoutes[method]]
+	if !ok {
+		limiter = s.rateLimiters.fallback
+	}
+	key := "ip:" + grpcClientIP(ctx)
+	if userID := grpcUserID(ctx); !limiter.limit.byIP && userID != uuid.Nil {
+		key = "user:" + userID.String()
+	}
+
+	allowed, _, _, retryAfter := limiter.allow(key)
+	if !allowed {
+		return &requestError{
+			status:  http.StatusTooManyRequests,
+			message: fmt.Sprintf("rate limit exceeded, retry in %ds", ceilSeconds(retryAfter)),
+		}
+	}
+	return nil
+}
+
+// grpcClientIP returns the address of the peer that made the call
+func grpcClientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// grpcUserID returns the user authenticated by grpcContext
+func grpcUserID(ctx context.Context) uuid.UUID {
+	id, _ := ctx.Value(userIDKey).(uuid.UUID)
+	return id
+}
+
+// grpcError turns a requestError into a status with the same message and any
+// field errors as BadRequest details. Anything else is an internal error whose
+// cause is only logged.
+func grpcError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	var reqErr *requestError
+	if !errors.As(err, &reqErr) {
+		reqErr = &requestError{status: http.StatusInternalServerError, message: "internal error", err: err}
+	}
+	code, ok := grpcCodes[reqErr.status]
+	if !ok {
+		code = codes.Unknown
+	}
+	if code == codes.Internal {
+		slog.ErrorContext(ctx, "responding with internal error", slog.String("reason", reqErr.message), slog.Any("error", err))
+	}
+
+	st := status.New(code, reqErr.message)
+	if len(reqErr.details) > 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(reqErr.details))
+		for i, detail := range reqErr.details {
+			violations[i] = &errdetails.BadRequest_FieldViolation{Field: detail.Field, Description: detail.Message}
+		}
+		if withDetails, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+			st = withDetails
+		}
+	}
+	return st.Err()
+}
+
+func logGRPC(ctx context.Context, method string, err error, start time.Time) {
+	attrs := []any{
+		slog.String("method", method),
+		slog.String("code", status.Code(err).String()),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if userID := grpcUserID(ctx); userID != uuid.Nil {
+		attrs = append(attrs, slog.String("user_id", userID.String()))
+	}
+	slog.InfoContext(ctx, "rpc handled", attrs...)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const readinessTimeout = 2 * time.Second
+
+func (s *state) handlerHealth(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Status string `json:"status"`
+	}
+
+	respondWithJSON(w, http.StatusOK, response{Status: "ok"})
+}
+
+func (s *state) handlerReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]string{
+		"database":   "ok",
+		"migrations": "ok",
+		"scheduler":  "ok",
+	}
+	ready := true
+	fail := func(check, msg string) {
+		checks[check] = msg
+		ready = false
+	}
+
+	if s.sqlDB == nil {
+		fail("database", "not configured")
+	} else if err := s.sqlDB.PingContext(ctx); err != nil {
+		fail("database", fmt.Sprintf("unreachable: %v", err))
+	}
+
+	if s.migrations == nil {
+		fail("migrations", "not configured")
+	} else if current, target, err := s.migrations.GetVersions(ctx); err != nil {
+		fail("migrations", fmt.Sprintf("unable to get version: %v", err))
+	} else if current != target {
+		fail("migrations", fmt.Sprintf("at version %d, expected %d", current, target))
+	}
+
+	if s.sched == nil || !s.sched.isRunning() {
+		fail("scheduler", "not running")
+	}
+
+	type response struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+
+	if !ready {
+		respondWithJSON(w, http.StatusServiceUnavailable, response{Status: "unavailable", Checks: checks})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, response{Status: "ready", Checks: checks})
+}
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
@@ -11,47 +13,28 @@ import (
 	"github.com/google/uuid"
 )
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    "gatorapi-access",
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+// MakeJWT issues an access token for userID, signed with the key set's signing key
+func (ks *KeySet) MakeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	now := ks.now().UTC()
+	return ks.sign(jwt.RegisteredClaims{
+		Issuer:    ks.Issuer,
+		Audience:  jwt.ClaimStrings{ks.Audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
 		Subject:   userID.String(),
 	})
-
-	signedString, err := token.SignedString([]byte(tokenSecret))
-	if err != nil {
-		return "", fmt.Errorf("unable to sign string %v", err)
-	}
-
-	return signedString, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+// ValidateJWT checks an access token's signature, expiry, issuer and audience and
+// returns the user it was issued to
+func (ks *KeySet) ValidateJWT(tokenString string) (uuid.UUID, error) {
 	claims := jwt.RegisteredClaims{}
-	token, err := jwt.ParseWithClaims(
-		tokenString,
-		&claims,
-		func(token *jwt.Token) (interface{}, error) { return []byte(tokenSecret), nil },
-	)
+	err := ks.parse(tokenString, &claims, ks.Issuer)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("unable to parse claims: %v", err)
 	}
 
-	issuer, err := token.Claims.GetIssuer()
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("unable to get issuer: %v", err)
-	}
-	if issuer != "gatorapi-access" {
-		return uuid.Nil, fmt.Errorf("bad issuer: %v", issuer)
-	}
-
-	idString, err := token.Claims.GetSubject()
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("unable to get subject: %v", err)
-	}
-
-	id, err := uuid.Parse(idString)
+	id, err := uuid.Parse(claims.Subject)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("unable to parse id string: %v", err)
 	}
@@ -72,3 +55,104 @@ func GetBearerToken(headers http.Header) (string, error) {
 
 	return splitAuth[1], nil
 }
+
+const passwordResetIssuer = "gatorapi-password-reset"
+
+// passwordClaims are for tokens that are only good while the password they were
+// issued for is unchanged
+type passwordClaims struct {
+	jwt.RegisteredClaims
+	// Fingerprint identifies the password hash the token was issued for, so the
+	// token stops working once the password has been changed
+	Fingerprint string `json:"pwd"`
+}
+
+// MakePasswordResetJWT issues a token that lets userID set a new password once.
+// It can't be used as an access token.
+func (ks *KeySet) MakePasswordResetJWT(userID uuid.UUID, hashedPassword string, expiresIn time.Duration) (string, error) {
+	return ks.makePasswordJWT(passwordResetIssuer, userID, hashedPassword, expiresIn)
+}
+
+// ValidatePasswordResetJWT returns the user the token was issued for and the
+// fingerprint of their password hash at the time
+func (ks *KeySet) ValidatePasswordResetJWT(tokenString string) (uuid.UUID, string, error) {
+	return ks.validatePasswordJWT(passwordResetIssuer, tokenString)
+}
+
+const readerIssuer = "gatorapi-reader"
+
+// readerClaims tie a Reader token to the password version it was issued for.
+// The version only changes when the password does, unlike the hash, which a
+// rehash at a higher bcrypt cost replaces.
+type readerClaims struct {
+	jwt.RegisteredClaims
+	PasswordVersion int64 `json:"pwv"`
+}
+
+// MakeReaderJWT issues the long lived token Google Reader clients get from
+// ClientLogin. It only works on the Reader API and stops working when the
+// password changes.
+func (ks *KeySet) MakeReaderJWT(userID uuid.UUID, passwordVersion int64, expiresIn time.Duration) (string, error) {
+	return ks.sign(readerClaims{
+		RegisteredClaims: ks.boundClaims(readerIssuer, userID, expiresIn),
+		PasswordVersion:  passwordVersion,
+	})
+}
+
+// ValidateReaderJWT returns the user the token was issued for and their password
+// version at the time
+func (ks *KeySet) ValidateReaderJWT(tokenString string) (uuid.UUID, int64, error) {
+	claims := readerClaims{}
+	id, err := ks.parseBound(readerIssuer, tokenString, &claims)
+	return id, claims.PasswordVersion, err
+}
+
+// makePasswordJWT issues a token tied to the password hash
+func (ks *KeySet) makePasswordJWT(issuer string, userID uuid.UUID, hashedPassword string, expiresIn time.Duration) (string, error) {
+	return ks.sign(passwordClaims{
+		RegisteredClaims: ks.boundClaims(issuer, userID, expiresIn),
+		Fingerprint:      PasswordFingerprint(hashedPassword),
+	})
+}
+
+func (ks *KeySet) validatePasswordJWT(issuer, tokenString string) (uuid.UUID, string, error) {
+	claims := passwordClaims{}
+	id, err := ks.parseBound(issuer, tokenString, &claims)
+	return id, claims.Fingerprint, err
+}
+
+// boundClaims are the registered claims of tokens bound to the user's password.
+// The issuer keeps each kind of token from being accepted as another.
+func (ks *KeySet) boundClaims(issuer string, userID uuid.UUID, expiresIn time.Duration) jwt.RegisteredClaims {
+	now := ks.now().UTC()
+	return jwt.RegisteredClaims{
+		Issuer:    issuer,
+		Audience:  jwt.ClaimStrings{ks.Audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+		Subject:   userID.String(),
+	}
+}
+
+func (ks *KeySet) parseBound(issuer, tokenString string, claims jwt.Claims) (uuid.UUID, error) {
+	err := ks.parse(tokenString, claims, issuer)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("unable to parse claims: %v", err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	id, err := uuid.Parse(subject)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("unable to parse id string: %v", err)
+	}
+
+	return id, nil
+}
+
+func PasswordFingerprint(hashedPassword string) string {
+	sum := sha256.Sum256([]byte(hashedPassword))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithms a Key can sign with
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// Key is a JWT signing key, identified in token headers by its kid
+type Key struct {
+	ID        string
+	Algorithm string
+	// VerifyUntil is when the key stops being accepted, if set. Retired keys are
+	// kept until the tokens they signed have expired.
+	VerifyUntil time.Time
+
+	signKey   any
+	verifyKey any
+}
+
+// NewHMACKey returns an HS256 key. An empty id is derived from the secret.
+func NewHMACKey(id string, secret []byte) Key {
+	if id == "" {
+		sum := sha256.Sum256(secret)
+		id = hex.EncodeToString(sum[:8])
+	}
+	return Key{ID: id, Algorithm: HS256, signKey: secret, verifyKey: secret}
+}
+
+// ParsePrivateKeyPEM parses an RSA (PKCS #1 or #8) or Ed25519 (PKCS #8) private
+// key, which sign with RS256 and EdDSA respectively. An empty id is derived from
+// the public key.
+func ParsePrivateKeyPEM(id string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.New("no PEM block found")
+	}
+
+	var private any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return Key{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("unable to parse private key: %v", err)
+	}
+
+	key := Key{ID: id, signKey: private}
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < 2048 {
+			return Key{}, fmt.Errorf("rsa keys must be at least 2048 bits, got %d", private.N.BitLen())
+		}
+		key.Algorithm = RS256
+		key.verifyKey = &private.PublicKey
+	case ed25519.PrivateKey:
+		key.Algorithm = EdDSA
+		key.verifyKey = private.Public()
+	default:
+		return Key{}, fmt.Errorf("unsupported private key type %T", private)
+	}
+
+	if key.ID == "" {
+		der, err := x509.MarshalPKIXPublicKey(key.verifyKey)
+		if err != nil {
+			return Key{}, fmt.Errorf("unable to marshal public key: %v", err)
+		}
+		sum := sha256.Sum256(der)
+		key.ID = hex.EncodeToString(sum[:8])
+	}
+	return key, nil
+}
+
+func (k Key) method() jwt.SigningMethod {
+	switch k.Algorithm {
+	case RS256:
+		return jwt.SigningMethodRS256
+	case EdDSA:
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodHS256
+}
+
+func (k Key) activeAt(t time.Time) bool {
+	return k.VerifyUntil.IsZero() || t.Before(k.VerifyUntil)
+}
+
+// KeySet signs tokens with one key and verifies them with any of its keys that
+// hasn't been retired, so keys can be rotated without invalidating tokens
+type KeySet struct {
+	Issuer   string
+	Audience string
+
+	now     func() time.Time
+	signing Key
+	keys    map[string]Key
+}
+
+// NewKeySet returns a key set that signs with the key named signingID, which may
+// be empty when there is only one key
+func NewKeySet(issuer, audience, signingID string, keys ...Key) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one key is required")
+	}
+	ks := &KeySet{
+		Issuer:   issuer,
+		Audience: audience,
+		now:      time.Now,
+		keys:     map[string]Key{},
+	}
+	for _, key := range keys {
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+	}
+
+	if signingID == "" {
+		if len(keys) > 1 {
+			return nil, errors.New("the signing key must be chosen when there are several keys")
+		}
+		signingID = keys[0].ID
+	}
+	signing, ok := ks.keys[signingID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found", signingID)
+	}
+	if !signing.VerifyUntil.IsZero() {
+		return nil, fmt.Errorf("signing key %q must not be retired", signingID)
+	}
+	ks.signing = signing
+	return ks, nil
+}
+
+// SigningKeyID is the kid new tokens are signed with
+func (ks *KeySet) SigningKeyID() string {
+	return ks.signing.ID
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method(), claims)
+	token.Header["kid"] = ks.signing.ID
+
+	signedString, err := token.SignedString(ks.signing.signKey)
+	if err != nil {
+		return "", fmt.Errorf("unable to sign string %v", err)
+	}
+
+	return signedString, nil
+}
+
+// keyFunc finds the key named by the token's kid, and only accepts the token when
+// it was signed with that key's algorithm
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("key %q does not sign with %v", kid, token.Method.Alg())
+	}
+	if !key.activeAt(ks.now()) {
+		return nil, fmt.Errorf("key %q has been retired", kid)
+	}
+	return key.verifyKey, nil
+}
+
+func (ks *KeySet) parse(tokenString string, claims jwt.Claims, issuer string) error {
+	_, err := jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		ks.keyFunc,
+		jwt.WithValidMethods([]string{HS256, RS256, EdDSA}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(ks.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(ks.now),
+	)
+	return err
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA modulus and exponent
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 curve and public key
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys that tokens may be verified with. HMAC keys are
+// secret and never included.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	now := ks.now()
+	for _, key := range ks.keys {
+		if !key.activeAt(now) {
+			continue
+		}
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Algorithm}
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	// Signing key first, then by kid so the document is stable
+	slices.SortFunc(jwks.Keys, func(a, b JWK) int {
+		switch {
+		case a.Kid == ks.signing.ID:
+			return -1
+		case b.Kid == ks.signing.ID:
+			return 1
+		}
+		return strings.Compare(a.Kid, b.Kid)
+	})
+	return jwks
+}
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	oldSecret = []byte("an-old-secret-that-is-at-least-32-chars")
+	newSecret = []byte("a-new-secret-that-is-at-least-32-chars")
+)
+
+func mustKeySet(t *testing.T, signingID string, keys ...Key) *KeySet {
+	t.Helper()
+	ks, err := NewKeySet("issuer", "audience", signingID, keys...)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	return ks
+}
+
+func ed25519Key(t *testing.T, id string) Key {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParsePrivateKeyPEM(id, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("ParsePrivateKeyPEM: %v", err)
+	}
+	return key
+}
+
+func rsaKey(t *testing.T, id string, bits int) (Key, error) {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)})
+	return ParsePrivateKeyPEM(id, data)
+}
+
+func TestKeySetRoundTrip(t *testing.T) {
+	rsa2048, err := rsaKey(t, "rsa", 2048)
+	if err != nil {
+		t.Fatalf("ParsePrivateKeyPEM: %v", err)
+	}
+	keys := []Key{NewHMACKey("hmac", newSecret), rsa2048, ed25519Key(t, "ed")}
+
+	for _, key := range keys {
+		t.Run(key.Algorithm, func(t *testing.T) {
+			ks := mustKeySet(t, key.ID, keys...)
+			id := uuid.New()
+			token, err := ks.MakeJWT(id, time.Hour)
+			if err != nil {
+				t.Fatalf("MakeJWT: %v", err)
+			}
+			got, err := ks.ValidateJWT(token)
+			if err != nil {
+				t.Fatalf("ValidateJWT: %v", err)
+			}
+			if got != id {
+				t.Errorf("subject: got %v, want %v", got, id)
+			}
+
+			parsed, _, _ := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			if parsed.Header["kid"] != key.ID || parsed.Header["alg"] != key.Algorithm {
+				t.Errorf("header: got %v, want kid %v and alg %v", parsed.Header, key.ID, key.Algorithm)
+			}
+		})
+	}
+
+	if _, err := rsaKey(t, "small", 1024); err == nil {
+		t.Error("1024 bit rsa key was accepted")
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	old := NewHMACKey("", oldSecret)
+	id := uuid.New()
+	token, err := mustKeySet(t, "", old).MakeJWT(id, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT: %v", err)
+	}
+
+	// The old key still verifies after a new one takes over signing
+	rotated := mustKeySet(t, NewHMACKey("", newSecret).ID, NewHMACKey("", newSecret), old)
+	if _, err := rotated.ValidateJWT(token); err != nil {
+		t.Errorf("token signed with the previous key: %v", err)
+	}
+
+	// and stops once it is retired
+	old.VerifyUntil = time.Now().Add(time.Minute)
+	retiring := mustKeySet(t, NewHMACKey("", newSecret).ID, NewHMACKey("", newSecret), old)
+	if _, err := retiring.ValidateJWT(token); err != nil {
+		t.Errorf("token before the key retires: %v", err)
+	}
+	retiring.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, err := retiring.ValidateJWT(token); err == nil {
+		t.Error("token signed with a retired key was accepted")
+	}
+
+	// Without the old key the token is rejected
+	if _, err := mustKeySet(t, "", NewHMACKey("", newSecret)).ValidateJWT(token); err == nil {
+		t.Error("token signed with an unknown key was accepted")
+	}
+
+	if _, err := NewKeySet("issuer", "audience", old.ID, old); err == nil {
+		t.Error("a retired key was accepted as the signing key")
+	}
+	if _, err := NewKeySet("issuer", "audience", "", old, NewHMACKey("", newSecret)); err == nil {
+		t.Error("several keys were accepted without a signing key")
+	}
+}
+
+func TestKeySetRejects(t *testing.T) {
+	ed := ed25519Key(t, "ed")
+	ks := mustKeySet(t, "", ed)
+	claims := jwt.RegisteredClaims{
+		Issuer:    "issuer",
+		Audience:  jwt.ClaimStrings{"audience"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		Subject:   uuid.NewString(),
+	}
+
+	// HS256 signed with the public key must not verify against an asymmetric key
+	public, err := x509.MarshalPKIXPublicKey(ed.verifyKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	confused.Header["kid"] = ed.ID
+	token, err := confused.SignedString(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.ValidateJWT(token); err == nil {
+		t.Error("HS256 token was accepted for an EdDSA key")
+	}
+
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+	unsigned.Header["kid"] = ed.ID
+	token, _ = unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if _, err := ks.ValidateJWT(token); err == nil {
+		t.Error("unsigned token was accepted")
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*jwt.RegisteredClaims, *jwt.Token)
+	}{
+		{"unknown kid", func(c *jwt.RegisteredClaims, tok *jwt.Token) { tok.Header["kid"] = "other" }},
+		{"no kid", func(c *jwt.RegisteredClaims, tok *jwt.Token) { delete(tok.Header, "kid") }},
+		{"wrong issuer", func(c *jwt.RegisteredClaims, tok *jwt.Token) { c.Issuer = "someone-else" }},
+		{"wrong audience", func(c *jwt.RegisteredClaims, tok *jwt.Token) { c.Audience = jwt.ClaimStrings{"other"} }},
+		{"no audience", func(c *jwt.RegisteredClaims, tok *jwt.Token) { c.Audience = nil }},
+		{"no expiry", func(c *jwt.RegisteredClaims, tok *jwt.Token) { c.ExpiresAt = nil }},
+		{"expired", func(c *jwt.RegisteredClaims, tok *jwt.Token) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := claims
+			tok := jwt.NewWithClaims(jwt.SigningMethodEdDSA, &c)
+			tok.Header["kid"] = ed.ID
+			tt.modify(&c, tok)
+			token, err := tok.SignedString(ed.signKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ks.ValidateJWT(token); err == nil {
+				t.Error("token was accepted")
+			}
+		})
+	}
+
+	// Password reset and reader tokens are not access tokens, nor the other way round
+	reset, err := ks.MakePasswordResetJWT(uuid.New(), "hash", time.Hour)
+	if err != nil {
+		t.Fatalf("MakePasswordResetJWT: %v", err)
+	}
+	if _, err := ks.ValidateJWT(reset); err == nil {
+		t.Error("reset token was accepted as an access token")
+	}
+	access, _ := ks.MakeJWT(uuid.New(), time.Hour)
+	if _, _, err := ks.ValidatePasswordResetJWT(access); err == nil {
+		t.Error("access token was accepted as a reset token")
+	}
+	reader, _ := ks.MakeReaderJWT(uuid.New(), 0, time.Hour)
+	if _, err := ks.ValidateJWT(reader); err == nil {
+		t.Error("reader token was accepted as an access token")
+	}
+	if _, _, err := ks.ValidateReaderJWT(reset); err == nil {
+		t.Error("reset token was accepted as a reader token")
+	}
+}
+
+func TestKeySetJWKS(t *testing.T) {
+	rsa2048, err := rsaKey(t, "b-rsa", 2048)
+	if err != nil {
+		t.Fatalf("ParsePrivateKeyPEM: %v", err)
+	}
+	retired := ed25519Key(t, "a-retired")
+	retired.VerifyUntil = time.Now().Add(-time.Minute)
+	ks := mustKeySet(t, "z-ed", ed25519Key(t, "z-ed"), rsa2048, retired, NewHMACKey("c-hmac", newSecret))
+
+	var kids []string
+	for _, jwk := range ks.JWKS().Keys {
+		kids = append(kids, jwk.Kid)
+		if jwk.Kid == "b-rsa" && (jwk.Kty != "RSA" || jwk.E != "AQAB" || jwk.N == "") {
+			t.Errorf("rsa jwk: got %+v", jwk)
+		}
+		if jwk.Kid == "z-ed" && (jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.X == "") {
+			t.Errorf("ed25519 jwk: got %+v", jwk)
+		}
+	}
+	if got := strings.Join(kids, ","); got != "z-ed,b-rsa" {
+		t.Errorf("published keys: got %v, want z-ed,b-rsa", got)
+	}
+}
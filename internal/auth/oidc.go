@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+const oidcFlowIssuer = "gatorapi-oidc-flow"
+
+// OIDCConfig identifies gator to an OpenID Connect provider
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends users back to, the callback route
+	RedirectURL string
+	Scopes      []string
+}
+
+// OIDCProvider logs users in through an external OpenID Connect provider with the
+// authorization code flow and PKCE. The provider's discovery document is fetched
+// on first use and kept once it loads.
+type OIDCProvider struct {
+	config OIDCConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// OIDCFlow is what a login in progress must remember between sending the user to
+// the provider and their return: the state and nonce that tie the response to this
+// request, and the PKCE code verifier
+type OIDCFlow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// OIDCClaims is the identity the provider vouches for. Subject is stable and
+// unique per issuer; the rest are informational and may be empty.
+type OIDCClaims struct {
+	Issuer            string `json:"iss"`
+	Subject           string `json:"sub"`
+	PreferredUsername string `json:"preferred_username"`
+	Email             string `json:"email"`
+	Name              string `json:"name"`
+}
+
+type oidcFlowClaims struct {
+	jwt.RegisteredClaims
+	OIDCFlow
+}
+
+func NewOIDCProvider(config OIDCConfig, client *http.Client) *OIDCProvider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &OIDCProvider{config: config, client: client}
+}
+
+// Issuer is the provider's issuer URL, which scopes the subjects it issues
+func (p *OIDCProvider) Issuer() string {
+	return p.config.Issuer
+}
+
+// RedirectURL is the callback the provider sends users back to
+func (p *OIDCProvider) RedirectURL() string {
+	return p.config.RedirectURL
+}
+
+func (p *OIDCProvider) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, p.client), p.config.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to discover oidc provider: %v", err)
+	}
+	scopes := p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	p.oauth = &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  p.config.RedirectURL,
+		Scopes:       scopes,
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.config.ClientID})
+	return p.oauth, p.verifier, nil
+}
+
+// Start begins a login, returning the provider URL to send the user to and the
+// flow to hand back to Finish
+func (p *OIDCProvider) Start(ctx context.Context) (string, OIDCFlow, error) {
+	config, _, err := p.discover(ctx)
+	if err != nil {
+		return "", OIDCFlow{}, err
+	}
+
+	flow := OIDCFlow{Verifier: oauth2.GenerateVerifier()}
+	if flow.State, err = randomToken(); err != nil {
+		return "", OIDCFlow{}, err
+	}
+	if flow.Nonce, err = randomToken(); err != nil {
+		return "", OIDCFlow{}, err
+	}
+	url := config.AuthCodeURL(flow.State, oidc.Nonce(flow.Nonce), oauth2.S256ChallengeOption(flow.Verifier))
+	return url, flow, nil
+}
+
+// Finish redeems the authorization code from the provider's callback and returns
+// the verified identity from its ID token
+func (p *OIDCProvider) Finish(ctx context.Context, flow OIDCFlow, code string) (OIDCClaims, error) {
+	config, verifier, err := p.discover(ctx)
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+	ctx = oidc.ClientContext(ctx, p.client)
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return OIDCClaims{}, fmt.Errorf("unable to exchange code: %v", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return OIDCClaims{}, errors.New("token response has no id_token")
+	}
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return OIDCClaims{}, fmt.Errorf("unable to verify id token: %v", err)
+	}
+	if idToken.Nonce != flow.Nonce {
+		return OIDCClaims{}, errors.New("id token nonce does not match")
+	}
+
+	claims := OIDCClaims{}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return OIDCClaims{}, fmt.Errorf("unable to parse id token claims: %v", err)
+	}
+	return claims, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate random token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// MakeOIDCFlowJWT signs flow so it can be kept by the user's browser until they
+// return from the provider
+func (ks *KeySet) MakeOIDCFlowJWT(flow OIDCFlow, expiresIn time.Duration) (string, error) {
+	now := ks.now().UTC()
+	return ks.sign(oidcFlowClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    oidcFlowIssuer,
+			Audience:  jwt.ClaimStrings{ks.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+		},
+		OIDCFlow: flow,
+	})
+}
+
+func (ks *KeySet) ValidateOIDCFlowJWT(tokenString string) (OIDCFlow, error) {
+	claims := oidcFlowClaims{}
+	err := ks.parse(tokenString, &claims, oidcFlowIssuer)
+	if err != nil {
+		return OIDCFlow{}, fmt.Errorf("unable to parse claims: %v", err)
+	}
+	return claims.OIDCFlow, nil
+}
@@ -0,0 +1,569 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	MinJWTSecretLength = 32
+	redacted           = "REDACTED"
+)
+
+type Config struct {
+	Port         int    `yaml:"port" toml:"port"`
+	DBConnection string `yaml:"db_connection" toml:"db_connection"`
+	JWTSecret    string `yaml:"jwt_secret" toml:"jwt_secret"`
+	LogLevel     string `yaml:"log_level" toml:"log_level"`
+	AutoMigrate  bool   `yaml:"auto_migrate" toml:"auto_migrate"`
+	// BcryptCost is the cost new password hashes use. Older hashes with a lower
+	// cost are rehashed when their user logs in.
+	BcryptCost int `yaml:"bcrypt_cost" toml:"bcrypt_cost"`
+	// GRPCPort serves the gRPC API alongside the HTTP API when it isn't 0
+	GRPCPort        int             `yaml:"grpc_port" toml:"grpc_port"`
+	Fetch           Fetch           `yaml:"fetch" toml:"fetch"`
+	Recommendations Recommendations `yaml:"recommendations" toml:"recommendations"`
+	Server          Server          `yaml:"server" toml:"server"`
+	RateLimit       RateLimit       `yaml:"rate_limit" toml:"rate_limit"`
+	JWT             JWT             `yaml:"jwt" toml:"jwt"`
+	OIDC            OIDC            `yaml:"oidc" toml:"oidc"`
+}
+
+type Fetch struct {
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+	Timeout  time.Duration `yaml:"timeout" toml:"timeout"`
+	Workers  int           `yaml:"workers" toml:"workers"`
+}
+
+// Recommendations configures how often feed recommendations are recomputed from
+// who follows what
+type Recommendations struct {
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+}
+
+type Server struct {
+	ReadTimeout       time.Duration `yaml:"read_timeout" toml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" toml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" toml:"max_header_bytes"`
+}
+
+// RateLimit configures per client request limits. Requests per Window is the
+// default limit; login, registration and aggregation have tighter fixed limits.
+type RateLimit struct {
+	Enabled  bool          `yaml:"enabled" toml:"enabled"`
+	Requests int           `yaml:"requests" toml:"requests"`
+	Window   time.Duration `yaml:"window" toml:"window"`
+	// TrustProxy takes the client IP from X-Forwarded-For
+	TrustProxy bool `yaml:"trust_proxy" toml:"trust_proxy"`
+	// A user name is locked out for LoginLockout after LoginMaxFailures failed
+	// logins in a row, doubling with every further failure
+	LoginMaxFailures int           `yaml:"login_max_failures" toml:"login_max_failures"`
+	LoginLockout     time.Duration `yaml:"login_lockout" toml:"login_lockout"`
+}
+
+// JWT configures the keys tokens are signed and verified with. JWTSecret is an
+// HS256 key; further keys let secrets be rotated and tokens be signed with RS256
+// or EdDSA instead.
+type JWT struct {
+	Issuer   string `yaml:"issuer" toml:"issuer"`
+	Audience string `yaml:"audience" toml:"audience"`
+	// PrivateKeyFile is a PEM encoded RSA or Ed25519 private key. New tokens are
+	// signed with it instead of JWTSecret.
+	PrivateKeyFile string `yaml:"private_key_file,omitempty" toml:"private_key_file,omitempty"`
+	// PreviousSecrets verify tokens but never sign new ones, so JWTSecret can be
+	// replaced without logging everyone out
+	PreviousSecrets []string `yaml:"previous_secrets,omitempty" toml:"previous_secrets,omitempty"`
+	Keys            []JWTKey `yaml:"keys,omitempty" toml:"keys,omitempty"`
+	// SigningKey is the id of the key new tokens are signed with, when it isn't
+	// PrivateKeyFile or JWTSecret
+	SigningKey string `yaml:"signing_key,omitempty" toml:"signing_key,omitempty"`
+}
+
+// JWTKey is a named key with either a Secret or a PrivateKeyFile. Once
+// VerifyUntil has passed the key is no longer accepted.
+type JWTKey struct {
+	ID             string    `yaml:"id" toml:"id"`
+	Secret         string    `yaml:"secret,omitempty" toml:"secret,omitempty"`
+	PrivateKeyFile string    `yaml:"private_key_file,omitempty" toml:"private_key_file,omitempty"`
+	VerifyUntil    time.Time `yaml:"verify_until,omitempty" toml:"verify_until,omitempty"`
+}
+
+// OIDC enables login through an external OpenID Connect provider when Issuer is
+// set
+type OIDC struct {
+	Issuer       string `yaml:"issuer,omitempty" toml:"issuer,omitempty"`
+	ClientID     string `yaml:"client_id,omitempty" toml:"client_id,omitempty"`
+	ClientSecret string `yaml:"client_secret,omitempty" toml:"client_secret,omitempty"`
+	// RedirectURL is this server's /api/oidc/callback as users' browsers reach it,
+	// and must be registered with the provider
+	RedirectURL string `yaml:"redirect_url,omitempty" toml:"redirect_url,omitempty"`
+	// Scopes default to openid, profile and email
+	Scopes []string `yaml:"scopes,omitempty" toml:"scopes,omitempty"`
+}
+
+func Default() Config {
+	return Config{
+		Port:       8080,
+		LogLevel:   "info",
+		BcryptCost: bcrypt.DefaultCost,
+		Fetch: Fetch{
+			Interval: time.Minute,
+			Timeout:  30 * time.Second,
+			Workers:  4,
+		},
+		Recommendations: Recommendations{
+			Interval: time.Hour,
+		},
+		Server: Server{
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+		},
+		RateLimit: RateLimit{
+			Enabled:          true,
+			Requests:         120,
+			Window:           time.Minute,
+			LoginMaxFailures: 5,
+			LoginLockout:     time.Minute,
+		},
+		JWT: JWT{
+			Issuer:   "gatorapi-access",
+			Audience: "gatorapi",
+		},
+	}
+}
+
+// Load builds the configuration from defaults, an optional YAML or TOML file, the
+// environment (including a .env file) and finally command line flags, in increasing
+// order of precedence. Configuration flags are registered on fs before args are
+// parsed, so callers may add flags of their own.
+func Load(fs *flag.FlagSet, args []string) (Config, error) {
+	cfg := Default()
+
+	configFile := fs.String("config", "", "path to a YAML or TOML config file (env CONFIG_FILE)")
+	port := fs.Int("port", 0, "port to serve on (env PORT)")
+	grpcPort := fs.Int("grpc-port", 0, "port to serve the gRPC API on, or 0 for none (env GRPC_PORT)")
+	dbConnection := fs.String("db-connection", "", "database connection string (env DB_CONNECTION)")
+	logLevel := fs.String("log-level", "", "debug, info, warn or error (env LOG_LEVEL)")
+	autoMigrate := fs.Bool("auto-migrate", false, "apply pending migrations on startup (env AUTO_MIGRATE)")
+	fetchInterval := fs.Duration("fetch-interval", 0, "how often due feeds are fetched (env FETCH_INTERVAL)")
+	fetchTimeout := fs.Duration("fetch-timeout", 0, "timeout for a single feed fetch (env FETCH_TIMEOUT)")
+	fetchWorkers := fs.Int("fetch-workers", 0, "number of concurrent feed fetchers (env FETCH_WORKERS)")
+	recommendationsInterval := fs.Duration("recommendations-interval", 0, "how often feed recommendations are recomputed (env RECOMMENDATIONS_INTERVAL)")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 0, "time allowed to drain on shutdown (env SHUTDOWN_TIMEOUT)")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	godotenv.Load()
+
+	path := *configFile
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := loadEnv(&cfg); err != nil {
+		return Config{}, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Port = *port
+		case "grpc-port":
+			cfg.GRPCPort = *grpcPort
+		case "db-connection":
+			cfg.DBConnection = *dbConnection
+		case "log-level":
+			cfg.LogLevel = *logLevel
+		case "auto-migrate":
+			cfg.AutoMigrate = *autoMigrate
+		case "fetch-interval":
+			cfg.Fetch.Interval = *fetchInterval
+		case "fetch-timeout":
+			cfg.Fetch.Timeout = *fetchTimeout
+		case "fetch-workers":
+			cfg.Fetch.Workers = *fetchWorkers
+		case "recommendations-interval":
+			cfg.Recommendations.Interval = *recommendationsInterval
+		case "shutdown-timeout":
+			cfg.Server.ShutdownTimeout = *shutdownTimeout
+		}
+	})
+
+	return cfg, nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file type: %v", path)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to parse config file %v: %v", path, err)
+	}
+	return nil
+}
+
+func loadEnv(cfg *Config) error {
+	var errs []error
+	setString := func(key string, dst *string) {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			*dst = value
+		}
+	}
+	setInt := func(key string, dst *int) {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%v: %v is not an integer", key, value))
+				return
+			}
+			*dst = parsed
+		}
+	}
+	setBool := func(key string, dst *bool) {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%v: %v is not a boolean", key, value))
+				return
+			}
+			*dst = parsed
+		}
+	}
+	setDuration := func(key string, dst *time.Duration) {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%v: %v is not a duration", key, value))
+				return
+			}
+			*dst = parsed
+		}
+	}
+
+	setInt("PORT", &cfg.Port)
+	setInt("GRPC_PORT", &cfg.GRPCPort)
+	setString("DB_CONNECTION", &cfg.DBConnection)
+	setString("JWT_SECRET", &cfg.JWTSecret)
+	setString("LOG_LEVEL", &cfg.LogLevel)
+	setBool("AUTO_MIGRATE", &cfg.AutoMigrate)
+	setInt("BCRYPT_COST", &cfg.BcryptCost)
+	setDuration("FETCH_INTERVAL", &cfg.Fetch.Interval)
+	setDuration("FETCH_TIMEOUT", &cfg.Fetch.Timeout)
+	setInt("FETCH_WORKERS", &cfg.Fetch.Workers)
+	setDuration("RECOMMENDATIONS_INTERVAL", &cfg.Recommendations.Interval)
+	setDuration("READ_TIMEOUT", &cfg.Server.ReadTimeout)
+	setDuration("READ_HEADER_TIMEOUT", &cfg.Server.ReadHeaderTimeout)
+	setDuration("WRITE_TIMEOUT", &cfg.Server.WriteTimeout)
+	setDuration("IDLE_TIMEOUT", &cfg.Server.IdleTimeout)
+	setDuration("SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+	setInt("MAX_HEADER_BYTES", &cfg.Server.MaxHeaderBytes)
+	setBool("RATE_LIMIT_ENABLED", &cfg.RateLimit.Enabled)
+	setInt("RATE_LIMIT_REQUESTS", &cfg.RateLimit.Requests)
+	setDuration("RATE_LIMIT_WINDOW", &cfg.RateLimit.Window)
+	setBool("TRUST_PROXY", &cfg.RateLimit.TrustProxy)
+	setInt("LOGIN_MAX_FAILURES", &cfg.RateLimit.LoginMaxFailures)
+	setDuration("LOGIN_LOCKOUT", &cfg.RateLimit.LoginLockout)
+	setString("JWT_ISSUER", &cfg.JWT.Issuer)
+	setString("JWT_AUDIENCE", &cfg.JWT.Audience)
+	setString("JWT_PRIVATE_KEY_FILE", &cfg.JWT.PrivateKeyFile)
+	setString("JWT_SIGNING_KEY", &cfg.JWT.SigningKey)
+	if value, ok := os.LookupEnv("JWT_PREVIOUS_SECRETS"); ok && value != "" {
+		cfg.JWT.PreviousSecrets = strings.Split(value, ",")
+	}
+	setString("OIDC_ISSUER", &cfg.OIDC.Issuer)
+	setString("OIDC_CLIENT_ID", &cfg.OIDC.ClientID)
+	setString("OIDC_CLIENT_SECRET", &cfg.OIDC.ClientSecret)
+	setString("OIDC_REDIRECT_URL", &cfg.OIDC.RedirectURL)
+	if value, ok := os.LookupEnv("OIDC_SCOPES"); ok && value != "" {
+		cfg.OIDC.Scopes = strings.Split(value, ",")
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate reports every problem with the configuration at once
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is out of range 1-65535", c.Port))
+	}
+	if c.GRPCPort < 0 || c.GRPCPort > 65535 {
+		errs = append(errs, fmt.Errorf("grpc port %d is out of range 1-65535", c.GRPCPort))
+	} else if c.GRPCPort != 0 && c.GRPCPort == c.Port {
+		errs = append(errs, fmt.Errorf("grpc port %d must differ from port", c.GRPCPort))
+	}
+	if err := ValidateDSN(c.DBConnection); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, c.JWT.validate(c.JWTSecret)...)
+	errs = append(errs, c.OIDC.validate()...)
+	if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("bcrypt cost %d is out of range %d-%d", c.BcryptCost, bcrypt.MinCost, bcrypt.MaxCost))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		errs = append(errs, fmt.Errorf("invalid log level %q", c.LogLevel))
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"fetch interval", c.Fetch.Interval},
+		{"fetch timeout", c.Fetch.Timeout},
+		{"recommendations interval", c.Recommendations.Interval},
+		{"read timeout", c.Server.ReadTimeout},
+		{"read header timeout", c.Server.ReadHeaderTimeout},
+		{"write timeout", c.Server.WriteTimeout},
+		{"idle timeout", c.Server.IdleTimeout},
+		{"shutdown timeout", c.Server.ShutdownTimeout},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%v must be positive, got %v", d.name, d.value))
+		}
+	}
+	if c.Fetch.Workers < 1 {
+		errs = append(errs, fmt.Errorf("fetch workers must be at least 1, got %d", c.Fetch.Workers))
+	}
+	if c.Server.MaxHeaderBytes < 1 {
+		errs = append(errs, fmt.Errorf("max header bytes must be at least 1, got %d", c.Server.MaxHeaderBytes))
+	}
+	if c.RateLimit.Enabled {
+		if c.RateLimit.Requests < 1 {
+			errs = append(errs, fmt.Errorf("rate limit requests must be at least 1, got %d", c.RateLimit.Requests))
+		}
+		if c.RateLimit.Window <= 0 {
+			errs = append(errs, fmt.Errorf("rate limit window must be positive, got %v", c.RateLimit.Window))
+		}
+		if c.RateLimit.LoginMaxFailures < 1 {
+			errs = append(errs, fmt.Errorf("login max failures must be at least 1, got %d", c.RateLimit.LoginMaxFailures))
+		}
+		if c.RateLimit.LoginLockout <= 0 {
+			errs = append(errs, fmt.Errorf("login lockout must be positive, got %v", c.RateLimit.LoginLockout))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (j JWT) validate(secret string) []error {
+	var errs []error
+	if secret == "" && j.PrivateKeyFile == "" && len(j.Keys) == 0 {
+		errs = append(errs, errors.New("jwt secret is required"))
+	} else if secret != "" && len(secret) < MinJWTSecretLength {
+		errs = append(errs, fmt.Errorf("jwt secret must be at least %d characters", MinJWTSecretLength))
+	}
+	if j.Issuer == "" {
+		errs = append(errs, errors.New("jwt issuer is required"))
+	}
+	if j.Audience == "" {
+		errs = append(errs, errors.New("jwt audience is required"))
+	}
+	for i, previous := range j.PreviousSecrets {
+		if len(previous) < MinJWTSecretLength {
+			errs = append(errs, fmt.Errorf("previous jwt secret %d must be at least %d characters", i+1, MinJWTSecretLength))
+		}
+	}
+	for _, key := range j.Keys {
+		switch {
+		case key.ID == "":
+			errs = append(errs, errors.New("jwt keys must have an id"))
+		case (key.Secret == "") == (key.PrivateKeyFile == ""):
+			errs = append(errs, fmt.Errorf("jwt key %q needs exactly one of secret and private_key_file", key.ID))
+		case key.Secret != "" && len(key.Secret) < MinJWTSecretLength:
+			errs = append(errs, fmt.Errorf("jwt key %q secret must be at least %d characters", key.ID, MinJWTSecretLength))
+		}
+	}
+	return errs
+}
+
+func (o OIDC) validate() []error {
+	if o.Issuer == "" {
+		return nil
+	}
+	var errs []error
+	for _, u := range []struct{ name, value string }{{"oidc issuer", o.Issuer}, {"oidc redirect url", o.RedirectURL}} {
+		parsed, err := url.Parse(u.value)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("%v must be an absolute http or https URL, got %q", u.name, u.value))
+		}
+	}
+	if o.ClientID == "" {
+		errs = append(errs, errors.New("oidc client id is required"))
+	}
+	return errs
+}
+
+// ValidateDSN checks that dsn looks like a connection string for a supported
+// database: a sqlite:// path, or a Postgres URL or key=value string
+func ValidateDSN(dsn string) error {
+	if dsn == "" {
+		return errors.New("db connection string is required")
+	}
+	for _, prefix := range []string{"sqlite://", "sqlite:"} {
+		if path, ok := strings.CutPrefix(dsn, prefix); ok {
+			if path == "" {
+				return errors.New("sqlite connection string has no path")
+			}
+			return nil
+		}
+	}
+	if !strings.Contains(dsn, "://") {
+		// key=value connection strings
+		if !strings.Contains(dsn, "=") {
+			return errors.New("db connection string is neither a URL nor key=value pairs")
+		}
+		return nil
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return fmt.Errorf("invalid db connection URL: %v", err)
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return fmt.Errorf("unsupported db connection scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("db connection URL has no host")
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration that is safe to print
+func (c Config) Redacted() Config {
+	if c.JWTSecret != "" {
+		c.JWTSecret = redacted
+	}
+	if len(c.JWT.PreviousSecrets) > 0 {
+		c.JWT.PreviousSecrets = make([]string, len(c.JWT.PreviousSecrets))
+		for i := range c.JWT.PreviousSecrets {
+			c.JWT.PreviousSecrets[i] = redacted
+		}
+	}
+	if len(c.JWT.Keys) > 0 {
+		keys := make([]JWTKey, len(c.JWT.Keys))
+		for i, key := range c.JWT.Keys {
+			if key.Secret != "" {
+				key.Secret = redacted
+			}
+			keys[i] = key
+		}
+		c.JWT.Keys = keys
+	}
+	if c.OIDC.ClientSecret != "" {
+		c.OIDC.ClientSecret = redacted
+	}
+	c.DBConnection = redactDSN(c.DBConnection)
+	return c
+}
+
+func redactDSN(dsn string) string {
+	if strings.HasPrefix(dsn, "sqlite:") {
+		return dsn
+	}
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return redacted
+		}
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		if query := u.Query(); query.Has("password") {
+			query.Set("password", redacted)
+			u.RawQuery = query.Encode()
+		}
+		return u.String()
+	}
+	return redactKeyValues(dsn)
+}
+
+// redactKeyValues replaces the password in a key=value connection string. Values
+// may be single quoted, with backslash escapes, and there may be spaces around =.
+func redactKeyValues(dsn string) string {
+	var out strings.Builder
+	for rest := dsn; rest != ""; {
+		// Copy the key and the = after it
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			out.WriteString(rest)
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		out.WriteString(rest[:eq+1])
+		rest = rest[eq+1:]
+		trimmed := strings.TrimLeft(rest, " \t")
+		out.WriteString(rest[:len(rest)-len(trimmed)])
+		rest = trimmed
+
+		// and find the end of the value
+		end := 0
+		if strings.HasPrefix(rest, "'") {
+			for end = 1; end < len(rest); end++ {
+				if rest[end] == '\\' {
+					end++
+				} else if rest[end] == '\'' {
+					end++
+					break
+				}
+			}
+			end = min(end, len(rest))
+		} else if end = strings.IndexAny(rest, " \t"); end < 0 {
+			end = len(rest)
+		}
+		if key == "password" {
+			out.WriteString(redacted)
+		} else {
+			out.WriteString(rest[:end])
+		}
+		rest = rest[end:]
+	}
+	return out.String()
+}
+
+// String renders the redacted configuration as YAML
+func (c Config) String() string {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("unable to render config: %v", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,44 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/imeltsner/gator-api/internal/database"
+	"github.com/imeltsner/gator-api/internal/database/databasetest"
+	_ "github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+)
+
+// TestConformance runs against a disposable Postgres database named by
+// GATOR_TEST_POSTGRES_DSN. Every table in it is dropped between subtests.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("GATOR_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("GATOR_TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, os.DirFS("../../sql/schema"))
+	if err != nil {
+		t.Fatalf("create migration provider: %v", err)
+	}
+
+	databasetest.Run(t, func(t *testing.T) database.Querier {
+		ctx := context.Background()
+		if _, err := provider.DownTo(ctx, 0); err != nil {
+			t.Fatalf("reset schema: %v", err)
+		}
+		if _, err := provider.Up(ctx); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		return database.New(db)
+	})
+}
@@ -0,0 +1,1193 @@
+// Package databasetest holds the conformance suite that every database.Querier
+// implementation must pass, so the backends behave the same behind the handlers.
+package databasetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/database"
+)
+
+// Run runs the conformance suite. newQuerier must return an empty, fully migrated
+// store each time it is called.
+func Run(t *testing.T, newQuerier func(t *testing.T) database.Querier) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, q database.Querier)
+	}{
+		{"Users", testUsers},
+		{"UniqueViolations", testUniqueViolations},
+		{"Feeds", testFeeds},
+		{"FeedFollows", testFeedFollows},
+		{"DeleteUserCascades", testDeleteUserCascades},
+		{"DeleteUsers", testDeleteUsers},
+		{"PostsForUser", testPostsForUser},
+		{"NextFeedsToFetch", testNextFeedsToFetch},
+		{"APIKeys", testAPIKeys},
+		{"UserIdentities", testUserIdentities},
+		{"Items", testItems},
+		{"SeqsNotReused", testSeqsNotReused},
+		{"FeverAPIKeys", testFeverAPIKeys},
+		{"GetByIDs", testGetByIDs},
+		{"ListFeeds", testListFeeds},
+		{"FeedTags", testFeedTags},
+		{"FeedRecommendations", testFeedRecommendations},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newQuerier(t))
+		})
+	}
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func createUser(t *testing.T, q database.Querier, name string) database.User {
+	t.Helper()
+	user, err := q.CreateUser(context.Background(), database.CreateUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      now(),
+		UpdatedAt:      now(),
+		Name:           name,
+		HashedPassword: "hash-" + name,
+	})
+	if err != nil {
+		t.Fatalf("CreateUser(%q): %v", name, err)
+	}
+	return user
+}
+
+func createFeed(t *testing.T, q database.Querier, userID uuid.UUID, url string) database.Feed {
+	t.Helper()
+	feed, err := q.CreateFeed(context.Background(), database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UpdatedAt: now(),
+		Title:     "Title of " + url,
+		Url:       url,
+		UserID:    userID,
+	})
+	if err != nil {
+		t.Fatalf("CreateFeed(%q): %v", url, err)
+	}
+	return feed
+}
+
+func follow(t *testing.T, q database.Querier, userID, feedID uuid.UUID) database.CreateFeedFollowRow {
+	t.Helper()
+	row, err := q.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UpdatedAt: now(),
+		UserID:    userID,
+		FeedID:    feedID,
+	})
+	if err != nil {
+		t.Fatalf("CreateFeedFollow: %v", err)
+	}
+	return row
+}
+
+func createPost(t *testing.T, q database.Querier, feedID uuid.UUID, url string, publishedAt sql.NullTime) database.Post {
+	t.Helper()
+	post, err := q.CreatePost(context.Background(), database.CreatePostParams{
+		ID:          uuid.New(),
+		CreatedAt:   now(),
+		UpdatedAt:   now(),
+		Title:       url,
+		Url:         url,
+		Description: sql.NullString{String: "about " + url, Valid: true},
+		PublishedAt: publishedAt,
+		FeedID:      feedID,
+	})
+	if err != nil {
+		t.Fatalf("CreatePost(%q): %v", url, err)
+	}
+	return post
+}
+
+func testUsers(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	createUser(t, q, "bob")
+
+	got, err := q.GetUserByID(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if got.ID != alice.ID || got.Name != "alice" || got.HashedPassword != "hash-alice" {
+		t.Errorf("GetUserByID: got %+v, want %+v", got, alice)
+	}
+	if !got.CreatedAt.Equal(alice.CreatedAt) {
+		t.Errorf("GetUserByID created_at: got %v, want %v", got.CreatedAt, alice.CreatedAt)
+	}
+
+	got, err = q.GetUserByName(ctx, "alice")
+	if err != nil || got.ID != alice.ID {
+		t.Errorf("GetUserByName: got %+v, %v", got, err)
+	}
+	name, err := q.GetUserNameByID(ctx, alice.ID)
+	if err != nil || name != "alice" {
+		t.Errorf("GetUserNameByID: got %q, %v", name, err)
+	}
+
+	users, err := q.GetUsers(ctx)
+	if err != nil || len(users) != 2 {
+		t.Errorf("GetUsers: got %d users, %v; want 2", len(users), err)
+	}
+
+	if _, err := q.GetUserByID(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserByID of missing user: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := q.GetUserByName(ctx, "nobody"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserByName of missing user: got %v, want sql.ErrNoRows", err)
+	}
+
+	_, err = q.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), CreatedAt: now(), UpdatedAt: now(), Name: strings.Repeat("a", 51)})
+	if err == nil {
+		t.Error("CreateUser with a 51 character name: got nil error")
+	}
+
+	updatedAt := now().Add(time.Minute)
+	err = q.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{ID: alice.ID, HashedPassword: "new-hash", UpdatedAt: updatedAt})
+	if err != nil {
+		t.Fatalf("UpdateUserPassword: %v", err)
+	}
+	got, _ = q.GetUserByID(ctx, alice.ID)
+	if got.HashedPassword != "new-hash" || got.UpdatedAt.Sub(updatedAt).Abs() > time.Millisecond || !got.CreatedAt.Equal(alice.CreatedAt) || got.PasswordVersion != 1 {
+		t.Errorf("user after UpdateUserPassword: got %+v", got)
+	}
+	err = q.RehashUserPassword(ctx, database.RehashUserPasswordParams{ID: alice.ID, HashedPassword: "rehashed", UpdatedAt: updatedAt})
+	if err != nil {
+		t.Fatalf("RehashUserPassword: %v", err)
+	}
+	got, _ = q.GetUserByID(ctx, alice.ID)
+	if got.HashedPassword != "rehashed" || got.PasswordVersion != 1 {
+		t.Errorf("user after RehashUserPassword: got %+v, want the password version unchanged", got)
+	}
+	bob, _ := q.GetUserByName(ctx, "bob")
+	if bob.HashedPassword != "hash-bob" {
+		t.Errorf("UpdateUserPassword changed another user: got %q", bob.HashedPassword)
+	}
+}
+
+func testUniqueViolations(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	feed := createFeed(t, q, alice.ID, "https://example.com/rss")
+	follow(t, q, alice.ID, feed.ID)
+	createPost(t, q, feed.ID, "https://example.com/1", sql.NullTime{})
+
+	_, err := q.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), CreatedAt: now(), UpdatedAt: now(), Name: "alice"})
+	if !database.IsUniqueViolation(err) {
+		t.Errorf("duplicate user name: got %v, want unique violation", err)
+	}
+
+	_, err = q.CreateFeed(ctx, database.CreateFeedParams{ID: uuid.New(), CreatedAt: now(), UpdatedAt: now(), Url: feed.Url, UserID: alice.ID})
+	if !database.IsUniqueViolation(err) {
+		t.Errorf("duplicate feed url: got %v, want unique violation", err)
+	}
+
+	_, err = q.CreateFeedFollow(ctx, database.CreateFeedFollowParams{ID: uuid.New(), CreatedAt: now(), UpdatedAt: now(), UserID: alice.ID, FeedID: feed.ID})
+	if !database.IsUniqueViolation(err) {
+		t.Errorf("duplicate follow: got %v, want unique violation", err)
+	}
+
+	_, err = q.CreatePost(ctx, database.CreatePostParams{ID: uuid.New(), CreatedAt: now(), UpdatedAt: now(), Url: "https://example.com/1", FeedID: feed.ID})
+	if !database.IsUniqueViolation(err) {
+		t.Errorf("duplicate post url: got %v, want unique violation", err)
+	}
+
+	_, err = q.CreateFeed(ctx, database.CreateFeedParams{ID: uuid.New(), CreatedAt: now(), UpdatedAt: now(), Url: "https://other.example.com", UserID: uuid.New()})
+	if err == nil || database.IsUniqueViolation(err) {
+		t.Errorf("feed for missing user: got %v, want foreign key error", err)
+	}
+}
+
+func testFeeds(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	feed := createFeed(t, q, alice.ID, "https://example.com/rss")
+	createFeed(t, q, alice.ID, "https://example.com/atom")
+
+	if feed.LastFetchedAt.Valid || feed.LastSuccessfulFetchAt.Valid {
+		t.Errorf("new feed: got last_fetched_at %v and last_successful_fetch_at %v, want NULL", feed.LastFetchedAt, feed.LastSuccessfulFetchAt)
+	}
+
+	got, err := q.GetFeedByID(ctx, feed.ID)
+	if err != nil || got.Url != feed.Url || got.UserID != alice.ID || got.Title != feed.Title {
+		t.Errorf("GetFeedByID: got %+v, %v", got, err)
+	}
+	got, err = q.GetFeedByURL(ctx, feed.Url)
+	if err != nil || got.ID != feed.ID {
+		t.Errorf("GetFeedByURL: got %+v, %v", got, err)
+	}
+	if _, err := q.GetFeedByURL(ctx, "https://missing.example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetFeedByURL of missing feed: got %v, want sql.ErrNoRows", err)
+	}
+
+	feeds, err := q.GetFeeds(ctx)
+	if err != nil || len(feeds) != 2 {
+		t.Errorf("GetFeeds: got %d feeds, %v; want 2", len(feeds), err)
+	}
+
+	fetchedAt := now()
+	err = q.MarkFeedFetched(ctx, database.MarkFeedFetchedParams{
+		LastFetchedAt: sql.NullTime{Time: fetchedAt, Valid: true},
+		UpdatedAt:     fetchedAt,
+		ID:            feed.ID,
+	})
+	if err != nil {
+		t.Fatalf("MarkFeedFetched: %v", err)
+	}
+	got, _ = q.GetFeedByID(ctx, feed.ID)
+	if !got.LastFetchedAt.Valid || got.LastFetchedAt.Time.Sub(fetchedAt).Abs() > time.Millisecond {
+		t.Errorf("last_fetched_at after MarkFeedFetched: got %v, want %v", got.LastFetchedAt, fetchedAt)
+	}
+	if got.LastSuccessfulFetchAt.Valid {
+		t.Errorf("last_successful_fetch_at after MarkFeedFetched: got %v, want NULL", got.LastSuccessfulFetchAt)
+	}
+
+	err = q.MarkFeedFetchSucceeded(ctx, database.MarkFeedFetchSucceededParams{
+		LastSuccessfulFetchAt: sql.NullTime{Time: fetchedAt, Valid: true},
+		ID:                    feed.ID,
+	})
+	if err != nil {
+		t.Fatalf("MarkFeedFetchSucceeded: %v", err)
+	}
+	got, _ = q.GetFeedByID(ctx, feed.ID)
+	if !got.LastSuccessfulFetchAt.Valid || got.LastSuccessfulFetchAt.Time.Sub(fetchedAt).Abs() > time.Millisecond {
+		t.Errorf("last_successful_fetch_at after MarkFeedFetchSucceeded: got %v, want %v", got.LastSuccessfulFetchAt, fetchedAt)
+	}
+}
+
+func testFeedFollows(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	bob := createUser(t, q, "bob")
+	feed := createFeed(t, q, alice.ID, "https://example.com/rss")
+
+	row := follow(t, q, bob.ID, feed.ID)
+	if row.FeedTitle != feed.Title || row.UserName != "bob" || row.UserID != bob.ID || row.FeedID != feed.ID {
+		t.Errorf("CreateFeedFollow: got %+v, want feed title %q and follower bob", row, feed.Title)
+	}
+
+	follows, err := q.GetFeedFollowsForUser(ctx, bob.ID)
+	if err != nil || len(follows) != 1 {
+		t.Fatalf("GetFeedFollowsForUser: got %d follows, %v; want 1", len(follows), err)
+	}
+	// user_name is the name of the feed's creator
+	if follows[0].FeedTitle != feed.Title || follows[0].UserName != "alice" {
+		t.Errorf("GetFeedFollowsForUser: got %+v, want feed title %q created by alice", follows[0], feed.Title)
+	}
+
+	err = q.DeleteFeedFollow(ctx, database.DeleteFeedFollowParams{UserID: bob.ID, FeedID: feed.ID})
+	if err != nil {
+		t.Fatalf("DeleteFeedFollow: %v", err)
+	}
+	follows, _ = q.GetFeedFollowsForUser(ctx, bob.ID)
+	if len(follows) != 0 {
+		t.Errorf("follows after DeleteFeedFollow: got %d, want 0", len(follows))
+	}
+	err = q.DeleteFeedFollow(ctx, database.DeleteFeedFollowParams{UserID: bob.ID, FeedID: feed.ID})
+	if err != nil {
+		t.Errorf("DeleteFeedFollow of missing follow: got %v, want nil", err)
+	}
+}
+
+func testDeleteUserCascades(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	bob := createUser(t, q, "bob")
+	aliceFeed := createFeed(t, q, alice.ID, "https://alice.example.com/rss")
+	bobFeed := createFeed(t, q, bob.ID, "https://bob.example.com/rss")
+	follow(t, q, bob.ID, aliceFeed.ID)
+	follow(t, q, bob.ID, bobFeed.ID)
+	follow(t, q, alice.ID, bobFeed.ID)
+	createPost(t, q, aliceFeed.ID, "https://alice.example.com/1", sql.NullTime{})
+	createPost(t, q, bobFeed.ID, "https://bob.example.com/1", sql.NullTime{})
+
+	if err := q.DeleteUser(ctx, alice.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	if _, err := q.GetUserByID(ctx, alice.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleted user: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := q.GetFeedByID(ctx, aliceFeed.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("feed of deleted user: got %v, want sql.ErrNoRows", err)
+	}
+	follows, _ := q.GetFeedFollowsForUser(ctx, bob.ID)
+	if len(follows) != 1 || follows[0].FeedID != bobFeed.ID {
+		t.Errorf("bob's follows: got %+v, want only %v", follows, bobFeed.ID)
+	}
+	posts, _ := q.GetPostsForUser(ctx, database.GetPostsForUserParams{UserID: bob.ID, Limit: 10})
+	if len(posts) != 1 || posts[0].FeedID != bobFeed.ID {
+		t.Errorf("bob's posts: got %+v, want only posts from %v", posts, bobFeed.ID)
+	}
+}
+
+func createAPIKey(t *testing.T, q database.Querier, userID uuid.UUID, name string, createdAt time.Time) database.ApiKey {
+	t.Helper()
+	key, err := q.CreateAPIKey(context.Background(), database.CreateAPIKeyParams{
+		ID:        uuid.New(),
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+		UserID:    userID,
+		Name:      name,
+		Prefix:    "gk_" + name,
+		HashedKey: "hash-" + userID.String() + "-" + name,
+		Scopes:    "posts:read",
+	})
+	if err != nil {
+		t.Fatalf("CreateAPIKey(%q): %v", name, err)
+	}
+	return key
+}
+
+func testAPIKeys(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	bob := createUser(t, q, "bob")
+
+	expiresAt := sql.NullTime{Time: now().Add(time.Hour), Valid: true}
+	cron, err := q.CreateAPIKey(ctx, database.CreateAPIKeyParams{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UpdatedAt: now(),
+		UserID:    alice.ID,
+		Name:      "cron",
+		Prefix:    "gk_abcd",
+		HashedKey: "hash-cron",
+		Scopes:    "posts:read follows:manage",
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	if !cron.ExpiresAt.Valid || cron.ExpiresAt.Time.Sub(expiresAt.Time).Abs() > time.Millisecond || cron.LastUsedAt.Valid {
+		t.Errorf("created key: got %+v", cron)
+	}
+	older := createAPIKey(t, q, alice.ID, "older", now().Add(-time.Hour))
+	createAPIKey(t, q, bob.ID, "cron", now())
+
+	got, err := q.GetAPIKeyByHash(ctx, "hash-cron")
+	if err != nil || got.ID != cron.ID || got.Scopes != "posts:read follows:manage" || got.UserID != alice.ID {
+		t.Errorf("GetAPIKeyByHash: got %+v, %v", got, err)
+	}
+	if _, err := q.GetAPIKeyByHash(ctx, "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetAPIKeyByHash missing: got %v, want sql.ErrNoRows", err)
+	}
+
+	keys, err := q.GetAPIKeysForUser(ctx, alice.ID)
+	if err != nil || len(keys) != 2 || keys[0].ID != older.ID || keys[1].ID != cron.ID {
+		t.Errorf("GetAPIKeysForUser: got %+v, %v, want older then cron", keys, err)
+	}
+
+	_, err = q.CreateAPIKey(ctx, database.CreateAPIKeyParams{ID: uuid.New(), CreatedAt: now(), UpdatedAt: now(), UserID: alice.ID, Name: "cron", HashedKey: "hash-other"})
+	if !database.IsUniqueViolation(err) {
+		t.Errorf("CreateAPIKey duplicate name: got %v, want a unique violation", err)
+	}
+	_, err = q.CreateAPIKey(ctx, database.CreateAPIKeyParams{ID: uuid.New(), CreatedAt: now(), UpdatedAt: now(), UserID: alice.ID, Name: "other", HashedKey: "hash-cron"})
+	if !database.IsUniqueViolation(err) {
+		t.Errorf("CreateAPIKey duplicate hash: got %v, want a unique violation", err)
+	}
+
+	usedAt := now()
+	if err := q.MarkAPIKeyUsed(ctx, database.MarkAPIKeyUsedParams{ID: cron.ID, LastUsedAt: sql.NullTime{Time: usedAt, Valid: true}}); err != nil {
+		t.Fatalf("MarkAPIKeyUsed: %v", err)
+	}
+	got, _ = q.GetAPIKeyByHash(ctx, "hash-cron")
+	if !got.LastUsedAt.Valid || got.LastUsedAt.Time.Sub(usedAt).Abs() > time.Millisecond {
+		t.Errorf("last used: got %v, want %v", got.LastUsedAt, usedAt)
+	}
+
+	// Keys can only be deleted by their owner
+	if n, err := q.DeleteAPIKey(ctx, database.DeleteAPIKeyParams{ID: cron.ID, UserID: bob.ID}); err != nil || n != 0 {
+		t.Errorf("DeleteAPIKey by another user: got %d, %v, want 0 rows", n, err)
+	}
+	if n, err := q.DeleteAPIKey(ctx, database.DeleteAPIKeyParams{ID: cron.ID, UserID: alice.ID}); err != nil || n != 1 {
+		t.Errorf("DeleteAPIKey: got %d, %v, want 1 row", n, err)
+	}
+	if _, err := q.GetAPIKeyByHash(ctx, "hash-cron"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleted key: got %v, want sql.ErrNoRows", err)
+	}
+
+	if err := q.DeleteUser(ctx, alice.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if keys, _ := q.GetAPIKeysForUser(ctx, alice.ID); len(keys) != 0 {
+		t.Errorf("keys of deleted user: got %+v, want none", keys)
+	}
+	if keys, _ := q.GetAPIKeysForUser(ctx, bob.ID); len(keys) != 1 {
+		t.Errorf("bob's keys: got %+v, want 1", keys)
+	}
+}
+
+func testUserIdentities(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	bob := createUser(t, q, "bob")
+
+	identity, err := q.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UserID:    alice.ID,
+		Issuer:    "https://sso.example.com",
+		Subject:   "1234",
+	})
+	if err != nil {
+		t.Fatalf("CreateUserIdentity: %v", err)
+	}
+
+	got, err := q.GetUserIdentity(ctx, database.GetUserIdentityParams{Issuer: "https://sso.example.com", Subject: "1234"})
+	if err != nil || got.ID != identity.ID || got.UserID != alice.ID {
+		t.Errorf("GetUserIdentity: got %+v, %v", got, err)
+	}
+	if _, err := q.GetUserIdentity(ctx, database.GetUserIdentityParams{Issuer: "https://other.example.com", Subject: "1234"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserIdentity other issuer: got %v, want sql.ErrNoRows", err)
+	}
+
+	// A subject links to one user per issuer
+	_, err = q.CreateUserIdentity(ctx, database.CreateUserIdentityParams{ID: uuid.New(), CreatedAt: now(), UserID: bob.ID, Issuer: "https://sso.example.com", Subject: "1234"})
+	if !database.IsUniqueViolation(err) {
+		t.Errorf("CreateUserIdentity duplicate subject: got %v, want a unique violation", err)
+	}
+	_, err = q.CreateUserIdentity(ctx, database.CreateUserIdentityParams{ID: uuid.New(), CreatedAt: now(), UserID: bob.ID, Issuer: "https://other.example.com", Subject: "1234"})
+	if err != nil {
+		t.Errorf("CreateUserIdentity same subject at another issuer: %v", err)
+	}
+	_, err = q.CreateUserIdentity(ctx, database.CreateUserIdentityParams{ID: uuid.New(), CreatedAt: now(), UserID: uuid.New(), Issuer: "https://sso.example.com", Subject: "5678"})
+	if err == nil {
+		t.Error("CreateUserIdentity for a missing user: got nil, want a foreign key violation")
+	}
+
+	if err := q.DeleteUser(ctx, alice.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := q.GetUserIdentity(ctx, database.GetUserIdentityParams{Issuer: "https://sso.example.com", Subject: "1234"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("identity of deleted user: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testDeleteUsers(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	createFeed(t, q, alice.ID, "https://alice.example.com/rss")
+
+	if err := q.DeleteUsers(ctx); err != nil {
+		t.Fatalf("DeleteUsers: %v", err)
+	}
+	users, _ := q.GetUsers(ctx)
+	feeds, _ := q.GetFeeds(ctx)
+	if len(users) != 0 || len(feeds) != 0 {
+		t.Errorf("after DeleteUsers: got %d users and %d feeds, want none", len(users), len(feeds))
+	}
+}
+
+func testPostsForUser(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	followed := createFeed(t, q, alice.ID, "https://followed.example.com/rss")
+	other := createFeed(t, q, alice.ID, "https://other.example.com/rss")
+	follow(t, q, alice.ID, followed.ID)
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	// A non-UTC time must sort by instant, not by its local representation
+	east := time.FixedZone("east", 10*60*60)
+	createPost(t, q, followed.ID, "https://followed.example.com/old", sql.NullTime{Time: base, Valid: true})
+	createPost(t, q, followed.ID, "https://followed.example.com/undated", sql.NullTime{})
+	createPost(t, q, followed.ID, "https://followed.example.com/new", sql.NullTime{Time: base.Add(2 * time.Hour).In(east), Valid: true})
+	createPost(t, q, followed.ID, "https://followed.example.com/mid", sql.NullTime{Time: base.Add(time.Hour), Valid: true})
+	createPost(t, q, other.ID, "https://other.example.com/1", sql.NullTime{Time: base.Add(3 * time.Hour), Valid: true})
+
+	posts, err := q.GetPostsForUser(ctx, database.GetPostsForUserParams{UserID: alice.ID, Limit: 10})
+	if err != nil {
+		t.Fatalf("GetPostsForUser: %v", err)
+	}
+	var urls []string
+	for _, post := range posts {
+		urls = append(urls, strings.TrimPrefix(post.Url, "https://followed.example.com/"))
+	}
+	// Postgres sorts NULLs first in descending order
+	want := []string{"undated", "new", "mid", "old"}
+	if !slices.Equal(urls, want) {
+		t.Errorf("GetPostsForUser order: got %q, want %q", urls, want)
+	}
+	if !posts[0].Description.Valid || posts[0].PublishedAt.Valid {
+		t.Errorf("undated post: got description %v and published_at %v", posts[0].Description, posts[0].PublishedAt)
+	}
+
+	posts, _ = q.GetPostsForUser(ctx, database.GetPostsForUserParams{UserID: alice.ID, Limit: 2})
+	if len(posts) != 2 {
+		t.Errorf("GetPostsForUser with limit 2: got %d posts", len(posts))
+	}
+
+	posts, _ = q.GetPostsForUser(ctx, database.GetPostsForUserParams{UserID: alice.ID, Limit: 2, Offset: 1})
+	urls = nil
+	for _, post := range posts {
+		urls = append(urls, strings.TrimPrefix(post.Url, "https://followed.example.com/"))
+	}
+	if want := []string{"new", "mid"}; !slices.Equal(urls, want) {
+		t.Errorf("GetPostsForUser with offset 1: got %q, want %q", urls, want)
+	}
+	posts, _ = q.GetPostsForUser(ctx, database.GetPostsForUserParams{UserID: alice.ID, Limit: 2, Offset: 10})
+	if len(posts) != 0 {
+		t.Errorf("GetPostsForUser past the end: got %d posts", len(posts))
+	}
+
+	// Posts published at the same time are ordered by id, so pages don't overlap
+	for i := range 4 {
+		createPost(t, q, other.ID, fmt.Sprintf("https://other.example.com/tie-%d", i), sql.NullTime{Time: base, Valid: true})
+	}
+	follow(t, q, alice.ID, other.ID)
+	all, _ := q.GetPostsForUser(ctx, database.GetPostsForUserParams{UserID: alice.ID, Limit: 100})
+	var paged []database.Post
+	for offset := int32(0); ; offset += 3 {
+		page, err := q.GetPostsForUser(ctx, database.GetPostsForUserParams{UserID: alice.ID, Limit: 3, Offset: offset})
+		if err != nil {
+			t.Fatalf("GetPostsForUser page: %v", err)
+		}
+		paged = append(paged, page...)
+		if len(page) < 3 {
+			break
+		}
+	}
+	if len(paged) != len(all) || len(all) != 9 {
+		t.Fatalf("paged posts: got %d, want %d of 9", len(paged), len(all))
+	}
+	for i := range all {
+		if paged[i].ID != all[i].ID {
+			t.Errorf("paged post %d: got %v, want %v", i, paged[i].Url, all[i].Url)
+		}
+	}
+}
+
+func testNextFeedsToFetch(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	if _, err := q.GetNextFeedToFetch(ctx); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetNextFeedToFetch with no feeds: got %v, want sql.ErrNoRows", err)
+	}
+
+	alice := createUser(t, q, "alice")
+	recent := createFeed(t, q, alice.ID, "https://recent.example.com/rss")
+	stale := createFeed(t, q, alice.ID, "https://stale.example.com/rss")
+	never := createFeed(t, q, alice.ID, "https://never.example.com/rss")
+
+	current := now()
+	for _, mark := range []struct {
+		id uuid.UUID
+		at time.Time
+	}{
+		{recent.ID, current.Add(-time.Minute)},
+		{stale.ID, current.Add(-time.Hour)},
+	} {
+		err := q.MarkFeedFetched(ctx, database.MarkFeedFetchedParams{
+			LastFetchedAt: sql.NullTime{Time: mark.at, Valid: true},
+			UpdatedAt:     current,
+			ID:            mark.id,
+		})
+		if err != nil {
+			t.Fatalf("MarkFeedFetched: %v", err)
+		}
+	}
+
+	next, err := q.GetNextFeedToFetch(ctx)
+	if err != nil || next.ID != never.ID {
+		t.Errorf("GetNextFeedToFetch: got %v, %v; want the never fetched feed", next.Url, err)
+	}
+
+	feeds, err := q.GetNextFeedsToFetch(ctx, database.GetNextFeedsToFetchParams{
+		LastFetchedAt: sql.NullTime{Time: current.Add(-10 * time.Minute), Valid: true},
+		Limit:         10,
+	})
+	if err != nil {
+		t.Fatalf("GetNextFeedsToFetch: %v", err)
+	}
+	var ids []uuid.UUID
+	for _, feed := range feeds {
+		ids = append(ids, feed.ID)
+	}
+	if !slices.Equal(ids, []uuid.UUID{never.ID, stale.ID}) {
+		t.Errorf("GetNextFeedsToFetch: got %v, want [%v %v]", ids, never.ID, stale.ID)
+	}
+
+	feeds, _ = q.GetNextFeedsToFetch(ctx, database.GetNextFeedsToFetchParams{
+		LastFetchedAt: sql.NullTime{Time: current, Valid: true},
+		Limit:         1,
+	})
+	if len(feeds) != 1 || feeds[0].ID != never.ID {
+		t.Errorf("GetNextFeedsToFetch with limit 1: got %v, want only the never fetched feed", feeds)
+	}
+}
+
+func itemSeqs(items []database.GetUserItemsRow) []int64 {
+	var seqs []int64
+	for _, item := range items {
+		seqs = append(seqs, item.Seq)
+	}
+	return seqs
+}
+
+func testItems(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	bob := createUser(t, q, "bob")
+	first := createFeed(t, q, alice.ID, "https://first.example.com/rss")
+	second := createFeed(t, q, alice.ID, "https://second.example.com/rss")
+	unfollowed := createFeed(t, q, alice.ID, "https://unfollowed.example.com/rss")
+	if first.Seq <= 0 || second.Seq <= first.Seq || unfollowed.Seq <= second.Seq {
+		t.Errorf("feed seqs: got %d, %d, %d, want increasing from 1", first.Seq, second.Seq, unfollowed.Seq)
+	}
+	follow(t, q, alice.ID, second.ID)
+	follow(t, q, alice.ID, first.ID)
+	follow(t, q, bob.ID, first.ID)
+
+	var posts []database.Post
+	for i, feed := range []database.Feed{first, second, first, unfollowed, second} {
+		posts = append(posts, createPost(t, q, feed.ID, fmt.Sprintf("%s/%d", feed.Url, i), sql.NullTime{}))
+		if i > 0 && posts[i].Seq <= posts[i-1].Seq {
+			t.Errorf("post seqs: got %d after %d, want increasing", posts[i].Seq, posts[i-1].Seq)
+		}
+	}
+	followed := []int64{posts[0].Seq, posts[1].Seq, posts[2].Seq, posts[4].Seq}
+
+	feeds, err := q.GetFollowedFeeds(ctx, alice.ID)
+	if err != nil || len(feeds) != 2 || feeds[0].ID != first.ID || feeds[1].ID != second.ID {
+		t.Errorf("GetFollowedFeeds: got %+v, %v, want first then second", feeds, err)
+	}
+	if count, err := q.CountUserItems(ctx, alice.ID); err != nil || count != 4 {
+		t.Errorf("CountUserItems: got %d, %v, want 4", count, err)
+	}
+
+	items, err := q.GetUserItems(ctx, database.GetUserItemsParams{UserID: alice.ID, Limit: 10})
+	if err != nil {
+		t.Fatalf("GetUserItems: %v", err)
+	}
+	if got := itemSeqs(items); !slices.Equal(got, followed) {
+		t.Errorf("GetUserItems: got %v, want %v", got, followed)
+	}
+	if items[1].FeedSeq != second.Seq || items[1].Url != posts[1].Url || items[1].ReadAt.Valid || items[1].SavedAt.Valid {
+		t.Errorf("GetUserItems item: got %+v", items[1])
+	}
+	items, _ = q.GetUserItems(ctx, database.GetUserItemsParams{UserID: alice.ID, AfterSeq: followed[0], Limit: 2})
+	if got, want := itemSeqs(items), followed[1:3]; !slices.Equal(got, want) {
+		t.Errorf("GetUserItems after %d: got %v, want %v", followed[0], got, want)
+	}
+	items, _ = q.GetUserItems(ctx, database.GetUserItemsParams{UserID: alice.ID, BeforeSeq: followed[3], Descending: true, Limit: 2})
+	if got, want := itemSeqs(items), []int64{followed[2], followed[1]}; !slices.Equal(got, want) {
+		t.Errorf("GetUserItems before %d: got %v, want %v", followed[3], got, want)
+	}
+
+	readAt := now()
+	n, err := q.SetItemRead(ctx, database.SetItemReadParams{UserID: alice.ID, Seq: followed[1], ReadAt: sql.NullTime{Time: readAt, Valid: true}})
+	if err != nil || n != 1 {
+		t.Errorf("SetItemRead: got %d, %v, want 1 row", n, err)
+	}
+	n, err = q.SetItemSaved(ctx, database.SetItemSavedParams{UserID: alice.ID, Seq: followed[1], SavedAt: sql.NullTime{Time: readAt, Valid: true}})
+	if err != nil || n != 1 {
+		t.Errorf("SetItemSaved: got %d, %v, want 1 row", n, err)
+	}
+	if n, _ := q.SetItemRead(ctx, database.SetItemReadParams{UserID: alice.ID, Seq: posts[3].Seq, ReadAt: sql.NullTime{Time: readAt, Valid: true}}); n != 0 {
+		t.Errorf("SetItemRead in an unfollowed feed: got %d rows, want 0", n)
+	}
+	item, err := q.GetUserItem(ctx, database.GetUserItemParams{UserID: alice.ID, Seq: followed[1]})
+	if err != nil || !item.ReadAt.Valid || !item.SavedAt.Valid || item.ID != posts[1].ID {
+		t.Errorf("GetUserItem after marking: got %+v, %v", item, err)
+	}
+	if _, err := q.GetUserItem(ctx, database.GetUserItemParams{UserID: alice.ID, Seq: posts[3].Seq}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserItem in an unfollowed feed: got %v, want sql.ErrNoRows", err)
+	}
+
+	// State is per user
+	unread, _ := q.GetUnreadItemSeqs(ctx, bob.ID)
+	if want := []int64{posts[0].Seq, posts[2].Seq}; !slices.Equal(unread, want) {
+		t.Errorf("bob's unread items: got %v, want %v", unread, want)
+	}
+	unread, _ = q.GetUnreadItemSeqs(ctx, alice.ID)
+	if want := []int64{followed[0], followed[2], followed[3]}; !slices.Equal(unread, want) {
+		t.Errorf("GetUnreadItemSeqs: got %v, want %v", unread, want)
+	}
+	saved, _ := q.GetSavedItemSeqs(ctx, alice.ID)
+	if want := []int64{followed[1]}; !slices.Equal(saved, want) {
+		t.Errorf("GetSavedItemSeqs: got %v, want %v", saved, want)
+	}
+
+	filters := []struct {
+		name   string
+		params database.GetUserItemsParams
+		want   []int64
+	}{
+		{"feed", database.GetUserItemsParams{FeedSeq: first.Seq}, []int64{posts[0].Seq, posts[2].Seq}},
+		{"unread", database.GetUserItemsParams{Unread: true}, []int64{followed[0], followed[2], followed[3]}},
+		{"saved", database.GetUserItemsParams{Saved: true}, []int64{followed[1]}},
+		{"unread in feed", database.GetUserItemsParams{FeedSeq: second.Seq, Unread: true}, []int64{followed[3]}},
+		{"created after", database.GetUserItemsParams{CreatedAfter: sql.NullTime{Time: now().Add(-time.Hour), Valid: true}}, followed},
+		{"created before", database.GetUserItemsParams{CreatedBefore: sql.NullTime{Time: now().Add(-time.Hour), Valid: true}}, nil},
+	}
+	for _, f := range filters {
+		f.params.UserID, f.params.Limit = alice.ID, 10
+		items, err := q.GetUserItems(ctx, f.params)
+		if got := itemSeqs(items); err != nil || !slices.Equal(got, f.want) {
+			t.Errorf("GetUserItems by %v: got %v, %v, want %v", f.name, got, err, f.want)
+		}
+	}
+
+	q.SetItemRead(ctx, database.SetItemReadParams{UserID: alice.ID, Seq: followed[1]})
+	q.SetItemSaved(ctx, database.SetItemSavedParams{UserID: alice.ID, Seq: followed[1]})
+	saved, _ = q.GetSavedItemSeqs(ctx, alice.ID)
+	unread, _ = q.GetUnreadItemSeqs(ctx, alice.ID)
+	if len(saved) != 0 || len(unread) != 4 {
+		t.Errorf("after unmarking: got saved %v and unread %v", saved, unread)
+	}
+
+	// Marking a feed read only touches items created before the cutoff
+	err = q.MarkItemsRead(ctx, database.MarkItemsReadParams{UserID: alice.ID, FeedSeq: first.Seq, CreatedBefore: now().Add(-time.Hour), ReadAt: now()})
+	if err != nil {
+		t.Fatalf("MarkItemsRead: %v", err)
+	}
+	if unread, _ = q.GetUnreadItemSeqs(ctx, alice.ID); len(unread) != 4 {
+		t.Errorf("unread after marking nothing read: got %v", unread)
+	}
+	q.MarkItemsRead(ctx, database.MarkItemsReadParams{UserID: alice.ID, FeedSeq: first.Seq, CreatedBefore: now().Add(time.Hour), ReadAt: now()})
+	if unread, _ = q.GetUnreadItemSeqs(ctx, alice.ID); !slices.Equal(unread, []int64{followed[1], followed[3]}) {
+		t.Errorf("unread after marking the first feed read: got %v, want %v", unread, []int64{followed[1], followed[3]})
+	}
+	q.SetItemRead(ctx, database.SetItemReadParams{UserID: alice.ID, Seq: followed[1], ReadAt: sql.NullTime{Time: readAt.Add(-time.Hour), Valid: true}})
+	q.MarkItemsRead(ctx, database.MarkItemsReadParams{UserID: alice.ID, CreatedBefore: now().Add(time.Hour), ReadAt: now()})
+	if unread, _ = q.GetUnreadItemSeqs(ctx, alice.ID); len(unread) != 0 {
+		t.Errorf("unread after marking everything read: got %v", unread)
+	}
+	// Items that were read already keep their read time
+	item, _ = q.GetUserItem(ctx, database.GetUserItemParams{UserID: alice.ID, Seq: followed[1]})
+	if !item.ReadAt.Time.Before(readAt) {
+		t.Errorf("read time of an item read earlier: got %v, want before %v", item.ReadAt.Time, readAt)
+	}
+
+	if err := q.DeleteUser(ctx, bob.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if unread, _ = q.GetUnreadItemSeqs(ctx, bob.ID); len(unread) != 0 {
+		t.Errorf("unread items of deleted user: got %v", unread)
+	}
+}
+
+// testSeqsNotReused deletes the newest feed and post, through the user that owns
+// them, and checks the next ones don't get their seqs back
+func testSeqsNotReused(t *testing.T, q database.Querier) {
+	alice := createUser(t, q, "alice")
+	feed := createFeed(t, q, alice.ID, "https://example.com/rss")
+	post := createPost(t, q, feed.ID, "https://example.com/1", sql.NullTime{})
+	if err := q.DeleteUser(context.Background(), alice.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	bob := createUser(t, q, "bob")
+	next := createFeed(t, q, bob.ID, "https://example.com/rss")
+	if next.Seq <= feed.Seq {
+		t.Errorf("feed seq after delete: got %d, want more than %d", next.Seq, feed.Seq)
+	}
+	if got := createPost(t, q, next.ID, "https://example.com/1", sql.NullTime{}); got.Seq <= post.Seq {
+		t.Errorf("post seq after delete: got %d, want more than %d", got.Seq, post.Seq)
+	}
+}
+
+func testFeverAPIKeys(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	bob := createUser(t, q, "bob")
+
+	if err := q.SetFeverAPIKey(ctx, database.SetFeverAPIKeyParams{UserID: alice.ID, CreatedAt: now(), ApiKey: "first"}); err != nil {
+		t.Fatalf("SetFeverAPIKey: %v", err)
+	}
+	if got, err := q.GetUserIDByFeverAPIKey(ctx, "first"); err != nil || got != alice.ID {
+		t.Errorf("GetUserIDByFeverAPIKey: got %v, %v, want %v", got, err, alice.ID)
+	}
+
+	// Setting the key again replaces it
+	if err := q.SetFeverAPIKey(ctx, database.SetFeverAPIKeyParams{UserID: alice.ID, CreatedAt: now(), ApiKey: "second"}); err != nil {
+		t.Fatalf("SetFeverAPIKey again: %v", err)
+	}
+	if _, err := q.GetUserIDByFeverAPIKey(ctx, "first"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("replaced key: got %v, want sql.ErrNoRows", err)
+	}
+	if got, _ := q.GetUserIDByFeverAPIKey(ctx, "second"); got != alice.ID {
+		t.Errorf("new key: got %v, want %v", got, alice.ID)
+	}
+
+	err := q.SetFeverAPIKey(ctx, database.SetFeverAPIKeyParams{UserID: bob.ID, CreatedAt: now(), ApiKey: "second"})
+	if !database.IsUniqueViolation(err) {
+		t.Errorf("SetFeverAPIKey with another user's key: got %v, want a unique violation", err)
+	}
+	if err := q.SetFeverAPIKey(ctx, database.SetFeverAPIKeyParams{UserID: uuid.New(), CreatedAt: now(), ApiKey: "third"}); err == nil {
+		t.Error("SetFeverAPIKey for a missing user: got nil, want a foreign key violation")
+	}
+
+	if n, err := q.DeleteFeverAPIKey(ctx, bob.ID); err != nil || n != 0 {
+		t.Errorf("DeleteFeverAPIKey without a key: got %d, %v, want 0", n, err)
+	}
+	if err := q.SetFeverAPIKey(ctx, database.SetFeverAPIKeyParams{UserID: bob.ID, CreatedAt: now(), ApiKey: "bob"}); err != nil {
+		t.Fatalf("SetFeverAPIKey: %v", err)
+	}
+	if n, err := q.DeleteFeverAPIKey(ctx, bob.ID); err != nil || n != 1 {
+		t.Errorf("DeleteFeverAPIKey: got %d, %v, want 1", n, err)
+	}
+	if _, err := q.GetUserIDByFeverAPIKey(ctx, "bob"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleted key: got %v, want sql.ErrNoRows", err)
+	}
+
+	if err := q.DeleteUser(ctx, alice.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := q.GetUserIDByFeverAPIKey(ctx, "second"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("key of deleted user: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testGetByIDs(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	bob := createUser(t, q, "bob")
+	createUser(t, q, "carol")
+	first := createFeed(t, q, alice.ID, "https://first.example.com/rss")
+	createFeed(t, q, alice.ID, "https://second.example.com/rss")
+	third := createFeed(t, q, bob.ID, "https://third.example.com/rss")
+
+	users, err := q.GetUsersByIDs(ctx, []uuid.UUID{bob.ID, alice.ID, uuid.New()})
+	if err != nil {
+		t.Fatalf("GetUsersByIDs: %v", err)
+	}
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+	slices.Sort(names)
+	if want := []string{"alice", "bob"}; !slices.Equal(names, want) {
+		t.Errorf("GetUsersByIDs: got %v, want %v", names, want)
+	}
+
+	feeds, err := q.GetFeedsByIDs(ctx, []uuid.UUID{third.ID, first.ID})
+	if err != nil {
+		t.Fatalf("GetFeedsByIDs: %v", err)
+	}
+	var urls []string
+	for _, feed := range feeds {
+		urls = append(urls, feed.Url)
+	}
+	slices.Sort(urls)
+	if want := []string{first.Url, third.Url}; !slices.Equal(urls, want) {
+		t.Errorf("GetFeedsByIDs: got %v, want %v", urls, want)
+	}
+
+	if users, err := q.GetUsersByIDs(ctx, nil); err != nil || len(users) != 0 {
+		t.Errorf("GetUsersByIDs with no ids: got %v, %v, want none", users, err)
+	}
+	if feeds, err := q.GetFeedsByIDs(ctx, []uuid.UUID{}); err != nil || len(feeds) != 0 {
+		t.Errorf("GetFeedsByIDs with no ids: got %v, %v, want none", feeds, err)
+	}
+}
+
+func followAt(t *testing.T, q database.Querier, userID, feedID uuid.UUID, at time.Time) {
+	t.Helper()
+	_, err := q.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: at,
+		UpdatedAt: at,
+		UserID:    userID,
+		FeedID:    feedID,
+	})
+	if err != nil {
+		t.Fatalf("CreateFeedFollow: %v", err)
+	}
+}
+
+func testListFeeds(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	bob := createUser(t, q, "bob")
+	carol := createUser(t, q, "carol")
+	first := createFeed(t, q, alice.ID, "https://first.example.com/rss")
+	second := createFeed(t, q, bob.ID, "https://second.example.com/atom")
+	third := createFeed(t, q, bob.ID, "https://third.example.com/rss")
+
+	// second has the most followers, but third gained the most this week
+	monthAgo := now().Add(-30 * 24 * time.Hour)
+	weekAgo := now().Add(-7 * 24 * time.Hour)
+	for _, user := range []uuid.UUID{alice.ID, bob.ID, carol.ID} {
+		followAt(t, q, user, second.ID, monthAgo)
+	}
+	followAt(t, q, alice.ID, third.ID, now())
+	followAt(t, q, carol.ID, third.ID, now())
+	createPost(t, q, third.ID, "https://third.example.com/1", sql.NullTime{})
+	createPost(t, q, third.ID, "https://third.example.com/2", sql.NullTime{})
+	_, err := q.CreatePost(ctx, database.CreatePostParams{
+		ID:        uuid.New(),
+		CreatedAt: monthAgo,
+		UpdatedAt: monthAgo,
+		Title:     "old",
+		Url:       "https://first.example.com/old",
+		FeedID:    first.ID,
+	})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+
+	err = q.MarkFeedFetched(ctx, database.MarkFeedFetchedParams{
+		LastFetchedAt: sql.NullTime{Time: now(), Valid: true},
+		UpdatedAt:     now().Add(time.Hour),
+		ID:            first.ID,
+	})
+	if err != nil {
+		t.Fatalf("MarkFeedFetched: %v", err)
+	}
+	for _, tag := range []database.CreateFeedTagParams{{FeedID: first.ID, Tag: "go"}, {FeedID: first.ID, Tag: "news"}, {FeedID: third.ID, Tag: "go"}} {
+		if err := q.CreateFeedTag(ctx, tag); err != nil {
+			t.Fatalf("CreateFeedTag: %v", err)
+		}
+	}
+
+	list := func(arg database.ListFeedsParams) []database.ListFeedsRow {
+		t.Helper()
+		if arg.Limit == 0 {
+			arg.Limit = 10
+		}
+		arg.Since = weekAgo
+		rows, err := q.ListFeeds(ctx, arg)
+		if err != nil {
+			t.Fatalf("ListFeeds(%+v): %v", arg, err)
+		}
+		return rows
+	}
+	urls := func(rows []database.ListFeedsRow) []string {
+		var urls []string
+		for _, row := range rows {
+			urls = append(urls, row.Url)
+		}
+		return urls
+	}
+
+	rows := list(database.ListFeedsParams{})
+	if want := []string{third.Url, second.Url, first.Url}; !slices.Equal(urls(rows), want) {
+		t.Fatalf("ListFeeds newest: got %v, want %v", urls(rows), want)
+	}
+	if row := rows[0]; row.FollowerCount != 2 || row.RecentFollowerCount != 2 || row.RecentPostCount != 2 {
+		t.Errorf("ListFeeds row: got %+v, want 2 followers and 2 posts this week", row)
+	}
+	if row := rows[1]; row.UserName != "bob" || row.FollowerCount != 3 || row.RecentFollowerCount != 0 || row.ID != second.ID {
+		t.Errorf("ListFeeds row: got %+v, want bob's feed with 3 older followers", row)
+	}
+	if row := rows[2]; row.UserName != "alice" || row.FollowerCount != 0 || row.RecentPostCount != 0 || !row.LastFetchedAt.Valid {
+		t.Errorf("ListFeeds row: got %+v, want alice's fetched feed with no followers or recent posts", row)
+	}
+
+	rows = list(database.ListFeedsParams{Sort: "followers"})
+	if want := []string{second.Url, third.Url, first.Url}; !slices.Equal(urls(rows), want) {
+		t.Errorf("ListFeeds by followers: got %v, want %v", urls(rows), want)
+	}
+	rows = list(database.ListFeedsParams{Sort: "popular"})
+	if want := []string{third.Url, second.Url, first.Url}; !slices.Equal(urls(rows), want) {
+		t.Errorf("ListFeeds by popularity: got %v, want %v", urls(rows), want)
+	}
+	// Being fetched doesn't make first more recently updated than third, whose
+	// posts are newer, and second has no posts at all
+	rows = list(database.ListFeedsParams{Sort: "updated"})
+	if want := []string{third.Url, first.Url, second.Url}; !slices.Equal(urls(rows), want) {
+		t.Errorf("ListFeeds by updated: got %v, want %v", urls(rows), want)
+	}
+
+	rows = list(database.ListFeedsParams{Limit: 1, Offset: 1})
+	if want := []string{second.Url}; !slices.Equal(urls(rows), want) {
+		t.Errorf("ListFeeds page: got %v, want %v", urls(rows), want)
+	}
+	if rows := list(database.ListFeedsParams{Offset: 3}); len(rows) != 0 {
+		t.Errorf("ListFeeds past the end: got %v, want none", urls(rows))
+	}
+
+	rows = list(database.ListFeedsParams{Search: "RSS"})
+	if want := []string{third.Url, first.Url}; !slices.Equal(urls(rows), want) {
+		t.Errorf("ListFeeds search by url: got %v, want %v", urls(rows), want)
+	}
+	rows = list(database.ListFeedsParams{Search: "title of https://SECOND"})
+	if want := []string{second.Url}; !slices.Equal(urls(rows), want) {
+		t.Errorf("ListFeeds search by title: got %v, want %v", urls(rows), want)
+	}
+	if rows := list(database.ListFeedsParams{Search: "%"}); len(rows) != 0 {
+		t.Errorf("ListFeeds search for %%: got %v, want none", urls(rows))
+	}
+
+	rows = list(database.ListFeedsParams{Tag: "go"})
+	if want := []string{third.Url, first.Url}; !slices.Equal(urls(rows), want) {
+		t.Errorf("ListFeeds tagged go: got %v, want %v", urls(rows), want)
+	}
+	rows = list(database.ListFeedsParams{Tag: "news", Search: "third"})
+	if len(rows) != 0 {
+		t.Errorf("ListFeeds tagged news matching third: got %v, want none", urls(rows))
+	}
+}
+
+func testFeedTags(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	bob := createUser(t, q, "bob")
+	first := createFeed(t, q, alice.ID, "https://first.example.com/rss")
+	second := createFeed(t, q, bob.ID, "https://second.example.com/rss")
+	for _, tag := range []database.CreateFeedTagParams{
+		{FeedID: first.ID, Tag: "news"},
+		{FeedID: first.ID, Tag: "go"},
+		{FeedID: first.ID, Tag: "go"},
+		{FeedID: second.ID, Tag: "go"},
+	} {
+		if err := q.CreateFeedTag(ctx, tag); err != nil {
+			t.Fatalf("CreateFeedTag(%+v): %v", tag, err)
+		}
+	}
+	if err := q.CreateFeedTag(ctx, database.CreateFeedTagParams{FeedID: uuid.New(), Tag: "go"}); err == nil {
+		t.Errorf("CreateFeedTag for a missing feed: got nil, want an error")
+	}
+
+	tags, err := q.GetTagsForFeeds(ctx, []uuid.UUID{first.ID})
+	want := []database.FeedTag{{FeedID: first.ID, Tag: "go"}, {FeedID: first.ID, Tag: "news"}}
+	if err != nil || !slices.Equal(tags, want) {
+		t.Errorf("GetTagsForFeeds: got %v, %v; want %v", tags, err, want)
+	}
+	if tags, err := q.GetTagsForFeeds(ctx, nil); err != nil || len(tags) != 0 {
+		t.Errorf("GetTagsForFeeds with no ids: got %v, %v; want none", tags, err)
+	}
+
+	counts, err := q.ListTags(ctx)
+	if want := []database.ListTagsRow{{Tag: "go", FeedCount: 2}, {Tag: "news", FeedCount: 1}}; err != nil || !slices.Equal(counts, want) {
+		t.Errorf("ListTags: got %v, %v; want %v", counts, err, want)
+	}
+
+	if err := q.DeleteFeedTags(ctx, first.ID); err != nil {
+		t.Fatalf("DeleteFeedTags: %v", err)
+	}
+	if tags, _ := q.GetTagsForFeeds(ctx, []uuid.UUID{first.ID, second.ID}); len(tags) != 1 || tags[0].FeedID != second.ID {
+		t.Errorf("tags after DeleteFeedTags: got %v, want only second's", tags)
+	}
+
+	// SetFeedTags replaces all of a feed's tags at once
+	if err := q.SetFeedTags(ctx, database.SetFeedTagsParams{FeedID: second.ID, Tags: []string{"rust", "go"}}); err != nil {
+		t.Fatalf("SetFeedTags: %v", err)
+	}
+	tags, _ = q.GetTagsForFeeds(ctx, []uuid.UUID{second.ID})
+	if want := []database.FeedTag{{FeedID: second.ID, Tag: "go"}, {FeedID: second.ID, Tag: "rust"}}; !slices.Equal(tags, want) {
+		t.Errorf("tags after SetFeedTags: got %v, want %v", tags, want)
+	}
+	if err := q.SetFeedTags(ctx, database.SetFeedTagsParams{FeedID: second.ID, Tags: []string{"news"}}); err != nil {
+		t.Fatalf("SetFeedTags: %v", err)
+	}
+	tags, _ = q.GetTagsForFeeds(ctx, []uuid.UUID{second.ID})
+	if want := []database.FeedTag{{FeedID: second.ID, Tag: "news"}}; !slices.Equal(tags, want) {
+		t.Errorf("tags after replacing them: got %v, want %v", tags, want)
+	}
+	if err := q.SetFeedTags(ctx, database.SetFeedTagsParams{FeedID: uuid.New(), Tags: []string{"go"}}); err == nil {
+		t.Errorf("SetFeedTags for a missing feed: got nil, want an error")
+	}
+
+	// Deleting a feed's owner deletes its tags
+	if err := q.DeleteUser(ctx, bob.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if counts, _ := q.ListTags(ctx); len(counts) != 0 {
+		t.Errorf("ListTags after deleting the feed owner: got %v, want none", counts)
+	}
+}
+
+// recommended summarises recommendation rows as "feed url because followed feed url"
+func recommended(rows []database.ListFeedRecommendationsRow, feeds map[uuid.UUID]string) []string {
+	var got []string
+	for _, row := range rows {
+		got = append(got, row.Url+" because "+feeds[row.BecauseFeedID])
+	}
+	return got
+}
+
+func testFeedRecommendations(t *testing.T, q database.Querier) {
+	ctx := context.Background()
+	alice := createUser(t, q, "alice")
+	bob := createUser(t, q, "bob")
+	carol := createUser(t, q, "carol")
+	dave := createUser(t, q, "dave")
+	golang := createFeed(t, q, alice.ID, "go")
+	rust := createFeed(t, q, alice.ID, "rust")
+	news := createFeed(t, q, bob.ID, "news")
+	cooking := createFeed(t, q, carol.ID, "cooking")
+	urls := map[uuid.UUID]string{golang.ID: "go", rust.ID: "rust", news.ID: "news", cooking.ID: "cooking"}
+	for _, f := range []struct {
+		user  database.User
+		feeds []database.Feed
+	}{
+		{alice, []database.Feed{golang, rust}},
+		{bob, []database.Feed{golang, rust, news}},
+		{carol, []database.Feed{golang, news}},
+		{dave, []database.Feed{golang, cooking}},
+	} {
+		for _, feed := range f.feeds {
+			follow(t, q, f.user.ID, feed.ID)
+		}
+	}
+
+	if rows, err := q.ListFeedRecommendations(ctx, alice.ID); err != nil || len(rows) != 0 {
+		t.Errorf("ListFeedRecommendations before any are computed: got %v, %v; want none", rows, err)
+	}
+
+	// Each feed keeps its two most similar feeds, so go drops cooking
+	first := now().Truncate(time.Second)
+	created, err := q.CreateFeedSimilarities(ctx, database.CreateFeedSimilaritiesParams{ComputedAt: first, PerFeed: 2})
+	if err != nil || created != 7 {
+		t.Fatalf("CreateFeedSimilarities: got %d, %v; want 7", created, err)
+	}
+	rows, err := q.ListFeedRecommendations(ctx, alice.ID)
+	if got, want := recommended(rows, urls), []string{"news because go", "news because rust"}; err != nil || !slices.Equal(got, want) {
+		t.Fatalf("ListFeedRecommendations: got %q, %v; want %q", got, err, want)
+	}
+	// go and news share two of their four and two followers, rust and news one of
+	// two and two
+	if rows[0].Title != news.Title || rows[0].CommonFollowers != 2 || rows[0].Score != 0.5 || rows[0].BecauseFeedTitle != golang.Title {
+		t.Errorf("first recommendation: got %+v", rows[0])
+	}
+	if rows[1].CommonFollowers != 1 || math.Abs(rows[1].Score-1.0/3) > 1e-9 {
+		t.Errorf("second recommendation: got %+v", rows[1])
+	}
+
+	// Only the latest similarities are used
+	follow(t, q, alice.ID, news.ID)
+	if rows, _ := q.ListFeedRecommendations(ctx, alice.ID); len(rows) != 0 {
+		t.Errorf("recommendations after following news: got %q, want none", recommended(rows, urls))
+	}
+	second := first.Add(time.Minute)
+	if _, err := q.CreateFeedSimilarities(ctx, database.CreateFeedSimilaritiesParams{ComputedAt: second, PerFeed: 10}); err != nil {
+		t.Fatalf("CreateFeedSimilarities: %v", err)
+	}
+	rows, err = q.ListFeedRecommendations(ctx, alice.ID)
+	if got, want := recommended(rows, urls), []string{"cooking because go"}; err != nil || !slices.Equal(got, want) {
+		t.Errorf("ListFeedRecommendations after a refresh: got %q, %v; want %q", got, err, want)
+	}
+
+	if err := q.DeleteFeedSimilaritiesBefore(ctx, second); err != nil {
+		t.Fatalf("DeleteFeedSimilaritiesBefore: %v", err)
+	}
+	if rows, _ := q.ListFeedRecommendations(ctx, alice.ID); len(rows) != 1 {
+		t.Errorf("recommendations after deleting older similarities: got %q, want cooking", recommended(rows, urls))
+	}
+
+	// Deleting a feed's owner deletes its similarities
+	if err := q.DeleteUser(ctx, carol.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if rows, _ := q.ListFeedRecommendations(ctx, alice.ID); len(rows) != 0 {
+		t.Errorf("recommendations after deleting cooking's owner: got %q, want none", recommended(rows, urls))
+	}
+}
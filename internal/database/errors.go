@@ -0,0 +1,21 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// ErrUniqueViolation is wrapped by Querier implementations that don't return
+// *pq.Error when a write violates a unique constraint
+var ErrUniqueViolation = errors.New("duplicate key value violates unique constraint")
+
+// IsUniqueViolation reports whether err was caused by a unique constraint violation
+// in any of the supported databases
+func IsUniqueViolation(err error) bool {
+	if errors.Is(err, ErrUniqueViolation) {
+		return true
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
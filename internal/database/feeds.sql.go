@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createFeed = `-- name: CreateFeed :one
@@ -23,7 +24,7 @@ VALUES (
     $5,
     $6
 )
-RETURNING id, created_at, updated_at, title, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, title, url, user_id, last_fetched_at, seq, last_successful_fetch_at
 `
 
 type CreateFeedParams struct {
@@ -53,12 +54,14 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Seq,
+		&i.LastSuccessfulFetchAt,
 	)
 	return i, err
 }
 
 const getFeedByID = `-- name: GetFeedByID :one
-SELECT id, created_at, updated_at, title, url, user_id, last_fetched_at FROM feeds WHERE id = $1
+SELECT id, created_at, updated_at, title, url, user_id, last_fetched_at, seq, last_successful_fetch_at FROM feeds WHERE id = $1
 `
 
 func (q *Queries) GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error) {
@@ -72,12 +75,14 @@ func (q *Queries) GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Seq,
+		&i.LastSuccessfulFetchAt,
 	)
 	return i, err
 }
 
 const getFeedByURL = `-- name: GetFeedByURL :one
-SELECT id, created_at, updated_at, title, url, user_id, last_fetched_at FROM feeds WHERE url = $1
+SELECT id, created_at, updated_at, title, url, user_id, last_fetched_at, seq, last_successful_fetch_at FROM feeds WHERE url = $1
 `
 
 func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
@@ -91,12 +96,14 @@ func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Seq,
+		&i.LastSuccessfulFetchAt,
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, title, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, title, url, user_id, last_fetched_at, seq, last_successful_fetch_at FROM feeds
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -116,6 +123,46 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.Seq,
+			&i.LastSuccessfulFetchAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedsByIDs = `-- name: GetFeedsByIDs :many
+SELECT id, created_at, updated_at, title, url, user_id, last_fetched_at, seq, last_successful_fetch_at FROM feeds
+WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetFeedsByIDs(ctx context.Context, ids []uuid.UUID) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Seq,
+			&i.LastSuccessfulFetchAt,
 		); err != nil {
 			return nil, err
 		}
@@ -131,7 +178,7 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 }
 
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, title, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, title, url, user_id, last_fetched_at, seq, last_successful_fetch_at FROM feeds
 ORDER BY last_fetched_at NULLS FIRST
 LIMIT 1
 `
@@ -147,10 +194,175 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Seq,
+		&i.LastSuccessfulFetchAt,
 	)
 	return i, err
 }
 
+const getNextFeedsToFetch = `-- name: GetNextFeedsToFetch :many
+SELECT id, created_at, updated_at, title, url, user_id, last_fetched_at, seq, last_successful_fetch_at FROM feeds
+WHERE last_fetched_at IS NULL OR last_fetched_at < $1
+ORDER BY last_fetched_at NULLS FIRST
+LIMIT $2
+`
+
+type GetNextFeedsToFetchParams struct {
+	LastFetchedAt sql.NullTime
+	Limit         int32
+}
+
+func (q *Queries) GetNextFeedsToFetch(ctx context.Context, arg GetNextFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getNextFeedsToFetch, arg.LastFetchedAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Seq,
+			&i.LastSuccessfulFetchAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeeds = `-- name: ListFeeds :many
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.title, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.seq, feeds.last_successful_fetch_at, users.name AS user_name,
+    COALESCE(follows.follower_count, 0)::bigint AS follower_count,
+    COALESCE(follows.recent_follower_count, 0)::bigint AS recent_follower_count,
+    COALESCE(feed_posts.post_count, 0)::bigint AS recent_post_count
+FROM feeds
+INNER JOIN users ON users.id = feeds.user_id
+LEFT JOIN (
+    SELECT feed_id, COUNT(*) AS follower_count,
+        COUNT(CASE WHEN created_at > $1 THEN 1 END) AS recent_follower_count
+    FROM feed_follows
+    GROUP BY feed_id
+) AS follows ON follows.feed_id = feeds.id
+LEFT JOIN (
+    SELECT feed_id, COUNT(CASE WHEN created_at > $1 THEN 1 END) AS post_count,
+        MAX(COALESCE(published_at, created_at)) AS latest_post_at
+    FROM posts
+    GROUP BY feed_id
+) AS feed_posts ON feed_posts.feed_id = feeds.id
+WHERE ($2::text = ''
+        OR strpos(lower(feeds.title), lower($2)) > 0
+        OR strpos(lower(feeds.url), lower($2)) > 0)
+    AND ($3::text = ''
+        OR EXISTS (SELECT 1 FROM feed_tags WHERE feed_tags.feed_id = feeds.id AND feed_tags.tag = $3))
+ORDER BY
+    CASE WHEN $4::text = 'popular' THEN COALESCE(follows.recent_follower_count, 0) END DESC,
+    CASE WHEN $4::text IN ('followers', 'popular') THEN COALESCE(follows.follower_count, 0) END DESC,
+    CASE WHEN $4::text = 'updated' THEN feed_posts.latest_post_at END DESC NULLS LAST,
+    feeds.created_at DESC,
+    feeds.seq DESC
+LIMIT $5 OFFSET $6
+`
+
+type ListFeedsParams struct {
+	Since  time.Time
+	Search string
+	Tag    string
+	Sort   string
+	Limit  int32
+	Offset int32
+}
+
+type ListFeedsRow struct {
+	ID                    uuid.UUID
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	Title                 string
+	Url                   string
+	UserID                uuid.UUID
+	LastFetchedAt         sql.NullTime
+	Seq                   int64
+	LastSuccessfulFetchAt sql.NullTime
+	UserName              string
+	FollowerCount         int64
+	RecentFollowerCount   int64
+	RecentPostCount       int64
+}
+
+func (q *Queries) ListFeeds(ctx context.Context, arg ListFeedsParams) ([]ListFeedsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFeeds,
+		arg.Since,
+		arg.Search,
+		arg.Tag,
+		arg.Sort,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFeedsRow
+	for rows.Next() {
+		var i ListFeedsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Seq,
+			&i.LastSuccessfulFetchAt,
+			&i.UserName,
+			&i.FollowerCount,
+			&i.RecentFollowerCount,
+			&i.RecentPostCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markFeedFetchSucceeded = `-- name: MarkFeedFetchSucceeded :exec
+UPDATE feeds
+SET last_successful_fetch_at = $1
+WHERE feeds.id = $2
+`
+
+type MarkFeedFetchSucceededParams struct {
+	LastSuccessfulFetchAt sql.NullTime
+	ID                    uuid.UUID
+}
+
+func (q *Queries) MarkFeedFetchSucceeded(ctx context.Context, arg MarkFeedFetchSucceededParams) error {
+	_, err := q.db.ExecContext(ctx, markFeedFetchSucceeded, arg.LastSuccessfulFetchAt, arg.ID)
+	return err
+}
+
 const markFeedFetched = `-- name: MarkFeedFetched :exec
 UPDATE feeds
 SET last_fetched_at = $1, updated_at = $2
@@ -0,0 +1,1136 @@
+// Package memory is an in-process implementation of database.Querier for tests. It
+// mirrors the constraints declared in sql/schema: unique user names, feed URLs, post
+// URLs, follows, API key hashes, identities and Fever API keys, foreign keys, and
+// cascading deletes from users to their feeds, follows, feed tags, feed similarities,
+// posts, post states, API keys, identities and Fever API keys. Feeds and posts are
+// numbered from sequences like their seq columns.
+package memory
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/database"
+)
+
+const (
+	maxUserNameLength   = 50
+	maxAPIKeyNameLength = 50
+)
+
+type Store struct {
+	mu           sync.Mutex
+	users        []database.User
+	feeds        []database.Feed
+	feedFollows  []database.FeedFollow
+	feedTags     []database.FeedTag
+	similarities []database.FeedSimilarity
+	posts        []database.Post
+	apiKeys      []database.ApiKey
+	identities   []database.UserIdentity
+	postStates   []database.PostState
+	feverKeys    []database.FeverApiKey
+	feedSeq      int64
+	postSeq      int64
+}
+
+var _ database.Querier = (*Store)(nil)
+
+func New() *Store {
+	return &Store{}
+}
+
+func uniqueViolation(constraint string) error {
+	return fmt.Errorf("%w %q", database.ErrUniqueViolation, constraint)
+}
+
+func foreignKeyViolation(table, constraint string) error {
+	return fmt.Errorf("insert or update on table %q violates foreign key constraint %q", table, constraint)
+}
+
+// timestamp truncates t the way a Postgres TIMESTAMP column stores it
+func timestamp(t time.Time) time.Time {
+	return t.Truncate(time.Microsecond)
+}
+
+func nullTimestamp(t sql.NullTime) sql.NullTime {
+	if !t.Valid {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: timestamp(t.Time), Valid: true}
+}
+
+func (s *Store) userIndex(id uuid.UUID) int {
+	return slices.IndexFunc(s.users, func(u database.User) bool { return u.ID == id })
+}
+
+func (s *Store) feedIndex(id uuid.UUID) int {
+	return slices.IndexFunc(s.feeds, func(f database.Feed) bool { return f.ID == id })
+}
+
+func (s *Store) postIndex(id uuid.UUID) int {
+	return slices.IndexFunc(s.posts, func(p database.Post) bool { return p.ID == id })
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if utf8.RuneCountInString(arg.Name) > maxUserNameLength {
+		return database.User{}, fmt.Errorf("value too long for type character varying(%d)", maxUserNameLength)
+	}
+	if s.userIndex(arg.ID) >= 0 {
+		return database.User{}, uniqueViolation("users_pkey")
+	}
+	if slices.ContainsFunc(s.users, func(u database.User) bool { return u.Name == arg.Name }) {
+		return database.User{}, uniqueViolation("users_name_key")
+	}
+
+	user := database.User{
+		ID:             arg.ID,
+		CreatedAt:      timestamp(arg.CreatedAt),
+		UpdatedAt:      timestamp(arg.UpdatedAt),
+		Name:           arg.Name,
+		HashedPassword: arg.HashedPassword,
+	}
+	s.users = append(s.users, user)
+	return user, nil
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.userIndex(id)
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	return s.users[i], nil
+}
+
+func (s *Store) GetUserByName(ctx context.Context, name string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.users, func(u database.User) bool { return u.Name == name })
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	return s.users[i], nil
+}
+
+func (s *Store) GetUserNameByID(ctx context.Context, id uuid.UUID) (string, error) {
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return user.Name, nil
+}
+
+func (s *Store) GetUsers(ctx context.Context) ([]database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.users), nil
+}
+
+func (s *Store) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var users []database.User
+	for _, user := range s.users {
+		if slices.Contains(ids, user.ID) {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (s *Store) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.userIndex(arg.ID)
+	if i < 0 {
+		return nil
+	}
+	s.users[i].HashedPassword = arg.HashedPassword
+	s.users[i].UpdatedAt = timestamp(arg.UpdatedAt)
+	s.users[i].PasswordVersion++
+	return nil
+}
+
+func (s *Store) RehashUserPassword(ctx context.Context, arg database.RehashUserPasswordParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.userIndex(arg.ID)
+	if i < 0 {
+		return nil
+	}
+	s.users[i].HashedPassword = arg.HashedPassword
+	s.users[i].UpdatedAt = timestamp(arg.UpdatedAt)
+	return nil
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteUsers(func(u database.User) bool { return u.ID == id })
+	return nil
+}
+
+func (s *Store) DeleteUsers(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteUsers(func(database.User) bool { return true })
+	return nil
+}
+
+// deleteUsers removes matching users and cascades to the rows that reference them
+func (s *Store) deleteUsers(match func(database.User) bool) {
+	deletedUsers := map[uuid.UUID]bool{}
+	s.users = slices.DeleteFunc(s.users, func(u database.User) bool {
+		if match(u) {
+			deletedUsers[u.ID] = true
+			return true
+		}
+		return false
+	})
+
+	deletedFeeds := map[uuid.UUID]bool{}
+	s.feeds = slices.DeleteFunc(s.feeds, func(f database.Feed) bool {
+		if deletedUsers[f.UserID] {
+			deletedFeeds[f.ID] = true
+			return true
+		}
+		return false
+	})
+
+	s.feedFollows = slices.DeleteFunc(s.feedFollows, func(ff database.FeedFollow) bool {
+		return deletedUsers[ff.UserID] || deletedFeeds[ff.FeedID]
+	})
+	s.feedTags = slices.DeleteFunc(s.feedTags, func(ft database.FeedTag) bool {
+		return deletedFeeds[ft.FeedID]
+	})
+	s.similarities = slices.DeleteFunc(s.similarities, func(fs database.FeedSimilarity) bool {
+		return deletedFeeds[fs.FeedID] || deletedFeeds[fs.SimilarFeedID]
+	})
+	s.posts = slices.DeleteFunc(s.posts, func(p database.Post) bool {
+		return deletedFeeds[p.FeedID]
+	})
+	s.apiKeys = slices.DeleteFunc(s.apiKeys, func(k database.ApiKey) bool {
+		return deletedUsers[k.UserID]
+	})
+	s.identities = slices.DeleteFunc(s.identities, func(i database.UserIdentity) bool {
+		return deletedUsers[i.UserID]
+	})
+	s.postStates = slices.DeleteFunc(s.postStates, func(ps database.PostState) bool {
+		return deletedUsers[ps.UserID] || s.postIndex(ps.PostID) < 0
+	})
+	s.feverKeys = slices.DeleteFunc(s.feverKeys, func(k database.FeverApiKey) bool {
+		return deletedUsers[k.UserID]
+	})
+}
+
+func (s *Store) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.feedIndex(arg.ID) >= 0 {
+		return database.Feed{}, uniqueViolation("feeds_pkey")
+	}
+	if slices.ContainsFunc(s.feeds, func(f database.Feed) bool { return f.Url == arg.Url }) {
+		return database.Feed{}, uniqueViolation("unique_url")
+	}
+	if s.userIndex(arg.UserID) < 0 {
+		return database.Feed{}, foreignKeyViolation("feeds", "feeds_user_id_fkey")
+	}
+
+	s.feedSeq++
+	feed := database.Feed{
+		ID:        arg.ID,
+		CreatedAt: timestamp(arg.CreatedAt),
+		UpdatedAt: timestamp(arg.UpdatedAt),
+		Title:     arg.Title,
+		Url:       arg.Url,
+		UserID:    arg.UserID,
+		Seq:       s.feedSeq,
+	}
+	s.feeds = append(s.feeds, feed)
+	return feed, nil
+}
+
+func (s *Store) GetFeedByID(ctx context.Context, id uuid.UUID) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.feedIndex(id)
+	if i < 0 {
+		return database.Feed{}, sql.ErrNoRows
+	}
+	return s.feeds[i], nil
+}
+
+func (s *Store) GetFeedByURL(ctx context.Context, url string) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.feeds, func(f database.Feed) bool { return f.Url == url })
+	if i < 0 {
+		return database.Feed{}, sql.ErrNoRows
+	}
+	return s.feeds[i], nil
+}
+
+func (s *Store) GetFeeds(ctx context.Context) ([]database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.feeds), nil
+}
+
+func (s *Store) GetFeedsByIDs(ctx context.Context, ids []uuid.UUID) ([]database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var feeds []database.Feed
+	for _, feed := range s.feeds {
+		if slices.Contains(ids, feed.ID) {
+			feeds = append(feeds, feed)
+		}
+	}
+	return feeds, nil
+}
+
+func (s *Store) ListFeeds(ctx context.Context, arg database.ListFeedsParams) ([]database.ListFeedsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	search := strings.ToLower(arg.Search)
+	var rows []database.ListFeedsRow
+	// latestPost is when each feed's newest post was published, or created when
+	// it has no publish time
+	latestPost := map[uuid.UUID]time.Time{}
+	for _, feed := range s.feeds {
+		if search != "" && !strings.Contains(strings.ToLower(feed.Title), search) && !strings.Contains(strings.ToLower(feed.Url), search) {
+			continue
+		}
+		if arg.Tag != "" && !slices.Contains(s.feedTags, database.FeedTag{FeedID: feed.ID, Tag: arg.Tag}) {
+			continue
+		}
+		row := database.ListFeedsRow{
+			ID:                    feed.ID,
+			CreatedAt:             feed.CreatedAt,
+			UpdatedAt:             feed.UpdatedAt,
+			Title:                 feed.Title,
+			Url:                   feed.Url,
+			UserID:                feed.UserID,
+			LastFetchedAt:         feed.LastFetchedAt,
+			Seq:                   feed.Seq,
+			LastSuccessfulFetchAt: feed.LastSuccessfulFetchAt,
+			UserName:              s.users[s.userIndex(feed.UserID)].Name,
+		}
+		for _, follow := range s.feedFollows {
+			if follow.FeedID == feed.ID {
+				row.FollowerCount++
+				if follow.CreatedAt.After(arg.Since) {
+					row.RecentFollowerCount++
+				}
+			}
+		}
+		for _, post := range s.posts {
+			if post.FeedID != feed.ID {
+				continue
+			}
+			if post.CreatedAt.After(arg.Since) {
+				row.RecentPostCount++
+			}
+			at := post.CreatedAt
+			if post.PublishedAt.Valid {
+				at = post.PublishedAt.Time
+			}
+			if at.After(latestPost[feed.ID]) {
+				latestPost[feed.ID] = at
+			}
+		}
+		rows = append(rows, row)
+	}
+	slices.SortFunc(rows, func(a, b database.ListFeedsRow) int {
+		popular := arg.Sort == "popular"
+		switch {
+		case popular && a.RecentFollowerCount != b.RecentFollowerCount:
+			return cmp.Compare(b.RecentFollowerCount, a.RecentFollowerCount)
+		case (popular || arg.Sort == "followers") && a.FollowerCount != b.FollowerCount:
+			return cmp.Compare(b.FollowerCount, a.FollowerCount)
+		case arg.Sort == "updated" && !latestPost[a.ID].Equal(latestPost[b.ID]):
+			return latestPost[b.ID].Compare(latestPost[a.ID])
+		case !a.CreatedAt.Equal(b.CreatedAt):
+			return b.CreatedAt.Compare(a.CreatedAt)
+		}
+		return cmp.Compare(b.Seq, a.Seq)
+	})
+	rows = rows[min(int(arg.Offset), len(rows)):]
+	if len(rows) > int(arg.Limit) {
+		rows = rows[:max(arg.Limit, 0)]
+	}
+	return rows, nil
+}
+
+func (s *Store) MarkFeedFetchSucceeded(ctx context.Context, arg database.MarkFeedFetchSucceededParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.feedIndex(arg.ID); i >= 0 {
+		s.feeds[i].LastSuccessfulFetchAt = nullTimestamp(arg.LastSuccessfulFetchAt)
+	}
+	return nil
+}
+
+func (s *Store) MarkFeedFetched(ctx context.Context, arg database.MarkFeedFetchedParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.feedIndex(arg.ID)
+	if i < 0 {
+		return nil
+	}
+	s.feeds[i].LastFetchedAt = nullTimestamp(arg.LastFetchedAt)
+	s.feeds[i].UpdatedAt = timestamp(arg.UpdatedAt)
+	return nil
+}
+
+func (s *Store) CreateFeedTag(ctx context.Context, arg database.CreateFeedTagParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.feedIndex(arg.FeedID) < 0 {
+		return foreignKeyViolation("feed_tags", "feed_tags_feed_id_fkey")
+	}
+	tag := database.FeedTag(arg)
+	if !slices.Contains(s.feedTags, tag) {
+		s.feedTags = append(s.feedTags, tag)
+	}
+	return nil
+}
+
+func (s *Store) DeleteFeedTags(ctx context.Context, feedID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.feedTags = slices.DeleteFunc(s.feedTags, func(ft database.FeedTag) bool { return ft.FeedID == feedID })
+	return nil
+}
+
+func (s *Store) SetFeedTags(ctx context.Context, arg database.SetFeedTagsParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(arg.Tags) > 0 && s.feedIndex(arg.FeedID) < 0 {
+		return foreignKeyViolation("feed_tags", "feed_tags_feed_id_fkey")
+	}
+	s.feedTags = slices.DeleteFunc(s.feedTags, func(ft database.FeedTag) bool { return ft.FeedID == arg.FeedID })
+	for _, tag := range arg.Tags {
+		ft := database.FeedTag{FeedID: arg.FeedID, Tag: tag}
+		if !slices.Contains(s.feedTags, ft) {
+			s.feedTags = append(s.feedTags, ft)
+		}
+	}
+	return nil
+}
+
+func (s *Store) GetTagsForFeeds(ctx context.Context, feedIds []uuid.UUID) ([]database.FeedTag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tags []database.FeedTag
+	for _, tag := range s.feedTags {
+		if slices.Contains(feedIds, tag.FeedID) {
+			tags = append(tags, tag)
+		}
+	}
+	slices.SortStableFunc(tags, func(a, b database.FeedTag) int { return cmp.Compare(a.Tag, b.Tag) })
+	return tags, nil
+}
+
+func (s *Store) ListTags(ctx context.Context) ([]database.ListTagsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := map[string]int64{}
+	for _, tag := range s.feedTags {
+		counts[tag.Tag]++
+	}
+	var rows []database.ListTagsRow
+	for tag, count := range counts {
+		rows = append(rows, database.ListTagsRow{Tag: tag, FeedCount: count})
+	}
+	slices.SortFunc(rows, func(a, b database.ListTagsRow) int {
+		if c := cmp.Compare(b.FeedCount, a.FeedCount); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Tag, b.Tag)
+	})
+	return rows, nil
+}
+
+// feedsByFetchOrder returns feeds ordered by last_fetched_at with never fetched feeds first
+func (s *Store) feedsByFetchOrder() []database.Feed {
+	feeds := slices.Clone(s.feeds)
+	slices.SortStableFunc(feeds, func(a, b database.Feed) int {
+		switch {
+		case !a.LastFetchedAt.Valid && !b.LastFetchedAt.Valid:
+			return 0
+		case !a.LastFetchedAt.Valid:
+			return -1
+		case !b.LastFetchedAt.Valid:
+			return 1
+		}
+		return a.LastFetchedAt.Time.Compare(b.LastFetchedAt.Time)
+	})
+	return feeds
+}
+
+func (s *Store) GetNextFeedToFetch(ctx context.Context) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feeds := s.feedsByFetchOrder()
+	if len(feeds) == 0 {
+		return database.Feed{}, sql.ErrNoRows
+	}
+	return feeds[0], nil
+}
+
+func (s *Store) GetNextFeedsToFetch(ctx context.Context, arg database.GetNextFeedsToFetchParams) ([]database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.Feed
+	for _, feed := range s.feedsByFetchOrder() {
+		if len(items) >= int(arg.Limit) {
+			break
+		}
+		due := !feed.LastFetchedAt.Valid ||
+			(arg.LastFetchedAt.Valid && feed.LastFetchedAt.Time.Before(arg.LastFetchedAt.Time))
+		if due {
+			items = append(items, feed)
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.feedFollows, func(ff database.FeedFollow) bool { return ff.ID == arg.ID }) {
+		return database.CreateFeedFollowRow{}, uniqueViolation("feed_follows_pkey")
+	}
+	userIndex := s.userIndex(arg.UserID)
+	if userIndex < 0 {
+		return database.CreateFeedFollowRow{}, foreignKeyViolation("feed_follows", "feed_follows_user_id_fkey")
+	}
+	feedIndex := s.feedIndex(arg.FeedID)
+	if feedIndex < 0 {
+		return database.CreateFeedFollowRow{}, foreignKeyViolation("feed_follows", "feed_follows_feed_id_fkey")
+	}
+	if slices.ContainsFunc(s.feedFollows, func(ff database.FeedFollow) bool {
+		return ff.UserID == arg.UserID && ff.FeedID == arg.FeedID
+	}) {
+		return database.CreateFeedFollowRow{}, uniqueViolation("following")
+	}
+
+	feedFollow := database.FeedFollow{
+		ID:        arg.ID,
+		CreatedAt: timestamp(arg.CreatedAt),
+		UpdatedAt: timestamp(arg.UpdatedAt),
+		UserID:    arg.UserID,
+		FeedID:    arg.FeedID,
+	}
+	s.feedFollows = append(s.feedFollows, feedFollow)
+	return database.CreateFeedFollowRow{
+		ID:        feedFollow.ID,
+		CreatedAt: feedFollow.CreatedAt,
+		UpdatedAt: feedFollow.UpdatedAt,
+		UserID:    feedFollow.UserID,
+		FeedID:    feedFollow.FeedID,
+		FeedTitle: s.feeds[feedIndex].Title,
+		UserName:  s.users[userIndex].Name,
+	}, nil
+}
+
+func (s *Store) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFeedFollowsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.GetFeedFollowsForUserRow
+	for _, ff := range s.feedFollows {
+		if ff.UserID != userID {
+			continue
+		}
+		feed := s.feeds[s.feedIndex(ff.FeedID)]
+		creator := s.users[s.userIndex(feed.UserID)]
+		items = append(items, database.GetFeedFollowsForUserRow{
+			ID:        ff.ID,
+			CreatedAt: ff.CreatedAt,
+			UpdatedAt: ff.UpdatedAt,
+			UserID:    ff.UserID,
+			FeedID:    ff.FeedID,
+			FeedTitle: feed.Title,
+			UserName:  creator.Name,
+		})
+	}
+	return items, nil
+}
+
+func (s *Store) DeleteFeedFollow(ctx context.Context, arg database.DeleteFeedFollowParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.feedFollows = slices.DeleteFunc(s.feedFollows, func(ff database.FeedFollow) bool {
+		return ff.UserID == arg.UserID && ff.FeedID == arg.FeedID
+	})
+	return nil
+}
+func (s *Store) GetFollowedFeeds(ctx context.Context, userID uuid.UUID) ([]database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.Feed
+	for _, feed := range s.feeds {
+		if s.follows(userID, feed.ID) {
+			items = append(items, feed)
+		}
+	}
+	slices.SortFunc(items, func(a, b database.Feed) int { return cmp.Compare(a.Seq, b.Seq) })
+	return items, nil
+}
+
+func (s *Store) follows(userID, feedID uuid.UUID) bool {
+	return slices.ContainsFunc(s.feedFollows, func(ff database.FeedFollow) bool {
+		return ff.UserID == userID && ff.FeedID == feedID
+	})
+}
+
+// CreateFeedSimilarities scores every pair of feeds with a follower in common by
+// the Jaccard index of their followers and keeps the best PerFeed for each feed
+func (s *Store) CreateFeedSimilarities(ctx context.Context, arg database.CreateFeedSimilaritiesParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	followers := map[uuid.UUID]int64{}
+	common := map[[2]uuid.UUID]int64{}
+	for _, a := range s.feedFollows {
+		followers[a.FeedID]++
+		for _, b := range s.feedFollows {
+			if b.UserID == a.UserID && b.FeedID != a.FeedID {
+				common[[2]uuid.UUID{a.FeedID, b.FeedID}]++
+			}
+		}
+	}
+
+	byFeed := map[uuid.UUID][]database.FeedSimilarity{}
+	for pair, count := range common {
+		byFeed[pair[0]] = append(byFeed[pair[0]], database.FeedSimilarity{
+			FeedID:          pair[0],
+			SimilarFeedID:   pair[1],
+			CommonFollowers: count,
+			Score:           float64(count) / float64(followers[pair[0]]+followers[pair[1]]-count),
+			ComputedAt:      timestamp(arg.ComputedAt),
+		})
+	}
+	var created int64
+	for _, similar := range byFeed {
+		slices.SortFunc(similar, func(a, b database.FeedSimilarity) int {
+			if c := cmp.Compare(b.Score, a.Score); c != 0 {
+				return c
+			}
+			if c := cmp.Compare(b.CommonFollowers, a.CommonFollowers); c != 0 {
+				return c
+			}
+			return bytes.Compare(a.SimilarFeedID[:], b.SimilarFeedID[:])
+		})
+		similar = similar[:min(int64(len(similar)), max(arg.PerFeed, 0))]
+		s.similarities = append(s.similarities, similar...)
+		created += int64(len(similar))
+	}
+	return created, nil
+}
+
+func (s *Store) DeleteFeedSimilaritiesBefore(ctx context.Context, computedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.similarities = slices.DeleteFunc(s.similarities, func(fs database.FeedSimilarity) bool {
+		return fs.ComputedAt.Before(computedAt)
+	})
+	return nil
+}
+
+func (s *Store) ListFeedRecommendations(ctx context.Context, userID uuid.UUID) ([]database.ListFeedRecommendationsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var latest time.Time
+	for _, fs := range s.similarities {
+		if fs.ComputedAt.After(latest) {
+			latest = fs.ComputedAt
+		}
+	}
+	var rows []database.ListFeedRecommendationsRow
+	for _, fs := range s.similarities {
+		if !fs.ComputedAt.Equal(latest) || !s.follows(userID, fs.FeedID) || s.follows(userID, fs.SimilarFeedID) {
+			continue
+		}
+		feed := s.feeds[s.feedIndex(fs.SimilarFeedID)]
+		rows = append(rows, database.ListFeedRecommendationsRow{
+			ID:                    feed.ID,
+			CreatedAt:             feed.CreatedAt,
+			UpdatedAt:             feed.UpdatedAt,
+			Title:                 feed.Title,
+			Url:                   feed.Url,
+			UserID:                feed.UserID,
+			LastFetchedAt:         feed.LastFetchedAt,
+			Seq:                   feed.Seq,
+			LastSuccessfulFetchAt: feed.LastSuccessfulFetchAt,
+			CommonFollowers:       fs.CommonFollowers,
+			Score:                 fs.Score,
+			BecauseFeedID:         fs.FeedID,
+			BecauseFeedTitle:      s.feeds[s.feedIndex(fs.FeedID)].Title,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.ListFeedRecommendationsRow) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.CommonFollowers, a.CommonFollowers); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.BecauseFeedTitle, b.BecauseFeedTitle); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.BecauseFeedID[:], b.BecauseFeedID[:])
+	})
+	return rows, nil
+}
+
+func (s *Store) CreatePost(ctx context.Context, arg database.CreatePostParams) (database.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.postIndex(arg.ID) >= 0 {
+		return database.Post{}, uniqueViolation("posts_pkey")
+	}
+	if slices.ContainsFunc(s.posts, func(p database.Post) bool { return p.Url == arg.Url }) {
+		return database.Post{}, uniqueViolation("posts_url_key")
+	}
+	if s.feedIndex(arg.FeedID) < 0 {
+		return database.Post{}, foreignKeyViolation("posts", "posts_feed_id_fkey")
+	}
+
+	s.postSeq++
+	post := database.Post{
+		ID:          arg.ID,
+		CreatedAt:   timestamp(arg.CreatedAt),
+		UpdatedAt:   timestamp(arg.UpdatedAt),
+		Title:       arg.Title,
+		Url:         arg.Url,
+		Description: arg.Description,
+		PublishedAt: nullTimestamp(arg.PublishedAt),
+		FeedID:      arg.FeedID,
+		Seq:         s.postSeq,
+	}
+	s.posts = append(s.posts, post)
+	return post, nil
+}
+
+// GetPostsForUser orders by published_at descending with NULLs first, as Postgres
+// does, then by id
+func (s *Store) GetPostsForUser(ctx context.Context, arg database.GetPostsForUserParams) ([]database.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.Post
+	for _, ff := range s.feedFollows {
+		if ff.UserID != arg.UserID {
+			continue
+		}
+		for _, post := range s.posts {
+			if post.FeedID == ff.FeedID {
+				items = append(items, post)
+			}
+		}
+	}
+
+	slices.SortFunc(items, func(a, b database.Post) int {
+		switch {
+		case !a.PublishedAt.Valid && !b.PublishedAt.Valid:
+			return bytes.Compare(a.ID[:], b.ID[:])
+		case !a.PublishedAt.Valid:
+			return -1
+		case !b.PublishedAt.Valid:
+			return 1
+		}
+		if c := b.PublishedAt.Time.Compare(a.PublishedAt.Time); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+
+	items = items[min(max(int(arg.Offset), 0), len(items)):]
+	if len(items) > int(arg.Limit) {
+		items = items[:max(arg.Limit, 0)]
+	}
+	return items, nil
+}
+
+// userItems returns the posts in the feeds the user follows with their read and
+// saved state, ordered by seq
+func (s *Store) userItems(userID uuid.UUID) []database.GetUserItemsRow {
+	var items []database.GetUserItemsRow
+	for _, post := range s.posts {
+		if !s.follows(userID, post.FeedID) {
+			continue
+		}
+		item := database.GetUserItemsRow{
+			ID:          post.ID,
+			CreatedAt:   post.CreatedAt,
+			UpdatedAt:   post.UpdatedAt,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+			PublishedAt: post.PublishedAt,
+			FeedID:      post.FeedID,
+			Seq:         post.Seq,
+			FeedSeq:     s.feeds[s.feedIndex(post.FeedID)].Seq,
+		}
+		if i := s.postStateIndex(userID, post.ID); i >= 0 {
+			item.ReadAt = s.postStates[i].ReadAt
+			item.SavedAt = s.postStates[i].SavedAt
+		}
+		items = append(items, item)
+	}
+	slices.SortFunc(items, func(a, b database.GetUserItemsRow) int { return cmp.Compare(a.Seq, b.Seq) })
+	return items
+}
+
+func (s *Store) CountUserItems(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return int64(len(s.userItems(userID))), nil
+}
+
+func (s *Store) GetUserItem(ctx context.Context, arg database.GetUserItemParams) (database.GetUserItemRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := s.userItems(arg.UserID)
+	i := slices.IndexFunc(items, func(item database.GetUserItemsRow) bool { return item.Seq == arg.Seq })
+	if i < 0 {
+		return database.GetUserItemRow{}, sql.ErrNoRows
+	}
+	return database.GetUserItemRow(items[i]), nil
+}
+
+func (s *Store) GetUserItems(ctx context.Context, arg database.GetUserItemsParams) ([]database.GetUserItemsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.GetUserItemsRow
+	for _, item := range s.userItems(arg.UserID) {
+		switch {
+		case item.Seq <= arg.AfterSeq, arg.BeforeSeq != 0 && item.Seq >= arg.BeforeSeq:
+		case arg.FeedSeq != 0 && item.FeedSeq != arg.FeedSeq:
+		case arg.Unread && item.ReadAt.Valid, arg.Saved && !item.SavedAt.Valid:
+		case arg.CreatedAfter.Valid && !item.CreatedAt.After(arg.CreatedAfter.Time):
+		case arg.CreatedBefore.Valid && !item.CreatedAt.Before(arg.CreatedBefore.Time):
+		default:
+			items = append(items, item)
+		}
+	}
+	if arg.Descending {
+		slices.Reverse(items)
+	}
+	if len(items) > int(arg.Limit) {
+		items = items[:max(arg.Limit, 0)]
+	}
+	return items, nil
+}
+
+func (s *Store) GetUnreadItemSeqs(ctx context.Context, userID uuid.UUID) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var seqs []int64
+	for _, item := range s.userItems(userID) {
+		if !item.ReadAt.Valid {
+			seqs = append(seqs, item.Seq)
+		}
+	}
+	return seqs, nil
+}
+
+func (s *Store) GetSavedItemSeqs(ctx context.Context, userID uuid.UUID) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var seqs []int64
+	for _, item := range s.userItems(userID) {
+		if item.SavedAt.Valid {
+			seqs = append(seqs, item.Seq)
+		}
+	}
+	return seqs, nil
+}
+
+func (s *Store) postStateIndex(userID, postID uuid.UUID) int {
+	return slices.IndexFunc(s.postStates, func(ps database.PostState) bool {
+		return ps.UserID == userID && ps.PostID == postID
+	})
+}
+
+// setPostState applies update to the user's state for a post, creating the state
+// when there is none yet
+func (s *Store) setPostState(userID, postID uuid.UUID, update func(*database.PostState)) {
+	i := s.postStateIndex(userID, postID)
+	if i < 0 {
+		s.postStates = append(s.postStates, database.PostState{UserID: userID, PostID: postID})
+		i = len(s.postStates) - 1
+	}
+	update(&s.postStates[i])
+}
+
+// followedPostBySeq returns the post numbered seq if it is in a feed the user follows
+func (s *Store) followedPostBySeq(userID uuid.UUID, seq int64) (database.Post, bool) {
+	i := slices.IndexFunc(s.posts, func(p database.Post) bool { return p.Seq == seq })
+	if i < 0 || !s.follows(userID, s.posts[i].FeedID) {
+		return database.Post{}, false
+	}
+	return s.posts[i], true
+}
+
+func (s *Store) SetItemRead(ctx context.Context, arg database.SetItemReadParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.followedPostBySeq(arg.UserID, arg.Seq)
+	if !ok {
+		return 0, nil
+	}
+	s.setPostState(arg.UserID, post.ID, func(ps *database.PostState) { ps.ReadAt = nullTimestamp(arg.ReadAt) })
+	return 1, nil
+}
+
+func (s *Store) SetItemSaved(ctx context.Context, arg database.SetItemSavedParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.followedPostBySeq(arg.UserID, arg.Seq)
+	if !ok {
+		return 0, nil
+	}
+	s.setPostState(arg.UserID, post.ID, func(ps *database.PostState) { ps.SavedAt = nullTimestamp(arg.SavedAt) })
+	return 1, nil
+}
+
+// MarkItemsRead keeps the read time of items that were already read
+func (s *Store) MarkItemsRead(ctx context.Context, arg database.MarkItemsReadParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, item := range s.userItems(arg.UserID) {
+		if (arg.FeedSeq != 0 && item.FeedSeq != arg.FeedSeq) || !item.CreatedAt.Before(arg.CreatedBefore) {
+			continue
+		}
+		s.setPostState(arg.UserID, item.ID, func(ps *database.PostState) {
+			if !ps.ReadAt.Valid {
+				ps.ReadAt = sql.NullTime{Time: timestamp(arg.ReadAt), Valid: true}
+			}
+		})
+	}
+	return nil
+}
+
+func (s *Store) CreateAPIKey(ctx context.Context, arg database.CreateAPIKeyParams) (database.ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if utf8.RuneCountInString(arg.Name) > maxAPIKeyNameLength {
+		return database.ApiKey{}, fmt.Errorf("value too long for type character varying(%d)", maxAPIKeyNameLength)
+	}
+	if slices.ContainsFunc(s.apiKeys, func(k database.ApiKey) bool { return k.ID == arg.ID }) {
+		return database.ApiKey{}, uniqueViolation("api_keys_pkey")
+	}
+	if slices.ContainsFunc(s.apiKeys, func(k database.ApiKey) bool { return k.HashedKey == arg.HashedKey }) {
+		return database.ApiKey{}, uniqueViolation("api_keys_hashed_key_key")
+	}
+	if slices.ContainsFunc(s.apiKeys, func(k database.ApiKey) bool { return k.UserID == arg.UserID && k.Name == arg.Name }) {
+		return database.ApiKey{}, uniqueViolation("api_key_name")
+	}
+	if s.userIndex(arg.UserID) < 0 {
+		return database.ApiKey{}, foreignKeyViolation("api_keys", "api_keys_user_id_fkey")
+	}
+
+	key := database.ApiKey{
+		ID:        arg.ID,
+		CreatedAt: timestamp(arg.CreatedAt),
+		UpdatedAt: timestamp(arg.UpdatedAt),
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		Prefix:    arg.Prefix,
+		HashedKey: arg.HashedKey,
+		Scopes:    arg.Scopes,
+		ExpiresAt: nullTimestamp(arg.ExpiresAt),
+	}
+	s.apiKeys = append(s.apiKeys, key)
+	return key, nil
+}
+
+func (s *Store) GetAPIKeyByHash(ctx context.Context, hashedKey string) (database.ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.apiKeys, func(k database.ApiKey) bool { return k.HashedKey == hashedKey })
+	if i < 0 {
+		return database.ApiKey{}, sql.ErrNoRows
+	}
+	return s.apiKeys[i], nil
+}
+
+func (s *Store) GetAPIKeysForUser(ctx context.Context, userID uuid.UUID) ([]database.ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.ApiKey
+	for _, key := range s.apiKeys {
+		if key.UserID == userID {
+			items = append(items, key)
+		}
+	}
+	slices.SortFunc(items, func(a, b database.ApiKey) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+	return items, nil
+}
+
+func (s *Store) MarkAPIKeyUsed(ctx context.Context, arg database.MarkAPIKeyUsedParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.apiKeys, func(k database.ApiKey) bool { return k.ID == arg.ID })
+	if i < 0 {
+		return nil
+	}
+	s.apiKeys[i].LastUsedAt = nullTimestamp(arg.LastUsedAt)
+	return nil
+}
+
+func (s *Store) DeleteAPIKey(ctx context.Context, arg database.DeleteAPIKeyParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.apiKeys)
+	s.apiKeys = slices.DeleteFunc(s.apiKeys, func(k database.ApiKey) bool {
+		return k.ID == arg.ID && k.UserID == arg.UserID
+	})
+	return int64(n - len(s.apiKeys)), nil
+}
+
+func (s *Store) CreateUserIdentity(ctx context.Context, arg database.CreateUserIdentityParams) (database.UserIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.identities, func(i database.UserIdentity) bool { return i.ID == arg.ID }) {
+		return database.UserIdentity{}, uniqueViolation("user_identities_pkey")
+	}
+	if slices.ContainsFunc(s.identities, func(i database.UserIdentity) bool { return i.Issuer == arg.Issuer && i.Subject == arg.Subject }) {
+		return database.UserIdentity{}, uniqueViolation("user_identity_subject")
+	}
+	if s.userIndex(arg.UserID) < 0 {
+		return database.UserIdentity{}, foreignKeyViolation("user_identities", "user_identities_user_id_fkey")
+	}
+
+	identity := database.UserIdentity{
+		ID:        arg.ID,
+		CreatedAt: timestamp(arg.CreatedAt),
+		UserID:    arg.UserID,
+		Issuer:    arg.Issuer,
+		Subject:   arg.Subject,
+	}
+	s.identities = append(s.identities, identity)
+	return identity, nil
+}
+
+func (s *Store) GetUserIdentity(ctx context.Context, arg database.GetUserIdentityParams) (database.UserIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.identities, func(i database.UserIdentity) bool {
+		return i.Issuer == arg.Issuer && i.Subject == arg.Subject
+	})
+	if i < 0 {
+		return database.UserIdentity{}, sql.ErrNoRows
+	}
+	return s.identities[i], nil
+}
+
+func (s *Store) SetFeverAPIKey(ctx context.Context, arg database.SetFeverAPIKeyParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.userIndex(arg.UserID) < 0 {
+		return foreignKeyViolation("fever_api_keys", "fever_api_keys_user_id_fkey")
+	}
+	if slices.ContainsFunc(s.feverKeys, func(k database.FeverApiKey) bool { return k.ApiKey == arg.ApiKey && k.UserID != arg.UserID }) {
+		return uniqueViolation("fever_api_keys_api_key_key")
+	}
+
+	key := database.FeverApiKey{
+		UserID:    arg.UserID,
+		CreatedAt: timestamp(arg.CreatedAt),
+		ApiKey:    arg.ApiKey,
+	}
+	if i := slices.IndexFunc(s.feverKeys, func(k database.FeverApiKey) bool { return k.UserID == arg.UserID }); i >= 0 {
+		s.feverKeys[i] = key
+		return nil
+	}
+	s.feverKeys = append(s.feverKeys, key)
+	return nil
+}
+
+func (s *Store) GetUserIDByFeverAPIKey(ctx context.Context, apiKey string) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.feverKeys, func(k database.FeverApiKey) bool { return k.ApiKey == apiKey })
+	if i < 0 {
+		return uuid.UUID{}, sql.ErrNoRows
+	}
+	return s.feverKeys[i].UserID, nil
+}
+
+func (s *Store) DeleteFeverAPIKey(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.feverKeys)
+	s.feverKeys = slices.DeleteFunc(s.feverKeys, func(k database.FeverApiKey) bool { return k.UserID == userID })
+	return int64(n - len(s.feverKeys)), nil
+}
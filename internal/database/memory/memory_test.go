@@ -0,0 +1,164 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/database"
+	"github.com/imeltsner/gator-api/internal/database/databasetest"
+)
+
+func createUser(t *testing.T, s *Store, name string) database.User {
+	t.Helper()
+	user, err := s.CreateUser(context.Background(), database.CreateUserParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Name:      name,
+	})
+	if err != nil {
+		t.Fatalf("CreateUser(%q): %v", name, err)
+	}
+	return user
+}
+
+func createFeed(t *testing.T, s *Store, userID uuid.UUID, url string) database.Feed {
+	t.Helper()
+	feed, err := s.CreateFeed(context.Background(), database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Title:     url,
+		Url:       url,
+		UserID:    userID,
+	})
+	if err != nil {
+		t.Fatalf("CreateFeed(%q): %v", url, err)
+	}
+	return feed
+}
+
+func TestUniqueConstraints(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	user := createUser(t, s, "alice")
+	feed := createFeed(t, s, user.ID, "https://example.com/rss")
+
+	_, err := s.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), Name: "alice"})
+	if err == nil || !strings.Contains(err.Error(), "duplicate key value") {
+		t.Errorf("duplicate user name: got %v, want duplicate key error", err)
+	}
+
+	_, err = s.CreateFeed(ctx, database.CreateFeedParams{ID: uuid.New(), Url: feed.Url, UserID: user.ID})
+	if err == nil || !strings.Contains(err.Error(), "duplicate key value") {
+		t.Errorf("duplicate feed url: got %v, want duplicate key error", err)
+	}
+
+	_, err = s.CreateFeedFollow(ctx, database.CreateFeedFollowParams{ID: uuid.New(), UserID: user.ID, FeedID: feed.ID})
+	if err != nil {
+		t.Fatalf("CreateFeedFollow: %v", err)
+	}
+	_, err = s.CreateFeedFollow(ctx, database.CreateFeedFollowParams{ID: uuid.New(), UserID: user.ID, FeedID: feed.ID})
+	if err == nil || !strings.Contains(err.Error(), "duplicate key value") {
+		t.Errorf("duplicate follow: got %v, want duplicate key error", err)
+	}
+
+	_, err = s.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), Name: strings.Repeat("a", 51)})
+	if err == nil {
+		t.Error("user name longer than 50 characters: got nil error")
+	}
+}
+
+func TestDeleteUserCascades(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	aliceFeed := createFeed(t, s, alice.ID, "https://alice.example.com/rss")
+	bobFeed := createFeed(t, s, bob.ID, "https://bob.example.com/rss")
+
+	for _, follow := range []database.CreateFeedFollowParams{
+		{ID: uuid.New(), UserID: bob.ID, FeedID: aliceFeed.ID},
+		{ID: uuid.New(), UserID: bob.ID, FeedID: bobFeed.ID},
+		{ID: uuid.New(), UserID: alice.ID, FeedID: bobFeed.ID},
+	} {
+		if _, err := s.CreateFeedFollow(ctx, follow); err != nil {
+			t.Fatalf("CreateFeedFollow: %v", err)
+		}
+	}
+	for _, feed := range []database.Feed{aliceFeed, bobFeed} {
+		_, err := s.CreatePost(ctx, database.CreatePostParams{ID: uuid.New(), Url: feed.Url + "/1", FeedID: feed.ID})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+	}
+
+	if err := s.DeleteUser(ctx, alice.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	if _, err := s.GetFeedByID(ctx, aliceFeed.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("feed of deleted user: got %v, want sql.ErrNoRows", err)
+	}
+	follows, _ := s.GetFeedFollowsForUser(ctx, bob.ID)
+	if len(follows) != 1 || follows[0].FeedID != bobFeed.ID {
+		t.Errorf("bob's follows after delete: got %+v, want only %v", follows, bobFeed.ID)
+	}
+	posts, _ := s.GetPostsForUser(ctx, database.GetPostsForUserParams{UserID: bob.ID, Limit: 10})
+	if len(posts) != 1 || posts[0].FeedID != bobFeed.ID {
+		t.Errorf("bob's posts after delete: got %+v, want only posts from %v", posts, bobFeed.ID)
+	}
+}
+
+func TestGetPostsForUserOrder(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	user := createUser(t, s, "alice")
+	feed := createFeed(t, s, user.ID, "https://example.com/rss")
+	_, err := s.CreateFeedFollow(ctx, database.CreateFeedFollowParams{ID: uuid.New(), UserID: user.ID, FeedID: feed.ID})
+	if err != nil {
+		t.Fatalf("CreateFeedFollow: %v", err)
+	}
+
+	now := time.Now().UTC()
+	published := []sql.NullTime{
+		{Time: now.Add(-2 * time.Hour), Valid: true},
+		{},
+		{Time: now, Valid: true},
+	}
+	for i, publishedAt := range published {
+		_, err := s.CreatePost(ctx, database.CreatePostParams{
+			ID:          uuid.New(),
+			Title:       string(rune('a' + i)),
+			Url:         feed.Url + "/" + string(rune('a'+i)),
+			PublishedAt: publishedAt,
+			FeedID:      feed.ID,
+		})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+	}
+
+	posts, err := s.GetPostsForUser(ctx, database.GetPostsForUserParams{UserID: user.ID, Limit: 2})
+	if err != nil {
+		t.Fatalf("GetPostsForUser: %v", err)
+	}
+	var titles []string
+	for _, post := range posts {
+		titles = append(titles, post.Title)
+	}
+	if got, want := strings.Join(titles, ","), "b,c"; got != want {
+		t.Errorf("post order: got %v, want %v", got, want)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T) database.Querier {
+		return New()
+	})
+}
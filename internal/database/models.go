@@ -11,14 +11,29 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	HashedKey  string
+	Scopes     string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Title         string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                    uuid.UUID
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	Title                 string
+	Url                   string
+	UserID                uuid.UUID
+	LastFetchedAt         sql.NullTime
+	Seq                   int64
+	LastSuccessfulFetchAt sql.NullTime
 }
 
 type FeedFollow struct {
@@ -29,6 +44,25 @@ type FeedFollow struct {
 	FeedID    uuid.UUID
 }
 
+type FeedSimilarity struct {
+	FeedID          uuid.UUID
+	SimilarFeedID   uuid.UUID
+	CommonFollowers int64
+	Score           float64
+	ComputedAt      time.Time
+}
+
+type FeedTag struct {
+	FeedID uuid.UUID
+	Tag    string
+}
+
+type FeverApiKey struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ApiKey    string
+}
+
 type Post struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
@@ -38,12 +72,29 @@ type Post struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Seq         int64
+}
+
+type PostState struct {
+	UserID  uuid.UUID
+	PostID  uuid.UUID
+	ReadAt  sql.NullTime
+	SavedAt sql.NullTime
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Name           string
-	HashedPassword string
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Name            string
+	HashedPassword  string
+	PasswordVersion int64
+}
+
+type UserIdentity struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Issuer    string
+	Subject   string
 }
@@ -16,7 +16,7 @@ import (
 const createPost = `-- name: CreatePost :one
 INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, seq
 `
 
 type CreatePostParams struct {
@@ -51,25 +51,27 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Seq,
 	)
 	return i, err
 }
 
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id FROM posts
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq FROM posts
 INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
 WHERE feed_follows.user_id = $1
-ORDER BY posts.published_at DESC
-LIMIT $2
+ORDER BY posts.published_at DESC, posts.id
+LIMIT $2 OFFSET $3
 `
 
 type GetPostsForUserParams struct {
 	UserID uuid.UUID
 	Limit  int32
+	Offset int32
 }
 
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit, arg.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -86,6 +88,7 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Seq,
 		); err != nil {
 			return nil, err
 		}
@@ -99,3 +102,222 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const countUserItems = `-- name: CountUserItems :one
+SELECT COUNT(*) FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = $1
+`
+
+func (q *Queries) CountUserItems(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUserItems, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getUserItem = `-- name: GetUserItem :one
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, feeds.seq AS feed_seq, post_states.read_at, post_states.saved_at
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+INNER JOIN feeds ON feeds.id = posts.feed_id
+LEFT JOIN post_states ON post_states.user_id = feed_follows.user_id AND post_states.post_id = posts.id
+WHERE feed_follows.user_id = $1 AND posts.seq = $2
+`
+
+type GetUserItemParams struct {
+	UserID uuid.UUID
+	Seq    int64
+}
+
+type GetUserItemRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Seq         int64
+	FeedSeq     int64
+	ReadAt      sql.NullTime
+	SavedAt     sql.NullTime
+}
+
+func (q *Queries) GetUserItem(ctx context.Context, arg GetUserItemParams) (GetUserItemRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserItem, arg.UserID, arg.Seq)
+	var i GetUserItemRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Seq,
+		&i.FeedSeq,
+		&i.ReadAt,
+		&i.SavedAt,
+	)
+	return i, err
+}
+
+const getUserItems = `-- name: GetUserItems :many
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.seq, feeds.seq AS feed_seq, post_states.read_at, post_states.saved_at
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+INNER JOIN feeds ON feeds.id = posts.feed_id
+LEFT JOIN post_states ON post_states.user_id = feed_follows.user_id AND post_states.post_id = posts.id
+WHERE feed_follows.user_id = $1
+    AND posts.seq > $2
+    AND ($3::bigint = 0 OR posts.seq < $3)
+    AND ($4::bigint = 0 OR feeds.seq = $4)
+    AND (NOT $5::boolean OR post_states.read_at IS NULL)
+    AND (NOT $6::boolean OR post_states.saved_at IS NOT NULL)
+    AND ($7::timestamp IS NULL OR posts.created_at > $7)
+    AND ($8::timestamp IS NULL OR posts.created_at < $8)
+ORDER BY
+    CASE WHEN $9::boolean THEN posts.seq END DESC,
+    posts.seq
+LIMIT $10
+`
+
+type GetUserItemsParams struct {
+	UserID        uuid.UUID
+	AfterSeq      int64
+	BeforeSeq     int64
+	FeedSeq       int64
+	Unread        bool
+	Saved         bool
+	CreatedAfter  sql.NullTime
+	CreatedBefore sql.NullTime
+	Descending    bool
+	Limit         int32
+}
+
+type GetUserItemsRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Seq         int64
+	FeedSeq     int64
+	ReadAt      sql.NullTime
+	SavedAt     sql.NullTime
+}
+
+func (q *Queries) GetUserItems(ctx context.Context, arg GetUserItemsParams) ([]GetUserItemsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserItems,
+		arg.UserID,
+		arg.AfterSeq,
+		arg.BeforeSeq,
+		arg.FeedSeq,
+		arg.Unread,
+		arg.Saved,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.Descending,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserItemsRow
+	for rows.Next() {
+		var i GetUserItemsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Seq,
+			&i.FeedSeq,
+			&i.ReadAt,
+			&i.SavedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnreadItemSeqs = `-- name: GetUnreadItemSeqs :many
+SELECT posts.seq FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_states ON post_states.user_id = feed_follows.user_id AND post_states.post_id = posts.id
+WHERE feed_follows.user_id = $1 AND post_states.read_at IS NULL
+ORDER BY posts.seq
+`
+
+func (q *Queries) GetUnreadItemSeqs(ctx context.Context, userID uuid.UUID) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, getUnreadItemSeqs, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var seq int64
+		if err := rows.Scan(&seq); err != nil {
+			return nil, err
+		}
+		items = append(items, seq)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSavedItemSeqs = `-- name: GetSavedItemSeqs :many
+SELECT posts.seq FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+INNER JOIN post_states ON post_states.user_id = feed_follows.user_id AND post_states.post_id = posts.id
+WHERE feed_follows.user_id = $1 AND post_states.saved_at IS NOT NULL
+ORDER BY posts.seq
+`
+
+func (q *Queries) GetSavedItemSeqs(ctx context.Context, userID uuid.UUID) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, getSavedItemSeqs, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var seq int64
+		if err := rows.Scan(&seq); err != nil {
+			return nil, err
+		}
+		items = append(items, seq)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
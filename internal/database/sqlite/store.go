@@ -0,0 +1,562 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/database"
+	msqlite "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Store adapts the generated SQLite queries to database.Querier. Times are stored
+// in UTC so that they sort and compare correctly as text.
+type Store struct {
+	db *sql.DB
+	q  *Queries
+}
+
+var _ database.Querier = (*Store)(nil)
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db, q: New(db)}
+}
+
+// Open opens the SQLite database at path with foreign keys enforced on every
+// connection. SQLite allows a single writer, so the pool is limited to one connection.
+func Open(path string) (*sql.DB, error) {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	dsn := path + sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// wrapErr maps SQLite constraint errors onto the errors database callers check for
+func wrapErr(err error) error {
+	var sqliteErr *msqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return fmt.Errorf("%w: %v", database.ErrUniqueViolation, err)
+		}
+	}
+	return err
+}
+
+func utc(t time.Time) time.Time {
+	return t.UTC()
+}
+
+func nullUTC(t sql.NullTime) sql.NullTime {
+	if !t.Valid {
+		return t
+	}
+	return sql.NullTime{Time: t.Time.UTC(), Valid: true}
+}
+
+func (s *Store) CountUserItems(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.CountUserItems(ctx, userID)
+}
+
+func (s *Store) CreateAPIKey(ctx context.Context, arg database.CreateAPIKeyParams) (database.ApiKey, error) {
+	key, err := s.q.CreateAPIKey(ctx, CreateAPIKeyParams{
+		ID:        arg.ID,
+		CreatedAt: utc(arg.CreatedAt),
+		UpdatedAt: utc(arg.UpdatedAt),
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		Prefix:    arg.Prefix,
+		HashedKey: arg.HashedKey,
+		Scopes:    arg.Scopes,
+		ExpiresAt: nullUTC(arg.ExpiresAt),
+	})
+	return database.ApiKey(key), wrapErr(err)
+}
+
+// CreateFeed takes the next feed seq from the seqs table in the same
+// transaction as the insert, so seqs are never handed out twice
+func (s *Store) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Feed{}, err
+	}
+	defer tx.Rollback()
+	qtx := s.q.WithTx(tx)
+
+	seq, err := qtx.NextSeq(ctx, "feeds")
+	if err != nil {
+		return database.Feed{}, err
+	}
+	feed, err := qtx.CreateFeed(ctx, CreateFeedParams{
+		ID:        arg.ID,
+		CreatedAt: utc(arg.CreatedAt),
+		UpdatedAt: utc(arg.UpdatedAt),
+		Title:     arg.Title,
+		Url:       arg.Url,
+		UserID:    arg.UserID,
+		Seq:       seq,
+	})
+	if err != nil {
+		return database.Feed{}, wrapErr(err)
+	}
+	return database.Feed(feed), tx.Commit()
+}
+
+// CreateFeedFollow inserts the follow and reads it back with the feed title and
+// user name in one transaction, since SQLite doesn't allow INSERT in a CTE
+func (s *Store) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.CreateFeedFollowRow{}, err
+	}
+	defer tx.Rollback()
+	qtx := s.q.WithTx(tx)
+
+	err = qtx.CreateFeedFollow(ctx, CreateFeedFollowParams{
+		ID:        arg.ID,
+		CreatedAt: utc(arg.CreatedAt),
+		UpdatedAt: utc(arg.UpdatedAt),
+		UserID:    arg.UserID,
+		FeedID:    arg.FeedID,
+	})
+	if err != nil {
+		return database.CreateFeedFollowRow{}, wrapErr(err)
+	}
+
+	row, err := qtx.GetFeedFollowWithNames(ctx, arg.ID)
+	if err != nil {
+		return database.CreateFeedFollowRow{}, err
+	}
+	return database.CreateFeedFollowRow(row), tx.Commit()
+}
+
+func (s *Store) CreateFeedSimilarities(ctx context.Context, arg database.CreateFeedSimilaritiesParams) (int64, error) {
+	return s.q.CreateFeedSimilarities(ctx, CreateFeedSimilaritiesParams{
+		ComputedAt: utc(arg.ComputedAt),
+		PerFeed:    arg.PerFeed,
+	})
+}
+
+func (s *Store) CreateFeedTag(ctx context.Context, arg database.CreateFeedTagParams) error {
+	return wrapErr(s.q.CreateFeedTag(ctx, CreateFeedTagParams(arg)))
+}
+
+// CreatePost takes its seq like CreateFeed
+func (s *Store) CreatePost(ctx context.Context, arg database.CreatePostParams) (database.Post, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Post{}, err
+	}
+	defer tx.Rollback()
+	qtx := s.q.WithTx(tx)
+
+	seq, err := qtx.NextSeq(ctx, "posts")
+	if err != nil {
+		return database.Post{}, err
+	}
+	post, err := qtx.CreatePost(ctx, CreatePostParams{
+		ID:          arg.ID,
+		CreatedAt:   utc(arg.CreatedAt),
+		UpdatedAt:   utc(arg.UpdatedAt),
+		Title:       arg.Title,
+		Url:         arg.Url,
+		Description: arg.Description,
+		PublishedAt: nullUTC(arg.PublishedAt),
+		FeedID:      arg.FeedID,
+		Seq:         seq,
+	})
+	if err != nil {
+		return database.Post{}, wrapErr(err)
+	}
+	return database.Post(post), tx.Commit()
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	user, err := s.q.CreateUser(ctx, CreateUserParams{
+		ID:             arg.ID,
+		CreatedAt:      utc(arg.CreatedAt),
+		UpdatedAt:      utc(arg.UpdatedAt),
+		Name:           arg.Name,
+		HashedPassword: arg.HashedPassword,
+	})
+	return database.User(user), wrapErr(err)
+}
+
+func (s *Store) CreateUserIdentity(ctx context.Context, arg database.CreateUserIdentityParams) (database.UserIdentity, error) {
+	identity, err := s.q.CreateUserIdentity(ctx, CreateUserIdentityParams{
+		ID:        arg.ID,
+		CreatedAt: utc(arg.CreatedAt),
+		UserID:    arg.UserID,
+		Issuer:    arg.Issuer,
+		Subject:   arg.Subject,
+	})
+	return database.UserIdentity(identity), wrapErr(err)
+}
+
+func (s *Store) DeleteAPIKey(ctx context.Context, arg database.DeleteAPIKeyParams) (int64, error) {
+	return s.q.DeleteAPIKey(ctx, DeleteAPIKeyParams(arg))
+}
+
+func (s *Store) DeleteFeedFollow(ctx context.Context, arg database.DeleteFeedFollowParams) error {
+	return s.q.DeleteFeedFollow(ctx, DeleteFeedFollowParams(arg))
+}
+
+func (s *Store) DeleteFeedSimilaritiesBefore(ctx context.Context, computedAt time.Time) error {
+	return s.q.DeleteFeedSimilaritiesBefore(ctx, utc(computedAt))
+}
+
+func (s *Store) DeleteFeedTags(ctx context.Context, feedID uuid.UUID) error {
+	return s.q.DeleteFeedTags(ctx, feedID)
+}
+
+func (s *Store) DeleteFeverAPIKey(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.DeleteFeverAPIKey(ctx, userID)
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	return s.q.DeleteUser(ctx, id)
+}
+
+func (s *Store) DeleteUsers(ctx context.Context) error {
+	return s.q.DeleteUsers(ctx)
+}
+
+func (s *Store) GetAPIKeyByHash(ctx context.Context, hashedKey string) (database.ApiKey, error) {
+	key, err := s.q.GetAPIKeyByHash(ctx, hashedKey)
+	return database.ApiKey(key), err
+}
+
+func (s *Store) GetAPIKeysForUser(ctx context.Context, userID uuid.UUID) ([]database.ApiKey, error) {
+	keys, err := s.q.GetAPIKeysForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]database.ApiKey, len(keys))
+	for i, key := range keys {
+		items[i] = database.ApiKey(key)
+	}
+	return items, nil
+}
+
+func (s *Store) GetFeedByID(ctx context.Context, id uuid.UUID) (database.Feed, error) {
+	feed, err := s.q.GetFeedByID(ctx, id)
+	return database.Feed(feed), err
+}
+
+func (s *Store) GetFeedByURL(ctx context.Context, url string) (database.Feed, error) {
+	feed, err := s.q.GetFeedByURL(ctx, url)
+	return database.Feed(feed), err
+}
+
+func (s *Store) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFeedFollowsForUserRow, error) {
+	rows, err := s.q.GetFeedFollowsForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]database.GetFeedFollowsForUserRow, len(rows))
+	for i, row := range rows {
+		items[i] = database.GetFeedFollowsForUserRow(row)
+	}
+	return items, nil
+}
+
+func (s *Store) GetFeeds(ctx context.Context) ([]database.Feed, error) {
+	feeds, err := s.q.GetFeeds(ctx)
+	return convertFeeds(feeds), err
+}
+
+func (s *Store) GetFeedsByIDs(ctx context.Context, ids []uuid.UUID) ([]database.Feed, error) {
+	feeds, err := s.q.GetFeedsByIDs(ctx, ids)
+	return convertFeeds(feeds), err
+}
+
+func (s *Store) GetFollowedFeeds(ctx context.Context, userID uuid.UUID) ([]database.Feed, error) {
+	feeds, err := s.q.GetFollowedFeeds(ctx, userID)
+	return convertFeeds(feeds), err
+}
+
+func (s *Store) GetNextFeedToFetch(ctx context.Context) (database.Feed, error) {
+	feed, err := s.q.GetNextFeedToFetch(ctx)
+	return database.Feed(feed), err
+}
+
+func (s *Store) GetNextFeedsToFetch(ctx context.Context, arg database.GetNextFeedsToFetchParams) ([]database.Feed, error) {
+	feeds, err := s.q.GetNextFeedsToFetch(ctx, GetNextFeedsToFetchParams{
+		LastFetchedAt: nullUTC(arg.LastFetchedAt),
+		Limit:         int64(arg.Limit),
+	})
+	return convertFeeds(feeds), err
+}
+
+func (s *Store) GetPostsForUser(ctx context.Context, arg database.GetPostsForUserParams) ([]database.Post, error) {
+	posts, err := s.q.GetPostsForUser(ctx, GetPostsForUserParams{
+		UserID: arg.UserID,
+		Limit:  int64(arg.Limit),
+		Offset: int64(arg.Offset),
+	})
+	if err != nil {
+		return nil, err
+	}
+	items := make([]database.Post, len(posts))
+	for i, post := range posts {
+		items[i] = database.Post(post)
+	}
+	return items, nil
+}
+
+func (s *Store) GetSavedItemSeqs(ctx context.Context, userID uuid.UUID) ([]int64, error) {
+	return s.q.GetSavedItemSeqs(ctx, userID)
+}
+
+func (s *Store) GetTagsForFeeds(ctx context.Context, feedIds []uuid.UUID) ([]database.FeedTag, error) {
+	tags, err := s.q.GetTagsForFeeds(ctx, feedIds)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]database.FeedTag, len(tags))
+	for i, tag := range tags {
+		items[i] = database.FeedTag(tag)
+	}
+	return items, nil
+}
+
+func (s *Store) GetUnreadItemSeqs(ctx context.Context, userID uuid.UUID) ([]int64, error) {
+	return s.q.GetUnreadItemSeqs(ctx, userID)
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := s.q.GetUserByID(ctx, id)
+	return database.User(user), err
+}
+
+func (s *Store) GetUserByName(ctx context.Context, name string) (database.User, error) {
+	user, err := s.q.GetUserByName(ctx, name)
+	return database.User(user), err
+}
+
+func (s *Store) GetUserIDByFeverAPIKey(ctx context.Context, apiKey string) (uuid.UUID, error) {
+	return s.q.GetUserIDByFeverAPIKey(ctx, apiKey)
+}
+
+func (s *Store) GetUserIdentity(ctx context.Context, arg database.GetUserIdentityParams) (database.UserIdentity, error) {
+	identity, err := s.q.GetUserIdentity(ctx, GetUserIdentityParams(arg))
+	return database.UserIdentity(identity), err
+}
+
+func (s *Store) GetUserItem(ctx context.Context, arg database.GetUserItemParams) (database.GetUserItemRow, error) {
+	item, err := s.q.GetUserItem(ctx, GetUserItemParams(arg))
+	return database.GetUserItemRow(item), err
+}
+
+func (s *Store) GetUserItems(ctx context.Context, arg database.GetUserItemsParams) ([]database.GetUserItemsRow, error) {
+	rows, err := s.q.GetUserItems(ctx, GetUserItemsParams{
+		UserID:        arg.UserID,
+		AfterSeq:      arg.AfterSeq,
+		BeforeSeq:     arg.BeforeSeq,
+		FeedSeq:       arg.FeedSeq,
+		Unread:        arg.Unread,
+		Saved:         arg.Saved,
+		CreatedAfter:  nullUTC(arg.CreatedAfter),
+		CreatedBefore: nullUTC(arg.CreatedBefore),
+		Descending:    arg.Descending,
+		Limit:         int64(arg.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	items := make([]database.GetUserItemsRow, len(rows))
+	for i, row := range rows {
+		items[i] = database.GetUserItemsRow(row)
+	}
+	return items, nil
+}
+
+func (s *Store) GetUserNameByID(ctx context.Context, id uuid.UUID) (string, error) {
+	return s.q.GetUserNameByID(ctx, id)
+}
+
+func (s *Store) GetUsers(ctx context.Context) ([]database.User, error) {
+	users, err := s.q.GetUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]database.User, len(users))
+	for i, user := range users {
+		items[i] = database.User(user)
+	}
+	return items, nil
+}
+
+func (s *Store) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]database.User, error) {
+	users, err := s.q.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]database.User, len(users))
+	for i, user := range users {
+		items[i] = database.User(user)
+	}
+	return items, nil
+}
+
+func (s *Store) ListFeedRecommendations(ctx context.Context, userID uuid.UUID) ([]database.ListFeedRecommendationsRow, error) {
+	rows, err := s.q.ListFeedRecommendations(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]database.ListFeedRecommendationsRow, len(rows))
+	for i, row := range rows {
+		items[i] = database.ListFeedRecommendationsRow(row)
+	}
+	return items, nil
+}
+
+func (s *Store) ListFeeds(ctx context.Context, arg database.ListFeedsParams) ([]database.ListFeedsRow, error) {
+	rows, err := s.q.ListFeeds(ctx, ListFeedsParams{
+		Since:  utc(arg.Since),
+		Search: arg.Search,
+		Tag:    arg.Tag,
+		Sort:   arg.Sort,
+		Limit:  int64(arg.Limit),
+		Offset: int64(arg.Offset),
+	})
+	if err != nil {
+		return nil, err
+	}
+	items := make([]database.ListFeedsRow, len(rows))
+	for i, row := range rows {
+		items[i] = database.ListFeedsRow(row)
+	}
+	return items, nil
+}
+
+func (s *Store) ListTags(ctx context.Context) ([]database.ListTagsRow, error) {
+	rows, err := s.q.ListTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]database.ListTagsRow, len(rows))
+	for i, row := range rows {
+		items[i] = database.ListTagsRow(row)
+	}
+	return items, nil
+}
+
+func (s *Store) MarkAPIKeyUsed(ctx context.Context, arg database.MarkAPIKeyUsedParams) error {
+	return s.q.MarkAPIKeyUsed(ctx, MarkAPIKeyUsedParams{
+		LastUsedAt: nullUTC(arg.LastUsedAt),
+		ID:         arg.ID,
+	})
+}
+
+func (s *Store) MarkFeedFetchSucceeded(ctx context.Context, arg database.MarkFeedFetchSucceededParams) error {
+	return s.q.MarkFeedFetchSucceeded(ctx, MarkFeedFetchSucceededParams{
+		LastSuccessfulFetchAt: nullUTC(arg.LastSuccessfulFetchAt),
+		ID:                    arg.ID,
+	})
+}
+
+func (s *Store) MarkFeedFetched(ctx context.Context, arg database.MarkFeedFetchedParams) error {
+	return s.q.MarkFeedFetched(ctx, MarkFeedFetchedParams{
+		LastFetchedAt: nullUTC(arg.LastFetchedAt),
+		UpdatedAt:     utc(arg.UpdatedAt),
+		ID:            arg.ID,
+	})
+}
+
+func (s *Store) MarkItemsRead(ctx context.Context, arg database.MarkItemsReadParams) error {
+	return s.q.MarkItemsRead(ctx, MarkItemsReadParams{
+		ReadAt:        utc(arg.ReadAt),
+		UserID:        arg.UserID,
+		FeedSeq:       arg.FeedSeq,
+		CreatedBefore: utc(arg.CreatedBefore),
+	})
+}
+
+func (s *Store) RehashUserPassword(ctx context.Context, arg database.RehashUserPasswordParams) error {
+	return s.q.RehashUserPassword(ctx, RehashUserPasswordParams{
+		HashedPassword: arg.HashedPassword,
+		UpdatedAt:      utc(arg.UpdatedAt),
+		ID:             arg.ID,
+	})
+}
+
+// SetFeedTags replaces the feed's tags in one transaction, since SQLite has no
+// arrays to do it in one statement with
+func (s *Store) SetFeedTags(ctx context.Context, arg database.SetFeedTagsParams) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.q.WithTx(tx)
+
+	err = qtx.DeleteFeedTags(ctx, arg.FeedID)
+	if err != nil {
+		return err
+	}
+	for _, tag := range arg.Tags {
+		err = qtx.CreateFeedTag(ctx, CreateFeedTagParams{FeedID: arg.FeedID, Tag: tag})
+		if err != nil {
+			return wrapErr(err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Store) SetFeverAPIKey(ctx context.Context, arg database.SetFeverAPIKeyParams) error {
+	err := s.q.SetFeverAPIKey(ctx, SetFeverAPIKeyParams{
+		UserID:    arg.UserID,
+		CreatedAt: utc(arg.CreatedAt),
+		ApiKey:    arg.ApiKey,
+	})
+	return wrapErr(err)
+}
+
+func (s *Store) SetItemRead(ctx context.Context, arg database.SetItemReadParams) (int64, error) {
+	return s.q.SetItemRead(ctx, SetItemReadParams{
+		ReadAt: nullUTC(arg.ReadAt),
+		UserID: arg.UserID,
+		Seq:    arg.Seq,
+	})
+}
+
+func (s *Store) SetItemSaved(ctx context.Context, arg database.SetItemSavedParams) (int64, error) {
+	return s.q.SetItemSaved(ctx, SetItemSavedParams{
+		SavedAt: nullUTC(arg.SavedAt),
+		UserID:  arg.UserID,
+		Seq:     arg.Seq,
+	})
+}
+
+func (s *Store) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) error {
+	return s.q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+		HashedPassword: arg.HashedPassword,
+		UpdatedAt:      utc(arg.UpdatedAt),
+		ID:             arg.ID,
+	})
+}
+
+func convertFeeds(feeds []Feed) []database.Feed {
+	if feeds == nil {
+		return nil
+	}
+	items := make([]database.Feed, len(feeds))
+	for i, feed := range feeds {
+		items[i] = database.Feed(feed)
+	}
+	return items
+}
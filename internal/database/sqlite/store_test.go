@@ -0,0 +1,31 @@
+package sqlite
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/imeltsner/gator-api/internal/database"
+	"github.com/imeltsner/gator-api/internal/database/databasetest"
+	"github.com/pressly/goose/v3"
+)
+
+func TestConformance(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T) database.Querier {
+		db, err := Open(filepath.Join(t.TempDir(), "gator.db"))
+		if err != nil {
+			t.Fatalf("open sqlite: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		provider, err := goose.NewProvider(goose.DialectSQLite3, db, os.DirFS("../../../sql/sqlite/schema"))
+		if err != nil {
+			t.Fatalf("create migration provider: %v", err)
+		}
+		if _, err := provider.Up(context.Background()); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		return NewStore(db)
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -21,7 +22,7 @@ VALUES (
     $4,
     $5
 )
-RETURNING id, created_at, updated_at, name, hashed_password
+RETURNING id, created_at, updated_at, name, hashed_password, password_version
 `
 
 type CreateUserParams struct {
@@ -47,6 +48,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Name,
 		&i.HashedPassword,
+		&i.PasswordVersion,
 	)
 	return i, err
 }
@@ -71,7 +73,7 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, name, hashed_password FROM users
+SELECT id, created_at, updated_at, name, hashed_password, password_version FROM users
 WHERE id = $1
 `
 
@@ -84,12 +86,13 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.UpdatedAt,
 		&i.Name,
 		&i.HashedPassword,
+		&i.PasswordVersion,
 	)
 	return i, err
 }
 
 const getUserByName = `-- name: GetUserByName :one
-SELECT id, created_at, updated_at, name, hashed_password FROM users
+SELECT id, created_at, updated_at, name, hashed_password, password_version FROM users
 WHERE name = $1
 `
 
@@ -102,6 +105,7 @@ func (q *Queries) GetUserByName(ctx context.Context, name string) (User, error)
 		&i.UpdatedAt,
 		&i.Name,
 		&i.HashedPassword,
+		&i.PasswordVersion,
 	)
 	return i, err
 }
@@ -119,7 +123,7 @@ func (q *Queries) GetUserNameByID(ctx context.Context, id uuid.UUID) (string, er
 }
 
 const getUsers = `-- name: GetUsers :many
-SELECT id, created_at, updated_at, name, hashed_password FROM users
+SELECT id, created_at, updated_at, name, hashed_password, password_version FROM users
 `
 
 func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
@@ -137,6 +141,7 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 			&i.UpdatedAt,
 			&i.Name,
 			&i.HashedPassword,
+			&i.PasswordVersion,
 		); err != nil {
 			return nil, err
 		}
@@ -150,3 +155,72 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 	}
 	return items, nil
 }
+
+const getUsersByIDs = `-- name: GetUsersByIDs :many
+SELECT id, created_at, updated_at, name, hashed_password, password_version FROM users
+WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.HashedPassword,
+			&i.PasswordVersion,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rehashUserPassword = `-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = $3
+WHERE id = $1
+`
+
+type RehashUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+	UpdatedAt      time.Time
+}
+
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, rehashUserPassword, arg.ID, arg.HashedPassword, arg.UpdatedAt)
+	return err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = $3, password_version = password_version + 1
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+	UpdatedAt      time.Time
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword, arg.UpdatedAt)
+	return err
+}
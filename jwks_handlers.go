@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/imeltsner/gator-api/internal/auth"
+	"github.com/imeltsner/gator-api/internal/config"
+)
+
+// jwksMaxAge is how long clients may cache the key set. New keys should be
+// published for at least this long before tokens are signed with them.
+const jwksMaxAge = 300
+
+// newKeySet builds the keys tokens are signed and verified with. New tokens are
+// signed with the private key file if there is one, otherwise the jwt secret,
+// unless the config names another signing key. Previous secrets only verify.
+func newKeySet(cfg config.Config) (*auth.KeySet, error) {
+	var keys []auth.Key
+	signingID := cfg.JWT.SigningKey
+	defaultID := ""
+
+	if cfg.JWTSecret != "" {
+		key := auth.NewHMACKey("", []byte(cfg.JWTSecret))
+		keys = append(keys, key)
+		defaultID = key.ID
+	}
+	for _, secret := range cfg.JWT.PreviousSecrets {
+		keys = append(keys, auth.NewHMACKey("", []byte(secret)))
+	}
+	if cfg.JWT.PrivateKeyFile != "" {
+		key, err := readPrivateKey("", cfg.JWT.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		defaultID = key.ID
+	}
+	for _, configured := range cfg.JWT.Keys {
+		var key auth.Key
+		if configured.Secret != "" {
+			key = auth.NewHMACKey(configured.ID, []byte(configured.Secret))
+		} else {
+			var err error
+			key, err = readPrivateKey(configured.ID, configured.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+		key.VerifyUntil = configured.VerifyUntil
+		keys = append(keys, key)
+	}
+
+	if signingID == "" {
+		signingID = defaultID
+	}
+	keySet, err := auth.NewKeySet(cfg.JWT.Issuer, cfg.JWT.Audience, signingID, keys...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load jwt keys: %v", err)
+	}
+	return keySet, nil
+}
+
+func readPrivateKey(id, path string) (auth.Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return auth.Key{}, fmt.Errorf("unable to read jwt private key: %v", err)
+	}
+	key, err := auth.ParsePrivateKeyPEM(id, data)
+	if err != nil {
+		return auth.Key{}, fmt.Errorf("unable to load jwt private key %v: %v", path, err)
+	}
+	return key, nil
+}
+
+func (s *state) handlerJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", jwksMaxAge))
+	respondWithJSON(w, http.StatusOK, s.keys.JWKS())
+}
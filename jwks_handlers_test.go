@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/auth"
+	"github.com/imeltsner/gator-api/internal/config"
+)
+
+func TestNewKeySet(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "jwt.pem")
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := "a-previous-secret-that-is-32-chars-long"
+	oldToken, err := newTestKeySet().MakeJWT(uuid.New(), time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.JWTSecret = previous
+	cfg.JWT.PreviousSecrets = []string{testJWTSecret}
+	cfg.JWT.PrivateKeyFile = keyFile
+	keys, err := newKeySet(cfg)
+	if err != nil {
+		t.Fatalf("newKeySet: %v", err)
+	}
+	if _, err := keys.ValidateJWT(oldToken); err != nil {
+		t.Errorf("token signed with a previous secret: %v", err)
+	}
+
+	// Tokens are signed with the private key, which is published
+	s := &state{keys: keys}
+	w := httptest.NewRecorder()
+	s.handlerJWKS(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want %d", w.Code, http.StatusOK)
+	}
+	if cache := w.Header().Get("Cache-Control"); !strings.Contains(cache, "max-age") {
+		t.Errorf("Cache-Control: got %q", cache)
+	}
+	var jwks auth.JWKS
+	if err := json.NewDecoder(w.Body).Decode(&jwks); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != keys.SigningKeyID() || jwks.Keys[0].Alg != auth.EdDSA {
+		t.Errorf("jwks: got %+v, want only the signing key %v", jwks.Keys, keys.SigningKeyID())
+	}
+
+	// A configured key can take over signing, and retired keys stop verifying
+	cfg.JWT.Keys = []config.JWTKey{
+		{ID: "next", Secret: "the-next-secret-that-is-32-chars-long"},
+		{ID: "gone", Secret: "a-retired-secret-that-is-32-chars-long", VerifyUntil: time.Now().Add(-time.Hour)},
+	}
+	cfg.JWT.SigningKey = "next"
+	keys, err = newKeySet(cfg)
+	if err != nil {
+		t.Fatalf("newKeySet: %v", err)
+	}
+	if keys.SigningKeyID() != "next" {
+		t.Errorf("signing key: got %v, want next", keys.SigningKeyID())
+	}
+
+	cfg.JWT.SigningKey = "gone"
+	if _, err := newKeySet(cfg); err == nil {
+		t.Error("a retired signing key was accepted")
+	}
+}
@@ -1,61 +1,290 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/imeltsner/gator-api/internal/auth"
+	"github.com/imeltsner/gator-api/internal/config"
 	"github.com/imeltsner/gator-api/internal/database"
-	"github.com/joho/godotenv"
+	"github.com/pressly/goose/v3"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 
 	_ "github.com/lib/pq"
 )
 
 type state struct {
-	db        *database.Queries
-	jwtSecret string
+	db           database.Querier
+	sqlDB        *sql.DB
+	migrations   *goose.Provider
+	sched        *scheduler
+	keys         *auth.KeySet
+	oidc         *auth.OIDCProvider
+	bcryptCost   int
+	fetchTimeout time.Duration
+	rateLimiters *rateLimiters
+	logins       *loginThrottle
+	newPosts     postNotifier
 }
 
 func main() {
-	// Load environment variables
-	godotenv.Load()
-	dbString := os.Getenv("DB_CONNECTION")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := migrateCommand(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "password-reset" {
+		err := passwordResetCommand(os.Args[2:], os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "password-reset: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Connect to db
-	db, err := sql.Open("postgres", dbString)
+	// Load configuration
+	fs := flag.NewFlagSet("gator-api", flag.ExitOnError)
+	printConfig := fs.Bool("print-config", false, "print the effective configuration and exit")
+	cfg, err := config.Load(fs, os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to load config: %v\n", err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		fmt.Print(cfg)
+		return
+	}
+	err = cfg.Validate()
 	if err != nil {
-		fmt.Printf("unable to connect to db: %v", err)
+		fmt.Fprintf(os.Stderr, "invalid config:\n%v\n", err)
 		os.Exit(1)
 	}
-	dbQueries := database.New(db)
+	slog.SetDefault(newLogger(cfg.LogLevel))
+
+	err = run(cfg)
+	if err != nil {
+		slog.Error("gator exited", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+func run(cfg config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("loaded config", slog.String("config", cfg.String()))
+
+	// Connect to db
+	store, err := openStorage(cfg.DBConnection)
+	if err != nil {
+		return err
+	}
+	db := store.db
+	defer db.Close()
+
+	pingCtx, cancelPing := context.WithTimeout(ctx, 5*time.Second)
+	err = db.PingContext(pingCtx)
+	cancelPing()
+	if err != nil {
+		return fmt.Errorf("unable to reach db: %v", err)
+	}
+
+	migrations, err := store.newMigrationProvider()
+	if err != nil {
+		return err
+	}
+	defer migrations.Close()
+
+	err = checkSchema(ctx, migrations, cfg.AutoMigrate)
+	if err != nil {
+		return err
+	}
+
+	keys, err := newKeySet(cfg)
+	if err != nil {
+		return err
+	}
+	slog.Info("loaded jwt keys", slog.String("signing_key", keys.SigningKeyID()))
 
 	s := state{
-		db:        dbQueries,
-		jwtSecret: os.Getenv("JWT_SECRET"),
+		db:           store.queries,
+		sqlDB:        db,
+		migrations:   migrations,
+		keys:         keys,
+		bcryptCost:   cfg.BcryptCost,
+		fetchTimeout: cfg.Fetch.Timeout,
 	}
+	if cfg.OIDC.Issuer != "" {
+		s.oidc = auth.NewOIDCProvider(auth.OIDCConfig{
+			Issuer:       cfg.OIDC.Issuer,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       cfg.OIDC.Scopes,
+		}, &http.Client{Timeout: oidcTimeout})
+	}
+	if cfg.RateLimit.Enabled {
+		s.rateLimiters = newRateLimiters(rateLimit{requests: cfg.RateLimit.Requests, window: cfg.RateLimit.Window}, cfg.RateLimit.TrustProxy)
+		s.logins = newLoginThrottle(cfg.RateLimit.LoginMaxFailures, cfg.RateLimit.LoginLockout)
+	}
+
+	// Start feed scheduler
+	s.sched = newScheduler(&s, cfg.Fetch.Interval, cfg.Fetch.Workers)
+	s.sched.start(ctx)
+	registerRuntimeMetrics(db, s.sched)
+
+	// Start recommendations job
+	recommenderDone := make(chan struct{})
+	go func() {
+		s.runRecommender(ctx, cfg.Recommendations.Interval)
+		close(recommenderDone)
+	}()
 
 	// Create http server
-	port := os.Getenv("PORT")
-	mux := http.NewServeMux()
 	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+		Addr:              ":" + strconv.Itoa(cfg.Port),
+		Handler:           s.routes(),
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+
+	// Start server
+	serverErr := make(chan error, 2)
+	go func() {
+		slog.Info("serving", slog.Int("port", cfg.Port))
+		serverErr <- server.ListenAndServe()
+	}()
+
+	// Start gRPC server on its own port
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort != 0 {
+		listener, err := net.Listen("tcp", ":"+strconv.Itoa(cfg.GRPCPort))
+		if err != nil {
+			return fmt.Errorf("unable to listen for grpc: %v", err)
+		}
+		grpcServer = s.newGRPCServer(ctx.Done())
+		go func() {
+			slog.Info("serving grpc", slog.Int("port", cfg.GRPCPort))
+			serverErr <- grpcServer.Serve(listener)
+		}()
+	}
+
+	select {
+	case err = <-serverErr:
+		stop()
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	}
+
+	// Drain in-flight requests and feed fetches
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil {
+		slog.Error("unable to shut down server cleanly", slog.Any("error", shutdownErr))
+	}
+	if grpcServer != nil {
+		stopGRPC(shutdownCtx, grpcServer)
+	}
+	if shutdownErr := s.sched.shutdown(shutdownCtx); shutdownErr != nil {
+		slog.Error("unable to drain feed scheduler", slog.Any("error", shutdownErr))
+	}
+	select {
+	case <-recommenderDone:
+	case <-shutdownCtx.Done():
+		slog.Error("unable to stop recommendations job", slog.Any("error", shutdownCtx.Err()))
+	}
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server stopped: %v", err)
+	}
+	slog.Info("shutdown complete")
+	return nil
+}
+
+// stopGRPC waits for in-flight calls to finish, cancelling any left when ctx is
+// done
+func stopGRPC(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Error("unable to shut down grpc server cleanly", slog.Any("error", ctx.Err()))
+		server.Stop()
 	}
+}
+
+// routeMux records the patterns registered on it so they can be checked against
+// the OpenAPI document
+type routeMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (mux *routeMux) Handle(pattern string, handler http.Handler) {
+	mux.patterns = append(mux.patterns, pattern)
+	mux.ServeMux.Handle(pattern, handler)
+}
+
+func (mux *routeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	mux.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// routes registers every handler on a new mux and wraps it in the common middleware
+func (s *state) routes() http.Handler {
+	return s.middlewareLog(middlewareMetrics(s.middlewareRateLimit(s.newMux())))
+}
+
+func (s *state) newMux() *routeMux {
+	mux := &routeMux{ServeMux: http.NewServeMux()}
 
 	// Register user routes
 	mux.HandleFunc("POST /api/login", s.handlerLogin)
 	mux.HandleFunc("POST /api/users", s.handlerCreateUser)
 	mux.HandleFunc("GET /api/users/{id}", s.handlerGetUser) // authenticated
 	mux.HandleFunc("GET /api/users", s.handlerGetUsers)
-	mux.HandleFunc("DELETE /api/users/{id}", s.handlerDeleteUser) // authenticated
+	mux.HandleFunc("DELETE /api/users/{id}", s.handlerDeleteUser)           // authenticated
+	mux.HandleFunc("PUT /api/users/{id}/password", s.handlerChangePassword) // authenticated
+	mux.HandleFunc("POST /api/password-reset", s.handlerResetPassword)
 	mux.HandleFunc("DELETE /admin/reset", s.handlerDeleteUsers)
+	mux.HandleFunc("GET /.well-known/jwks.json", s.handlerJWKS)
+	mux.HandleFunc("GET /api/oidc/login", s.handlerOIDCLogin)
+	mux.HandleFunc("GET /api/oidc/callback", s.handlerOIDCCallback)
+
+	// Register API key routes
+	mux.HandleFunc("POST /api/api-keys", s.handlerCreateAPIKey)        // authenticated
+	mux.HandleFunc("GET /api/api-keys", s.handlerGetAPIKeys)           // authenticated
+	mux.HandleFunc("DELETE /api/api-keys/{id}", s.handlerDeleteAPIKey) // authenticated
 
 	// Register feed routes
 	mux.HandleFunc("POST /api/feeds", s.handlerAddFeed) // authenticated
 	mux.HandleFunc("GET /api/feeds/{id}", s.handlerGetFeed)
 	mux.HandleFunc("GET /api/feeds", s.handlerGetFeeds)
+	mux.HandleFunc("PUT /api/feeds/{id}/tags", s.handlerSetFeedTags) // authenticated
+	mux.HandleFunc("GET /api/tags", s.handlerGetTags)
 	mux.HandleFunc("POST /api/agg", s.handlerAggregate)
 
 	// Register follow routes
@@ -63,10 +292,121 @@ func main() {
 	mux.HandleFunc("GET /api/follows", s.handlerFollowing)   // authenticated
 	mux.HandleFunc("DELETE /api/follows", s.handlerUnfollow) // authenticated
 
+	// Register recommendation routes
+	mux.HandleFunc("GET /api/recommendations/feeds", s.handlerGetFeedRecommendations) // authenticated
+
 	// Register post routes
 	mux.HandleFunc("GET /api/posts", s.handlerBrowse) // authenticated
 
-	// Start server
-	log.Printf("Serving on port: %s\n", port)
-	log.Fatal(server.ListenAndServe())
+	// Register Fever API routes. The API itself is authenticated by the api_key
+	// form value.
+	mux.HandleFunc("PUT /api/users/{id}/fever", s.handlerSetFeverPassword)       // authenticated
+	mux.HandleFunc("DELETE /api/users/{id}/fever", s.handlerDeleteFeverPassword) // authenticated
+	mux.HandleFunc("POST /fever/{$}", s.handlerFever)
+
+	// Register Google Reader API routes. They are authenticated by the token from
+	// ClientLogin.
+	mux.HandleFunc("POST /accounts/ClientLogin", s.handlerReaderLogin)
+	mux.HandleFunc("GET /reader/api/0/token", s.handlerReaderToken)
+	mux.HandleFunc("GET /reader/api/0/user-info", s.handlerReaderUserInfo)
+	mux.HandleFunc("GET /reader/api/0/subscription/list", s.handlerReaderSubscriptions)
+	mux.HandleFunc("POST /reader/api/0/subscription/edit", s.handlerReaderEditSubscription)
+	mux.HandleFunc("POST /reader/api/0/subscription/quickadd", s.handlerReaderQuickAdd)
+	mux.HandleFunc("GET /reader/api/0/tag/list", s.handlerReaderTags)
+	mux.HandleFunc("GET /reader/api/0/stream/items/ids", s.handlerReaderItemIDs)
+	mux.HandleFunc("POST /reader/api/0/stream/items/contents", s.handlerReaderItemContents)
+	mux.HandleFunc("GET /reader/api/0/stream/contents/{stream...}", s.handlerReaderStreamContents)
+	mux.HandleFunc("POST /reader/api/0/edit-tag", s.handlerReaderEditTag)
+	mux.HandleFunc("POST /reader/api/0/mark-all-as-read", s.handlerReaderMarkAllRead)
+
+	// Register GraphQL routes
+	mux.HandleFunc("POST /graphql", s.handlerGraphQL) // authenticated
+
+	// Register operational routes
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", s.handlerHealth)
+	mux.HandleFunc("GET /readyz", s.handlerReady)
+
+	// Register documentation routes
+	mux.HandleFunc("GET /api/openapi.json", s.handlerOpenAPI)
+	mux.HandleFunc("GET /api/docs", s.handlerDocs)
+
+	return mux
+}
+
+func migrateCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gator-api migrate up|down|status [flags]")
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("gator-api migrate "+action, flag.ExitOnError)
+	cfg, err := config.Load(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	err = config.ValidateDSN(cfg.DBConnection)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStorage(cfg.DBConnection)
+	if err != nil {
+		return err
+	}
+	defer store.db.Close()
+
+	provider, err := store.newMigrationProvider()
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+
+	return runMigrate(context.Background(), provider, action, os.Stdout)
+}
+
+// passwordResetCommand prints a one time token the named user can redeem at
+// POST /api/password-reset to set a new password
+func passwordResetCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("gator-api password-reset", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gator-api password-reset [flags] <name>")
+		fs.PrintDefaults()
+	}
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("a user name is required")
+	}
+	err = config.ValidateDSN(cfg.DBConnection)
+	if err != nil {
+		return err
+	}
+	keys, err := newKeySet(cfg)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStorage(cfg.DBConnection)
+	if err != nil {
+		return err
+	}
+	defer store.db.Close()
+
+	user, err := store.queries.GetUserByName(context.Background(), fs.Arg(0))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("user %q not found", fs.Arg(0))
+	} else if err != nil {
+		return fmt.Errorf("unable to get user: %v", err)
+	}
+
+	token, err := keys.MakePasswordResetJWT(user.ID, user.HashedPassword, passwordResetExpiry)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Reset token for %v, valid for %v:\n%v\n", user.Name, passwordResetExpiry, token)
+	return nil
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gator",
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gator",
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by method and route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	feedFetches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gator",
+		Name:      "feed_fetches_total",
+		Help:      "Feed fetches, by outcome.",
+	}, []string{"outcome"})
+
+	feedFetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gator",
+		Name:      "feed_fetch_duration_seconds",
+		Help:      "Time spent fetching and parsing a feed, by outcome.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"outcome"})
+
+	postsSaved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gator",
+		Name:      "posts_saved_total",
+		Help:      "Posts processed while saving feeds, by result (inserted or duplicate).",
+	}, []string{"result"})
+)
+
+func observeFeedFetch(outcome string, duration time.Duration) {
+	feedFetches.WithLabelValues(outcome).Inc()
+	feedFetchDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+}
+
+// registerRuntimeMetrics registers collectors that read from live resources
+func registerRuntimeMetrics(db *sql.DB, sched *scheduler) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "gator"))
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "gator",
+		Name:      "scheduler_queue_depth",
+		Help:      "Feeds waiting in the scheduler queue to be fetched.",
+	}, func() float64 { return float64(sched.queueDepth()) }))
+}
+
+func middlewareMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+		httpRequestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/auth"
+	"github.com/imeltsner/gator-api/internal/database"
+)
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (s *state) middlewareLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		r = r.WithContext(contextWithRequestID(r.Context(), requestID))
+		slot := &callerSlot{}
+		r = r.WithContext(context.WithValue(r.Context(), callerKey, slot))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		attrs := []any{
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.Int("status", rec.status),
+			slog.Duration("duration", time.Since(start)),
+		}
+		if c := slot.caller; c != nil && c.err == nil {
+			attrs = append(attrs, slog.String("user_id", c.userID.String()))
+		}
+		slog.InfoContext(r.Context(), "request handled", attrs...)
+	})
+}
+
+// caller is the user a request's bearer token belongs to, whatever an API key's
+// scopes. It is resolved at most once per request, so the rate limiter,
+// authenticate and the log don't each look the token up.
+type caller struct {
+	userID uuid.UUID
+	// apiKey is set when the token is an API key
+	apiKey *database.ApiKey
+	// err is the *requestError to reject the token with, if it isn't valid
+	err error
+}
+
+func (s *state) resolveCaller(r *http.Request) *caller {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return &caller{err: &requestError{status: http.StatusUnauthorized, message: "unable to parse auth header", err: err}}
+	}
+	if !auth.IsAPIKey(token) {
+		id, err := s.keys.ValidateJWT(token)
+		if err != nil {
+			return &caller{err: &requestError{status: http.StatusUnauthorized, message: "unable to validate jwt", err: err}}
+		}
+		return &caller{userID: id}
+	}
+
+	key, err := s.lookupAPIKey(r.Context(), token)
+	if errors.Is(err, errInvalidAPIKey) {
+		return &caller{err: &requestError{status: http.StatusUnauthorized, message: err.Error()}}
+	} else if err != nil {
+		return &caller{err: &requestError{status: http.StatusInternalServerError, message: "unable to get api key", err: err}}
+	}
+	return &caller{userID: key.UserID, apiKey: &key}
+}
+
+// callerSlot is where middlewareLog keeps the caller once something has needed it
+type callerSlot struct {
+	caller *caller
+}
+
+// requestCaller resolves the request's caller the first time it is asked for. API
+// keys cost a database lookup, so only handlers that authenticate should ask
+// before the request has passed the rate limiter.
+func (s *state) requestCaller(r *http.Request) *caller {
+	slot, ok := r.Context().Value(callerKey).(*callerSlot)
+	if !ok {
+		return s.resolveCaller(r)
+	}
+	if slot.caller == nil {
+		slot.caller = s.resolveCaller(r)
+	}
+	return slot.caller
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
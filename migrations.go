@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+//go:embed sql/schema/*.sql sql/sqlite/schema/*.sql
+var embedMigrations embed.FS
+
+// checkSchema makes sure the database schema is at the version the embedded
+// migrations (and so the generated queries) expect, applying pending migrations
+// first if autoMigrate is set
+func checkSchema(ctx context.Context, provider *goose.Provider, autoMigrate bool) error {
+	current, target, err := provider.GetVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get schema version: %v", err)
+	}
+
+	if current < target && autoMigrate {
+		slog.Info("applying migrations", slog.Int64("from", current), slog.Int64("to", target))
+		results, err := provider.Up(ctx)
+		for _, result := range results {
+			slog.Info("migration applied", slog.String("migration", result.Source.Path), slog.Duration("duration", result.Duration))
+		}
+		if err != nil {
+			return fmt.Errorf("unable to apply migrations: %v", err)
+		}
+		return nil
+	}
+
+	if current < target {
+		return fmt.Errorf("database schema is at version %d but %d is required; run `gator-api migrate up` or start with -auto-migrate", current, target)
+	}
+	if current > target {
+		slog.Warn("database schema is newer than this binary", slog.Int64("current", current), slog.Int64("expected", target))
+	}
+	return nil
+}
+
+func runMigrate(ctx context.Context, provider *goose.Provider, action string, out io.Writer) error {
+	switch action {
+	case "up":
+		results, err := provider.Up(ctx)
+		for _, result := range results {
+			fmt.Fprintln(out, result)
+		}
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Fprintln(out, "no migrations to apply")
+		}
+		return nil
+
+	case "down":
+		result, err := provider.Down(ctx)
+		if errors.Is(err, goose.ErrNoNextVersion) {
+			fmt.Fprintln(out, "no migrations to roll back")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, result)
+		return nil
+
+	case "status":
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tMIGRATION")
+		for _, status := range statuses {
+			appliedAt := "-"
+			if status.State == goose.StateApplied {
+				appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, status.Source.Path)
+		}
+		return tw.Flush()
+
+	default:
+		return fmt.Errorf("unknown migrate action %q, expected up, down or status", action)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/auth"
+	"github.com/imeltsner/gator-api/internal/database"
+)
+
+const (
+	// oidcFlowCookie keeps a login in progress in the user's browser while they are
+	// at the identity provider
+	oidcFlowCookie = "gator_oidc"
+	oidcFlowExpiry = 10 * time.Minute
+	// oidcTimeout bounds each request to the identity provider
+	oidcTimeout = 10 * time.Second
+	// maxOIDCNameAttempts is how many numbered variants of a name are tried when
+	// creating a user whose preferred name is taken
+	maxOIDCNameAttempts = 10
+)
+
+func (s *state) handlerOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if s.oidc == nil {
+		respondWithError(w, http.StatusNotFound, "single sign-on is not configured", nil)
+		return
+	}
+
+	url, flow, err := s.oidc.Start(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "unable to reach identity provider", err)
+		return
+	}
+	token, err := s.keys.MakeOIDCFlowJWT(flow, oidcFlowExpiry)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to start login", err)
+		return
+	}
+
+	http.SetCookie(w, s.oidcFlowCookie(token, int(oidcFlowExpiry.Seconds())))
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+func (s *state) handlerOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if s.oidc == nil {
+		respondWithError(w, http.StatusNotFound, "single sign-on is not configured", nil)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "no login in progress, start at /api/oidc/login", err)
+		return
+	}
+	// The flow is only good for one attempt
+	http.SetCookie(w, s.oidcFlowCookie("", -1))
+
+	flow, err := s.keys.ValidateOIDCFlowJWT(cookie.Value)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "login expired, start again at /api/oidc/login", err)
+		return
+	}
+	query := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(flow.State)) != 1 {
+		respondWithError(w, http.StatusBadRequest, "state does not match the login in progress", nil)
+		return
+	}
+	if providerErr := query.Get("error"); providerErr != "" {
+		message := "identity provider refused login: " + providerErr
+		if description := query.Get("error_description"); description != "" {
+			message += ": " + description
+		}
+		respondWithError(w, http.StatusUnauthorized, message, nil)
+		return
+	}
+	if query.Get("code") == "" {
+		respondWithError(w, http.StatusBadRequest, "callback has no authorization code", nil)
+		return
+	}
+
+	claims, err := s.oidc.Finish(r.Context(), flow, query.Get("code"))
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unable to verify login with identity provider", err)
+		return
+	}
+
+	dbUser, err := s.oidcUser(r.Context(), claims)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get user", err)
+		return
+	}
+
+	token, err := s.keys.MakeJWT(dbUser.ID, time.Hour)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to make jwt", err)
+		return
+	}
+
+	type response struct {
+		User
+		Token string `json:"token"`
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		User: User{
+			ID:        dbUser.ID,
+			CreatedAt: dbUser.CreatedAt,
+			UpdatedAt: dbUser.UpdatedAt,
+			Name:      dbUser.Name,
+		},
+		Token: token,
+	})
+}
+
+func (s *state) oidcFlowCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    value,
+		Path:     "/api/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(s.oidc.RedirectURL(), "https://"),
+		// Lax so the cookie is sent when the provider redirects back
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// oidcUser returns the user linked to the provider's subject, creating and linking
+// a user on their first login
+func (s *state) oidcUser(ctx context.Context, claims auth.OIDCClaims) (database.User, error) {
+	identity, err := s.db.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Issuer:  s.oidc.Issuer(),
+		Subject: claims.Subject,
+	})
+	if err == nil {
+		return s.db.GetUserByID(ctx, identity.UserID)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("unable to get identity: %v", err)
+	}
+
+	user, err := s.createOIDCUser(ctx, oidcUserName(claims))
+	if err != nil {
+		return database.User{}, err
+	}
+	_, err = s.db.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UserID:    user.ID,
+		Issuer:    s.oidc.Issuer(),
+		Subject:   claims.Subject,
+	})
+	if err != nil {
+		if deleteErr := s.db.DeleteUser(ctx, user.ID); deleteErr != nil {
+			slog.ErrorContext(ctx, "unable to delete unlinked user", slog.String("user_id", user.ID.String()), slog.Any("error", deleteErr))
+		}
+		if database.IsUniqueViolation(err) {
+			// A concurrent first login linked the subject already
+			return s.oidcUser(ctx, claims)
+		}
+		return database.User{}, fmt.Errorf("unable to link identity: %v", err)
+	}
+
+	slog.InfoContext(ctx, "user created", slog.String("user_id", user.ID.String()), slog.String("name", user.Name), slog.String("issuer", s.oidc.Issuer()))
+	return user, nil
+}
+
+// createOIDCUser creates a user named name, or name-2, name-3 and so on when it is
+// taken. The user has no password until they reset it.
+func (s *state) createOIDCUser(ctx context.Context, name string) (database.User, error) {
+	for i := 1; i <= maxOIDCNameAttempts; i++ {
+		candidate := name
+		if i > 1 {
+			suffix := fmt.Sprintf("-%d", i)
+			candidate = truncateRunes(name, maxUserNameLength-len(suffix)) + suffix
+		}
+		user, err := s.db.CreateUser(ctx, database.CreateUserParams{
+			ID:             uuid.New(),
+			CreatedAt:      time.Now().UTC(),
+			UpdatedAt:      time.Now().UTC(),
+			Name:           candidate,
+			HashedPassword: unsetPassword,
+		})
+		if database.IsUniqueViolation(err) {
+			continue
+		} else if err != nil {
+			return database.User{}, fmt.Errorf("unable to create user: %v", err)
+		}
+		return user, nil
+	}
+	return database.User{}, fmt.Errorf("unable to create user: %q and %d variants are taken", name, maxOIDCNameAttempts-1)
+}
+
+// oidcUserName picks a name for a new user from their preferred user name, email
+// address or display name
+func oidcUserName(claims auth.OIDCClaims) string {
+	local, _, _ := strings.Cut(claims.Email, "@")
+	for _, name := range []string{claims.PreferredUsername, local, claims.Name} {
+		if name = strings.TrimSpace(name); name != "" {
+			return truncateRunes(name, maxUserNameLength)
+		}
+	}
+	return "user"
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
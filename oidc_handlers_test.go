@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"testing"
+
+	"github.com/imeltsner/gator-api/internal/auth"
+	"github.com/imeltsner/gator-api/internal/auth/oidctest"
+)
+
+// browser follows redirects and keeps cookies, like the user's browser would
+// through a login
+type browser struct {
+	t      *testing.T
+	client *http.Client
+}
+
+func newBrowser(t *testing.T) *browser {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &browser{t: t, client: &http.Client{Jar: jar}}
+}
+
+// get returns the status and JSON body of the page the redirects end up at
+func (b *browser) get(url string) (int, map[string]any) {
+	b.t.Helper()
+	res, err := b.client.Get(url)
+	if err != nil {
+		b.t.Fatalf("GET %v: %v", url, err)
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+	body := map[string]any{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		b.t.Fatalf("GET %v: response is not JSON: %s", url, data)
+	}
+	return res.StatusCode, body
+}
+
+func TestOIDCLogin(t *testing.T) {
+	s, api := newTestAPI(t)
+	api.do(http.MethodGet, "/api/oidc/login", nil, http.StatusNotFound)
+
+	provider := oidctest.New("gator", "client-secret")
+	t.Cleanup(provider.Close)
+	s.oidc = auth.NewOIDCProvider(auth.OIDCConfig{
+		Issuer:       provider.URL,
+		ClientID:     "gator",
+		ClientSecret: "client-secret",
+		RedirectURL:  api.baseURL + "/api/oidc/callback",
+	}, provider.Client())
+
+	// The provider denies access until it has a user
+	status, res := newBrowser(t).get(api.baseURL + "/api/oidc/login")
+	if status != http.StatusUnauthorized || !strings.Contains(res["error"].(map[string]any)["message"].(string), "access_denied") {
+		t.Errorf("denied login: got %d %v", status, res)
+	}
+
+	// The first login creates a user, with a free variant of the preferred name
+	api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusCreated)
+	provider.SetUser(oidctest.User{Subject: "sub-1", PreferredUsername: "alice", Email: "alice@example.com"})
+	status, first := newBrowser(t).get(api.baseURL + "/api/oidc/login")
+	if status != http.StatusOK {
+		t.Fatalf("first login: got %d %v", status, first)
+	}
+	requireKeys(t, "login", first, "id", "created_at", "updated_at", "name", "token")
+	if first["name"] != "alice-2" {
+		t.Errorf("created user name: got %v, want alice-2", first["name"])
+	}
+	api.token = first["token"].(string)
+	api.do(http.MethodGet, "/api/users/"+first["id"].(string), nil, http.StatusOK)
+
+	// Later logins map the subject to the same user, whatever it is called now
+	provider.SetUser(oidctest.User{Subject: "sub-1", PreferredUsername: "alice.smith"})
+	status, second := newBrowser(t).get(api.baseURL + "/api/oidc/login")
+	if status != http.StatusOK || second["id"] != first["id"] {
+		t.Errorf("second login: got %d %v, want user %v", status, second, first["id"])
+	}
+
+	provider.SetUser(oidctest.User{Subject: "sub-2", Email: "bob@example.com"})
+	_, bob := newBrowser(t).get(api.baseURL + "/api/oidc/login")
+	if bob["name"] != "bob" {
+		t.Errorf("name from email: got %v, want bob", bob["name"])
+	}
+
+	// SSO users have no password to log in with until they set one, which they
+	// do without a current password
+	api.token = ""
+	res = api.do(http.MethodPost, "/api/login", map[string]any{"name": "bob", "password": "anything"}, http.StatusForbidden)
+	requireErrorCode(t, res, codePasswordResetRequired)
+	api.token = bob["token"].(string)
+	path := "/api/users/" + bob["id"].(string) + "/password"
+	api.do(http.MethodPut, path, map[string]any{"new_password": "correct horse"}, http.StatusNoContent)
+	api.do(http.MethodPut, path, map[string]any{"new_password": "another horse"}, http.StatusBadRequest)
+	api.token = ""
+	api.do(http.MethodPost, "/api/login", map[string]any{"name": "bob", "password": "correct horse"}, http.StatusOK)
+}
+
+func TestOIDCCallbackRejects(t *testing.T) {
+	s, api := newTestAPI(t)
+	provider := oidctest.New("gator", "client-secret")
+	t.Cleanup(provider.Close)
+	provider.SetUser(oidctest.User{Subject: "sub-1", PreferredUsername: "alice"})
+	s.oidc = auth.NewOIDCProvider(auth.OIDCConfig{
+		Issuer:       provider.URL,
+		ClientID:     "gator",
+		ClientSecret: "client-secret",
+		RedirectURL:  api.baseURL + "/api/oidc/callback",
+	}, provider.Client())
+
+	// Stop at the callback to replay and tamper with it
+	b := newBrowser(t)
+	var callback string
+	b.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if req.URL.Path == "/api/oidc/callback" {
+			callback = req.URL.String()
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+	res, err := b.client.Get(api.baseURL + "/api/oidc/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if callback == "" {
+		t.Fatal("login did not redirect back to the callback")
+	}
+	b.client.CheckRedirect = nil
+
+	if status, _ := newBrowser(t).get(callback); status != http.StatusBadRequest {
+		t.Errorf("callback without the flow cookie: got %d, want 400", status)
+	}
+	if status, _ := b.get(strings.Replace(callback, "state=", "state=x", 1)); status != http.StatusBadRequest {
+		t.Errorf("callback with another state: got %d, want 400", status)
+	}
+	// The failed attempt used up the flow
+	if status, _ := b.get(callback); status != http.StatusBadRequest {
+		t.Errorf("callback after a failed attempt: got %d, want 400", status)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/imeltsner/gator-api/internal/auth"
+	"github.com/imeltsner/gator-api/internal/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordResetExpiry is how long a reset token issued by gator-api
+// password-reset stays valid
+const passwordResetExpiry = 24 * time.Hour
+
+var errPasswordResetRequired = &requestError{
+	status:  http.StatusForbidden,
+	code:    codePasswordResetRequired,
+	message: "the account has no password; set one after logging in with SSO, or ask an operator for a password reset token",
+}
+
+// rehashPassword upgrades the user's hash after a successful login when it was
+// made with a lower cost than the one configured. Failures are only logged since
+// the old hash still works. The password version is left alone so Reader tokens
+// keep working.
+func (s *state) rehashPassword(ctx context.Context, user database.User, password string) {
+	cost, err := bcrypt.Cost([]byte(user.HashedPassword))
+	if err != nil || cost >= s.bcryptCost {
+		return
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
+	if err == nil {
+		err = s.db.RehashUserPassword(ctx, database.RehashUserPasswordParams{
+			ID:             user.ID,
+			HashedPassword: string(hashedPassword),
+			UpdatedAt:      time.Now().UTC(),
+		})
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "unable to rehash password", slog.String("user_id", user.ID.String()), slog.Any("error", err))
+		return
+	}
+	slog.InfoContext(ctx, "password rehashed", slog.String("user_id", user.ID.String()), slog.Int("from_cost", cost), slog.Int("to_cost", s.bcryptCost))
+}
+
+func (s *state) setPassword(ctx context.Context, user database.User, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
+	if err != nil {
+		return err
+	}
+	return s.db.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:             user.ID,
+		HashedPassword: string(hashedPassword),
+		UpdatedAt:      time.Now().UTC(),
+	})
+}
+
+func (s *state) handlerChangePassword(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	authID, err := s.authenticate(r, "")
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	if authID != id {
+		respondWithError(w, http.StatusForbidden, "mismatched id", nil)
+		return
+	}
+
+	params := parameters{}
+	err = decodeJSON(w, r, &params)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	user, err := s.db.GetUserByID(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "user not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get user", err)
+		return
+	}
+
+	// Users created by an OpenID Connect login have no password, so they set
+	// their first one without a current password
+	hasPassword := user.HashedPassword != unsetPassword
+	v := validator{}
+	if hasPassword {
+		v.required("current_password", params.CurrentPassword)
+	}
+	v.password("new_password", params.NewPassword, user.Name)
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	if hasPassword {
+		// Guessing the current password here counts towards the login lockout
+		if wait := s.logins.locked(user.Name); wait > 0 {
+			respondWithError(w, http.StatusTooManyRequests, "too many failed logins, try again later", nil)
+			return
+		}
+		err = bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(params.CurrentPassword))
+		if err != nil {
+			s.logins.fail(user.Name)
+			respondWithError(w, http.StatusForbidden, "incorrect current password", err)
+			return
+		}
+		s.logins.succeed(user.Name)
+	}
+
+	err = s.setPassword(r.Context(), user, params.NewPassword)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to change password", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "password changed", slog.String("user_id", user.ID.String()))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerResetPassword sets a new password with a token issued by gator-api
+// password-reset. The token is tied to the password hash it was issued for, so
+// it can only be used once.
+func (s *state) handlerResetPassword(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+
+	params := parameters{}
+	err := decodeJSON(w, r, &params)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	v := validator{}
+	v.required("token", params.Token)
+	v.required("new_password", params.NewPassword)
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	id, fingerprint, err := s.keys.ValidatePasswordResetJWT(params.Token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired reset token", err)
+		return
+	}
+
+	user, err := s.db.GetUserByID(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired reset token", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get user", err)
+		return
+	}
+	if auth.PasswordFingerprint(user.HashedPassword) != fingerprint {
+		respondWithError(w, http.StatusUnauthorized, "reset token has already been used", nil)
+		return
+	}
+
+	v.password("new_password", params.NewPassword, user.Name)
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	err = s.setPassword(r.Context(), user, params.NewPassword)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to reset password", err)
+		return
+	}
+	s.logins.succeed(user.Name)
+
+	slog.InfoContext(r.Context(), "password reset", slog.String("user_id", user.ID.String()))
+	w.WriteHeader(http.StatusNoContent)
+}
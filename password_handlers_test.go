@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestChangePassword(t *testing.T) {
+	_, api := newTestAPI(t)
+
+	user := api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusCreated)
+	path := "/api/users/" + user["id"].(string) + "/password"
+	api.do(http.MethodPut, path, map[string]any{"current_password": "hunter22", "new_password": "correct horse"}, http.StatusUnauthorized)
+
+	login := api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusOK)
+	api.token = login["token"].(string)
+
+	api.do(http.MethodPut, "/api/users/"+uuid.NewString()+"/password", map[string]any{"current_password": "hunter22", "new_password": "correct horse"}, http.StatusForbidden)
+	api.do(http.MethodPut, path, map[string]any{"current_password": "wrong", "new_password": "correct horse"}, http.StatusForbidden)
+	res := api.do(http.MethodPut, path, map[string]any{"current_password": "hunter22", "new_password": "alice2024"}, http.StatusBadRequest)
+	details := requireErrorCode(t, res, codeValidation)["details"].([]any)
+	if field := details[0].(map[string]any)["field"]; field != "new_password" {
+		t.Errorf("policy violation field: got %v, want new_password", field)
+	}
+	api.do(http.MethodPut, path, map[string]any{"current_password": "hunter22", "new_password": "correct horse"}, http.StatusNoContent)
+
+	api.token = ""
+	api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusUnauthorized)
+	api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "correct horse"}, http.StatusOK)
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	s, api := newTestAPI(t)
+
+	api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusCreated)
+	token := readerLogin(t, api.baseURL, "alice", "hunter22")
+	s.bcryptCost = bcrypt.MinCost + 1
+	api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusOK)
+
+	user, err := s.db.GetUserByName(context.Background(), "alice")
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if cost, _ := bcrypt.Cost([]byte(user.HashedPassword)); cost != bcrypt.MinCost+1 {
+		t.Errorf("cost after login: got %d, want %d", cost, bcrypt.MinCost+1)
+	}
+	// Rehashing doesn't change the password, so Reader clients stay logged in
+	if status, body := reader(t, api.baseURL, token, http.MethodGet, "user-info", nil); status != http.StatusOK {
+		t.Errorf("reader token after a rehash: got %d %s, want 200", status, body)
+	}
+	api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusOK)
+}
+
+func TestPasswordReset(t *testing.T) {
+	s, api := newTestAPI(t)
+
+	user, err := s.db.CreateUser(context.Background(), database.CreateUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      time.Now().UTC(),
+		UpdatedAt:      time.Now().UTC(),
+		Name:           "legacy",
+		HashedPassword: unsetPassword,
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	res := api.do(http.MethodPost, "/api/login", map[string]any{"name": "legacy", "password": "unset"}, http.StatusForbidden)
+	requireErrorCode(t, res, codePasswordResetRequired)
+
+	token, err := s.keys.MakePasswordResetJWT(user.ID, user.HashedPassword, time.Hour)
+	if err != nil {
+		t.Fatalf("make reset token: %v", err)
+	}
+
+	// A reset token is not an access token
+	api.token = token
+	api.do(http.MethodGet, "/api/users/"+user.ID.String(), nil, http.StatusUnauthorized)
+	api.token = ""
+
+	api.do(http.MethodPost, "/api/password-reset", map[string]any{"token": "not-a-token", "new_password": "correct horse"}, http.StatusUnauthorized)
+	api.do(http.MethodPost, "/api/password-reset", map[string]any{"token": token, "new_password": "password"}, http.StatusBadRequest)
+	api.do(http.MethodPost, "/api/password-reset", map[string]any{"token": token, "new_password": "correct horse"}, http.StatusNoContent)
+	api.do(http.MethodPost, "/api/password-reset", map[string]any{"token": token, "new_password": "another horse"}, http.StatusUnauthorized)
+
+	api.do(http.MethodPost, "/api/login", map[string]any{"name": "legacy", "password": "correct horse"}, http.StatusOK)
+}
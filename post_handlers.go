@@ -1,13 +1,10 @@
 package main
 
 import (
-	"context"
-	"encoding/json"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/imeltsner/gator-api/internal/auth"
 	"github.com/imeltsner/gator-api/internal/database"
 )
 
@@ -23,37 +20,33 @@ type Post struct {
 }
 
 func (s *state) handlerBrowse(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Limit int `json:"limit"`
+	query := r.URL.Query()
+	v := validator{}
+	limit := v.queryInt(query, "limit", defaultPostsLimit)
+	v.between("limit", limit, 1, maxPostsLimit)
+	offset := v.queryInt(query, "offset", 0)
+	if offset < 0 {
+		v.add("offset", "must not be negative")
 	}
-
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err := decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to decode params", err)
-		return
-	}
-
-	authToken, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to parse auth header", err)
+	if err := v.queryErr(); err != nil {
+		respondWithRequestError(w, err)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(authToken, s.jwtSecret)
+	userID, err := s.authenticate(r, scopeReadPosts)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "unable to validate jwt", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
 	getPostParams := database.GetPostsForUserParams{
 		UserID: userID,
-		Limit:  int32(params.Limit),
+		Limit:  int32(limit),
+		Offset: int32(offset),
 	}
-	posts, err := s.db.GetPostsForUser(context.Background(), getPostParams)
+	posts, err := s.db.GetPostsForUser(r.Context(), getPostParams)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "unable to get post", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to get posts", err)
 		return
 	}
 
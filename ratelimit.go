@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imeltsner/gator-api/internal/auth"
+)
+
+// rateLimit allows requests at a steady rate of requests per window, with bursts of
+// up to requests. Clients are told apart by user ID when the request carries a
+// valid JWT, unless byIP is set.
+type rateLimit struct {
+	requests int
+	window   time.Duration
+	byIP     bool
+}
+
+// Routes that are expensive or a brute force target get tighter limits than the
+// configured default, which the remaining routes share
+var routeRateLimits = map[string]rateLimit{
+	"POST /api/login":            {requests: 10, window: time.Minute, byIP: true},
+	"GET /api/oidc/login":        {requests: 10, window: time.Minute, byIP: true},
+	"GET /api/oidc/callback":     {requests: 10, window: time.Minute, byIP: true},
+	"POST /api/users":            {requests: 5, window: time.Minute, byIP: true},
+	"POST /api/agg":              {requests: 6, window: time.Minute},
+	"POST /fever/{$}":            {requests: 120, window: time.Minute, byIP: true},
+	"POST /accounts/ClientLogin": {requests: 10, window: time.Minute, byIP: true},
+}
+
+// Probes and scrapes are never limited
+var unlimitedRoutes = map[string]bool{
+	"GET /healthz": true,
+	"GET /readyz":  true,
+	"GET /metrics": true,
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a set of token buckets sharing one limit, keyed by client
+type rateLimiter struct {
+	limit rateLimit
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(limit rateLimit) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		now:     time.Now,
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// perSecond is the rate tokens are refilled at
+func (l *rateLimiter) perSecond() float64 {
+	return float64(l.limit.requests) / l.limit.window.Seconds()
+}
+
+// allow takes a token from key's bucket. It returns the tokens left, the time
+// until the bucket is full again, and when denied, the time until a token is
+// available.
+func (l *rateLimiter) allow(key string) (ok bool, remaining int, reset, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	capacity := float64(l.limit.requests)
+	bucket, found := l.buckets[key]
+	if !found {
+		if len(l.buckets) >= 10000 {
+			l.prune(now)
+		}
+		bucket = &tokenBucket{tokens: capacity, last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = min(capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*l.perSecond())
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		ok = true
+	} else {
+		retryAfter = l.timeFor(1 - bucket.tokens)
+	}
+	return ok, int(bucket.tokens), l.timeFor(capacity - bucket.tokens), retryAfter
+}
+
+func (l *rateLimiter) timeFor(tokens float64) time.Duration {
+	return time.Duration(tokens / l.perSecond() * float64(time.Second))
+}
+
+// prune drops buckets that have refilled completely, since they are the same as
+// new ones
+func (l *rateLimiter) prune(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.perSecond() >= float64(l.limit.requests) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// rateLimiters holds a limiter for every route limit
+type rateLimiters struct {
+	trustProxy bool
+	fallback   *rateLimiter
+	routes     map[string]*rateLimiter
+}
+
+func newRateLimiters(fallback rateLimit, trustProxy bool) *rateLimiters {
+	limiters := &rateLimiters{
+		trustProxy: trustProxy,
+		fallback:   newRateLimiter(fallback),
+		routes:     map[string]*rateLimiter{},
+	}
+	for pattern, limit := range routeRateLimits {
+		limiters.routes[pattern] = newRateLimiter(limit)
+	}
+	return limiters
+}
+
+// middlewareRateLimit limits each client per route, by user ID when the request
+// carries a valid JWT and by IP otherwise, and reports the limit in
+// RateLimit-* headers
+func (s *state) middlewareRateLimit(mux *routeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.rateLimiters == nil {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		_, pattern := mux.Handler(r)
+		if pattern == "" || unlimitedRoutes[pattern] {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		limiter, ok := s.rateLimiters.routes[pattern]
+		if !ok {
+			limiter = s.rateLimiters.fallback
+		}
+		key := "ip:" + clientIP(r, s.rateLimiters.trustProxy)
+		if !limiter.limit.byIP {
+			// API keys would need a database lookup before the request is
+			// limited, so they are limited by IP like anonymous requests
+			token, err := auth.GetBearerToken(r.Header)
+			if err == nil && !auth.IsAPIKey(token) {
+				if c := s.requestCaller(r); c.err == nil {
+					key = "user:" + c.userID.String()
+				}
+			}
+		}
+
+		allowed, remaining, reset, retryAfter := limiter.allow(key)
+		header := w.Header()
+		header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limiter.limit.requests, int(limiter.limit.window.Seconds())))
+		header.Set("RateLimit-Limit", strconv.Itoa(limiter.limit.requests))
+		header.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(reset)))
+		if !allowed {
+			header.Set("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+			respondWithError(w, http.StatusTooManyRequests, "rate limit exceeded", nil)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// clientIP returns the address of the client, taken from the last X-Forwarded-For
+// entry when the server is behind a trusted proxy
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			parts := strings.Split(forwarded, ",")
+			if ip := strings.TrimSpace(parts[len(parts)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type loginFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// loginThrottle locks a user name out after maxFailures consecutive failed logins.
+// Each further failure doubles the lockout, up to maxLoginLockout, and failures
+// are forgotten after a day without any.
+type loginThrottle struct {
+	maxFailures int
+	lockout     time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+}
+
+const (
+	maxLoginLockout      = time.Hour
+	loginFailuresResetIn = 24 * time.Hour
+)
+
+func newLoginThrottle(maxFailures int, lockout time.Duration) *loginThrottle {
+	return &loginThrottle{
+		maxFailures: maxFailures,
+		lockout:     lockout,
+		now:         time.Now,
+		failures:    map[string]*loginFailures{},
+	}
+}
+
+// locked reports how long name is still locked out for. A nil throttle never locks.
+func (t *loginThrottle) locked(name string) time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f, ok := t.failures[name]
+	if !ok {
+		return 0
+	}
+	return max(f.lockedUntil.Sub(t.now()), 0)
+}
+
+func (t *loginThrottle) fail(name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	f, ok := t.failures[name]
+	if !ok || now.Sub(f.last) > loginFailuresResetIn {
+		if len(t.failures) >= 10000 {
+			t.prune(now)
+		}
+		f = &loginFailures{}
+		t.failures[name] = f
+	}
+	f.count++
+	f.last = now
+	if f.count >= t.maxFailures {
+		lockout := t.lockout << min(f.count-t.maxFailures, 16)
+		f.lockedUntil = now.Add(min(lockout, maxLoginLockout))
+	}
+}
+
+func (t *loginThrottle) succeed(name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.failures, name)
+}
+
+func (t *loginThrottle) prune(now time.Time) {
+	for key, f := range t.failures {
+		if now.Sub(f.last) > loginFailuresResetIn {
+			delete(t.failures, key)
+		}
+	}
+}
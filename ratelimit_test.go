@@ -0,0 +1,187 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	limiter := newRateLimiter(rateLimit{requests: 2, window: time.Minute})
+	limiter.now = clock.Now
+
+	for i := range 2 {
+		if ok, remaining, _, _ := limiter.allow("a"); !ok || remaining != 1-i {
+			t.Fatalf("request %d: got ok=%v remaining=%d", i, ok, remaining)
+		}
+	}
+	ok, _, reset, retryAfter := limiter.allow("a")
+	if ok {
+		t.Fatal("third request: got allowed")
+	}
+	if retryAfter != 30*time.Second || reset != time.Minute {
+		t.Errorf("denied request: got retry after %v and reset %v, want 30s and 1m", retryAfter, reset)
+	}
+	if ok, _, _, _ := limiter.allow("b"); !ok {
+		t.Error("other key: got denied")
+	}
+
+	clock.now = clock.now.Add(30 * time.Second)
+	if ok, _, _, _ := limiter.allow("a"); !ok {
+		t.Error("after refill: got denied")
+	}
+	if ok, _, _, _ := limiter.allow("a"); ok {
+		t.Error("refill is one token per 30s: got allowed")
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	limiter.prune(clock.now)
+	if len(limiter.buckets) != 0 {
+		t.Errorf("buckets after prune: got %d, want 0", len(limiter.buckets))
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	throttle := newLoginThrottle(3, time.Minute)
+	throttle.now = clock.Now
+
+	for range 2 {
+		throttle.fail("alice")
+	}
+	if wait := throttle.locked("alice"); wait != 0 {
+		t.Fatalf("after 2 failures: got locked for %v", wait)
+	}
+	throttle.fail("alice")
+	if wait := throttle.locked("alice"); wait != time.Minute {
+		t.Errorf("after 3 failures: got locked for %v, want 1m", wait)
+	}
+	throttle.fail("alice")
+	if wait := throttle.locked("alice"); wait != 2*time.Minute {
+		t.Errorf("after 4 failures: got locked for %v, want 2m", wait)
+	}
+	for range 10 {
+		throttle.fail("alice")
+	}
+	if wait := throttle.locked("alice"); wait != maxLoginLockout {
+		t.Errorf("after 14 failures: got locked for %v, want %v", wait, maxLoginLockout)
+	}
+	if wait := throttle.locked("bob"); wait != 0 {
+		t.Errorf("other user: got locked for %v", wait)
+	}
+
+	throttle.succeed("alice")
+	if wait := throttle.locked("alice"); wait != 0 {
+		t.Errorf("after success: got locked for %v", wait)
+	}
+
+	var nilThrottle *loginThrottle
+	nilThrottle.fail("alice")
+	if wait := nilThrottle.locked("alice"); wait != 0 {
+		t.Errorf("nil throttle: got locked for %v", wait)
+	}
+}
+
+func TestMiddlewareRateLimit(t *testing.T) {
+	s := newTestState()
+	s.rateLimiters = newRateLimiters(rateLimit{requests: 3, window: time.Minute}, true)
+	handler := s.routes()
+
+	send := func(method, path, body, ip, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Forwarded-For", "10.0.0.1, "+ip)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Login is limited per IP regardless of the name
+	for i := range routeRateLimits["POST /api/login"].requests {
+		w := send(http.MethodPost, "/api/login", `{"name":"nobody","password":"x"}`, "192.0.2.1", "")
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("login %d: got %d", i, w.Code)
+		}
+	}
+	w := send(http.MethodPost, "/api/login", `{"name":"nobody","password":"x"}`, "192.0.2.1", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("login over the limit: got %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "6" || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Limit") != "10" {
+		t.Errorf("429 headers: got %v", w.Header())
+	}
+	if !strings.Contains(w.Body.String(), `"code":"too_many_requests"`) {
+		t.Errorf("429 body: got %s", w.Body)
+	}
+	if w := send(http.MethodPost, "/api/login", `{"name":"nobody","password":"x"}`, "192.0.2.2", ""); w.Code != http.StatusNotFound {
+		t.Errorf("login from another IP: got %d", w.Code)
+	}
+
+	// Other routes share the default limit, keyed by user when authenticated
+	send(http.MethodPost, "/api/users", `{"name":"alice","password":"hunter22"}`, "192.0.2.3", "")
+	login := send(http.MethodPost, "/api/login", `{"name":"alice","password":"hunter22"}`, "192.0.2.3", "")
+	token := strings.Split(strings.Split(login.Body.String(), `"token":"`)[1], `"`)[0]
+	for i := range 3 {
+		if w := send(http.MethodGet, "/api/follows", "", "192.0.2.3", token); w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") == "" {
+			t.Fatalf("follows %d: got %d, headers %v", i, w.Code, w.Header())
+		}
+	}
+	if w := send(http.MethodGet, "/api/posts", "", "192.0.2.4", token); w.Code != http.StatusTooManyRequests {
+		t.Errorf("same user from another IP: got %d, want 429", w.Code)
+	}
+	if w := send(http.MethodGet, "/api/users", "", "192.0.2.3", ""); w.Code != http.StatusOK {
+		t.Errorf("anonymous request from the same IP: got %d, want 200", w.Code)
+	}
+
+	// Probes are never limited
+	for range 5 {
+		if w := send(http.MethodGet, "/healthz", "", "192.0.2.3", ""); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("healthz: got %d, headers %v", w.Code, w.Header())
+		}
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	s := newTestState()
+	s.logins = newLoginThrottle(2, time.Minute)
+	create := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(`{"name":"alice","password":"hunter22"}`))
+	s.handlerCreateUser(httptest.NewRecorder(), create)
+
+	login := func(password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"name":"alice","password":"`+password+`"}`))
+		w := httptest.NewRecorder()
+		s.handlerLogin(w, req)
+		return w
+	}
+
+	for range 2 {
+		if w := login("wrong"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("wrong password: got %d", w.Code)
+		}
+	}
+	w := login("hunter22")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("locked out: got %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	s.logins.now = func() time.Time { return time.Now().Add(time.Minute) }
+	if w := login("hunter22"); w.Code != http.StatusOK {
+		t.Errorf("after the lockout: got %d", w.Code)
+	}
+	if wait := s.logins.locked("alice"); wait != 0 {
+		t.Errorf("after a successful login: got locked for %v", wait)
+	}
+}
@@ -0,0 +1,762 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/database"
+)
+
+const (
+	// readerTokenExpiry is how long a ClientLogin token lasts. Clients keep it
+	// rather than the password, so it is long lived.
+	readerTokenExpiry  = 30 * 24 * time.Hour
+	readerDefaultItems = 20
+	readerMaxItems     = 1000
+)
+
+// Google Reader stream and item ids. Feeds are identified by their seq, and items
+// by their seq in either the long form below or as a decimal.
+const (
+	readerReadingList = "user/-/state/com.google/reading-list"
+	readerRead        = "user/-/state/com.google/read"
+	readerStarred     = "user/-/state/com.google/starred"
+	readerFeedPrefix  = "feed/"
+	readerLabelPrefix = "user/-/label/"
+	readerItemPrefix  = "tag:google.com,2005:reader/item/"
+)
+
+type ReaderSubscription struct {
+	ID         string   `json:"id"`
+	Title      string   `json:"title"`
+	Categories []string `json:"categories"`
+	Url        string   `json:"url"`
+	HtmlUrl    string   `json:"htmlUrl"`
+	IconUrl    string   `json:"iconUrl"`
+}
+
+type ReaderTag struct {
+	ID string `json:"id"`
+}
+
+type ReaderItemRef struct {
+	ID              string   `json:"id"`
+	DirectStreamIDs []string `json:"directStreamIds"`
+	TimestampUsec   string   `json:"timestampUsec"`
+}
+
+type ReaderLink struct {
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
+}
+
+type ReaderContent struct {
+	Direction string `json:"direction"`
+	Content   string `json:"content"`
+}
+
+type ReaderOrigin struct {
+	StreamID string `json:"streamId"`
+	Title    string `json:"title"`
+	HtmlUrl  string `json:"htmlUrl"`
+}
+
+type ReaderItem struct {
+	ID            string        `json:"id"`
+	CrawlTimeMsec string        `json:"crawlTimeMsec"`
+	TimestampUsec string        `json:"timestampUsec"`
+	Published     int64         `json:"published"`
+	Updated       int64         `json:"updated"`
+	Title         string        `json:"title"`
+	Canonical     []ReaderLink  `json:"canonical"`
+	Alternate     []ReaderLink  `json:"alternate"`
+	Summary       ReaderContent `json:"summary"`
+	Author        string        `json:"author"`
+	Categories    []string      `json:"categories"`
+	Origin        ReaderOrigin  `json:"origin"`
+}
+
+type ReaderStream struct {
+	ID           string       `json:"id"`
+	Updated      int64        `json:"updated"`
+	Items        []ReaderItem `json:"items"`
+	Continuation string       `json:"continuation,omitempty"`
+}
+
+// handlerReaderLogin is Google's ClientLogin: the form's Email and Passwd are the
+// user's name and account password, and the response is a token for the Reader API
+func (s *state) handlerReaderLogin(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+	err := r.ParseForm()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "unable to parse form", err)
+		return
+	}
+
+	name, password := r.Form.Get("Email"), r.Form.Get("Passwd")
+	v := validator{}
+	v.required("Email", name)
+	v.required("Passwd", password)
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	// ClientLogin doesn't tell unknown names from wrong passwords
+	user, err := s.checkLogin(r.Context(), name, password)
+	var reqErr *requestError
+	if errors.As(err, &reqErr) && (reqErr.status == http.StatusNotFound || reqErr.status == http.StatusUnauthorized) {
+		respondWithError(w, http.StatusUnauthorized, "incorrect user name or password", err)
+		return
+	} else if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	token, err := s.keys.MakeReaderJWT(user.ID, user.PasswordVersion, readerTokenExpiry)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to make jwt", err)
+		return
+	}
+
+	respondWithText(w, http.StatusOK, fmt.Sprintf("SID=%v\nLSID=null\nAuth=%v\n", token, token))
+}
+
+// readerRequest parses the form of a Reader API request and returns the user it
+// is from. Clients send the ClientLogin token as "Authorization: GoogleLogin
+// auth=<token>".
+func (s *state) readerRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+	err := r.ParseForm()
+	if err != nil {
+		return uuid.Nil, &requestError{status: http.StatusBadRequest, message: "unable to parse form", err: err}
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "GoogleLogin auth=")
+	if !ok || token == "" {
+		return uuid.Nil, &requestError{status: http.StatusUnauthorized, message: "unable to parse auth header"}
+	}
+	id, passwordVersion, err := s.keys.ValidateReaderJWT(token)
+	if err != nil {
+		return uuid.Nil, &requestError{status: http.StatusUnauthorized, message: "invalid or expired reader token", err: err}
+	}
+
+	user, err := s.db.GetUserByID(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, &requestError{status: http.StatusUnauthorized, message: "invalid or expired reader token", err: err}
+	} else if err != nil {
+		return uuid.Nil, &requestError{status: http.StatusInternalServerError, message: "unable to get user", err: err}
+	}
+	if user.PasswordVersion != passwordVersion {
+		return uuid.Nil, &requestError{status: http.StatusUnauthorized, message: "password has changed, log in again"}
+	}
+	return id, nil
+}
+
+// handlerReaderToken returns the token clients send back as T on edits. Edits
+// are already authenticated by the Authorization header, which browsers don't
+// send cross-site, so T is not checked.
+func (s *state) handlerReaderToken(w http.ResponseWriter, r *http.Request) {
+	_, err := s.readerRequest(w, r)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to make token", err)
+		return
+	}
+	respondWithText(w, http.StatusOK, hex.EncodeToString(b))
+}
+
+func (s *state) handlerReaderUserInfo(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.readerRequest(w, r)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	name, err := s.db.GetUserNameByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get user", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"userId":        userID.String(),
+		"userName":      name,
+		"userProfileId": userID.String(),
+		"userEmail":     "",
+	})
+}
+
+func (s *state) handlerReaderSubscriptions(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.readerRequest(w, r)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	feeds, err := s.db.GetFollowedFeeds(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get feeds", err)
+		return
+	}
+
+	type response struct {
+		Subscriptions []ReaderSubscription `json:"subscriptions"`
+	}
+	subscriptions := make([]ReaderSubscription, len(feeds))
+	for i, feed := range feeds {
+		subscriptions[i] = ReaderSubscription{
+			ID:         readerFeedID(feed.Seq),
+			Title:      feed.Title,
+			Categories: []string{},
+			Url:        feed.Url,
+			HtmlUrl:    siteURL(feed.Url),
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, response{Subscriptions: subscriptions})
+}
+
+// handlerReaderEditSubscription subscribes to or unsubscribes from the feeds in
+// s. Subscribing to a URL gator doesn't have yet adds the feed. Renaming and
+// labelling feeds are accepted but do nothing, since feed titles are shared and
+// gator has no folders.
+func (s *state) handlerReaderEditSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.readerRequest(w, r)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	streams := r.Form["s"]
+	if len(streams) == 0 {
+		v := validator{}
+		v.add("s", "is required")
+		respondWithRequestError(w, v.err())
+		return
+	}
+
+	switch ac := r.Form.Get("ac"); ac {
+	case "subscribe":
+		for _, stream := range streams {
+			_, err := s.readerSubscribe(r.Context(), userID, strings.TrimPrefix(stream, readerFeedPrefix), r.Form.Get("t"))
+			if err != nil {
+				respondWithRequestError(w, err)
+				return
+			}
+		}
+	case "unsubscribe":
+		feeds, err := s.readerFeeds(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "unable to get feeds", err)
+			return
+		}
+		for _, stream := range streams {
+			seq, _ := readerFeedSeq(stream)
+			feed, ok := feeds[seq]
+			if !ok {
+				respondWithError(w, http.StatusNotFound, "feed not followed: "+stream, nil)
+				return
+			}
+			err = s.db.DeleteFeedFollow(r.Context(), database.DeleteFeedFollowParams{UserID: userID, FeedID: feed.ID})
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "unable to unfollow", err)
+				return
+			}
+		}
+	case "edit":
+	default:
+		v := validator{}
+		v.add("ac", "must be subscribe, unsubscribe or edit")
+		respondWithRequestError(w, v.err())
+		return
+	}
+
+	respondWithText(w, http.StatusOK, "OK")
+}
+
+func (s *state) handlerReaderQuickAdd(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.readerRequest(w, r)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	query := r.Form.Get("quickadd")
+	feed, err := s.readerSubscribe(r.Context(), userID, strings.TrimPrefix(query, readerFeedPrefix), "")
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	type response struct {
+		NumResults int    `json:"numResults"`
+		Query      string `json:"query"`
+		StreamID   string `json:"streamId"`
+		StreamName string `json:"streamName"`
+	}
+	respondWithJSON(w, http.StatusOK, response{
+		NumResults: 1,
+		Query:      query,
+		StreamID:   readerFeedID(feed.Seq),
+		StreamName: feed.Title,
+	})
+}
+
+// readerSubscribe follows the feed at feedURL, adding it under title, or the
+// URL's host without one, if gator doesn't have it yet
+func (s *state) readerSubscribe(ctx context.Context, userID uuid.UUID, feedURL, title string) (database.Feed, error) {
+	v := validator{}
+	v.feedURL("s", feedURL)
+	if err := v.err(); err != nil {
+		return database.Feed{}, err
+	}
+
+	feed, err := s.db.GetFeedByURL(ctx, feedURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		if title == "" {
+			u, _ := url.Parse(feedURL)
+			title = u.Host
+		}
+		feed, err = s.db.CreateFeed(ctx, database.CreateFeedParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			UpdatedAt: time.Now().UTC(),
+			Title:     title,
+			Url:       feedURL,
+			UserID:    userID,
+		})
+		if database.IsUniqueViolation(err) {
+			// Someone else added it first
+			feed, err = s.db.GetFeedByURL(ctx, feedURL)
+		} else if err == nil {
+			slog.InfoContext(ctx, "feed created", slog.String("feed_id", feed.ID.String()), slog.String("title", feed.Title), slog.String("url", feed.Url))
+		}
+	}
+	if err != nil {
+		return database.Feed{}, &requestError{status: http.StatusInternalServerError, message: "unable to get feed", err: err}
+	}
+
+	_, err = s.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		UserID:    userID,
+		FeedID:    feed.ID,
+	})
+	if err != nil && !database.IsUniqueViolation(err) {
+		return database.Feed{}, &requestError{status: http.StatusInternalServerError, message: "unable to create feed follow entry", err: err}
+	}
+	return feed, nil
+}
+
+func (s *state) handlerReaderTags(w http.ResponseWriter, r *http.Request) {
+	_, err := s.readerRequest(w, r)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	type response struct {
+		Tags []ReaderTag `json:"tags"`
+	}
+	respondWithJSON(w, http.StatusOK, response{Tags: []ReaderTag{{ID: readerStarred}}})
+}
+
+func (s *state) handlerReaderItemIDs(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.readerRequest(w, r)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	rows, continuation, err := s.readerItems(r, userID, r.Form.Get("s"))
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	type response struct {
+		ItemRefs     []ReaderItemRef `json:"itemRefs"`
+		Continuation string          `json:"continuation,omitempty"`
+	}
+	refs := make([]ReaderItemRef, len(rows))
+	for i, row := range rows {
+		refs[i] = ReaderItemRef{
+			ID:              strconv.FormatInt(row.Seq, 10),
+			DirectStreamIDs: []string{},
+			TimestampUsec:   strconv.FormatInt(row.CreatedAt.UnixMicro(), 10),
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, response{ItemRefs: refs, Continuation: continuation})
+}
+
+// handlerReaderStreamContents returns the items of the stream named in the path,
+// or by s
+func (s *state) handlerReaderStreamContents(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.readerRequest(w, r)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	stream := r.PathValue("stream")
+	if stream == "" {
+		stream = r.Form.Get("s")
+	}
+	rows, continuation, err := s.readerItems(r, userID, stream)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+	feeds, err := s.readerFeeds(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get feeds", err)
+		return
+	}
+
+	items := make([]ReaderItem, len(rows))
+	for i, row := range rows {
+		items[i] = newReaderItem(row, feeds[row.FeedSeq])
+	}
+
+	respondWithJSON(w, http.StatusOK, ReaderStream{
+		ID:           stream,
+		Updated:      time.Now().Unix(),
+		Items:        items,
+		Continuation: continuation,
+	})
+}
+
+// handlerReaderItemContents returns the items listed in i, skipping any that
+// aren't in a followed feed
+func (s *state) handlerReaderItemContents(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.readerRequest(w, r)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	v := validator{}
+	seqs := readerItemSeqs(&v, r)
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+	feeds, err := s.readerFeeds(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get feeds", err)
+		return
+	}
+
+	items := []ReaderItem{}
+	for _, seq := range seqs {
+		item, err := s.db.GetUserItem(r.Context(), database.GetUserItemParams{UserID: userID, Seq: seq})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		} else if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "unable to get items", err)
+			return
+		}
+		items = append(items, newReaderItem(database.GetUserItemsRow(item), feeds[item.FeedSeq]))
+	}
+
+	respondWithJSON(w, http.StatusOK, ReaderStream{
+		ID:      readerReadingList,
+		Updated: time.Now().Unix(),
+		Items:   items,
+	})
+}
+
+// handlerReaderEditTag adds the tags in a to and removes the tags in r from the
+// items in i. Only the read and starred states are kept; other tags, such as
+// labels or kept-unread, are ignored.
+func (s *state) handlerReaderEditTag(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.readerRequest(w, r)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	v := validator{}
+	seqs := readerItemSeqs(&v, r)
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	edit := func(tag string, add bool) error {
+		at := sql.NullTime{Time: now, Valid: add}
+		for _, seq := range seqs {
+			var err error
+			switch readerStreamID(tag) {
+			case readerRead:
+				_, err = s.db.SetItemRead(r.Context(), database.SetItemReadParams{ReadAt: at, UserID: userID, Seq: seq})
+			case readerStarred:
+				_, err = s.db.SetItemSaved(r.Context(), database.SetItemSavedParams{SavedAt: at, UserID: userID, Seq: seq})
+			default:
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, tag := range r.Form["a"] {
+		if err := edit(tag, true); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "unable to tag items", err)
+			return
+		}
+	}
+	for _, tag := range r.Form["r"] {
+		if err := edit(tag, false); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "unable to untag items", err)
+			return
+		}
+	}
+
+	respondWithText(w, http.StatusOK, "OK")
+}
+
+// handlerReaderMarkAllRead marks the items of the reading list or a feed read,
+// up to ts in microseconds if it is given
+func (s *state) handlerReaderMarkAllRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.readerRequest(w, r)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	v := validator{}
+	params := database.MarkItemsReadParams{
+		ReadAt:        time.Now().UTC(),
+		UserID:        userID,
+		CreatedBefore: time.Now().UTC(),
+	}
+	if r.Form.Has("ts") {
+		params.CreatedBefore = time.UnixMicro(formInt(&v, r, "ts")).UTC()
+	}
+	switch stream := readerStreamID(r.Form.Get("s")); {
+	case stream == readerReadingList:
+	case strings.HasPrefix(stream, readerFeedPrefix):
+		seq, ok := readerFeedSeq(stream)
+		if !ok {
+			respondWithText(w, http.StatusOK, "OK")
+			return
+		}
+		params.FeedSeq = seq
+	case strings.HasPrefix(stream, readerLabelPrefix):
+		// Labels have no items
+		respondWithText(w, http.StatusOK, "OK")
+		return
+	default:
+		v.add("s", "must be the reading list, a feed or a label")
+	}
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	err = s.db.MarkItemsRead(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to mark items read", err)
+		return
+	}
+
+	respondWithText(w, http.StatusOK, "OK")
+}
+
+// readerItems returns a page of the stream's items, filtered by the request's xt,
+// it, ot and nt, newest first unless r=o, along with the continuation for the
+// next page if there is one
+func (s *state) readerItems(r *http.Request, userID uuid.UUID, stream string) ([]database.GetUserItemsRow, string, error) {
+	v := validator{}
+	params := database.GetUserItemsParams{UserID: userID, Descending: r.Form.Get("r") != "o"}
+
+	switch stream = readerStreamID(stream); {
+	case stream == readerReadingList:
+	case stream == readerStarred:
+		params.Saved = true
+	case strings.HasPrefix(stream, readerFeedPrefix):
+		seq, ok := readerFeedSeq(stream)
+		if !ok {
+			return nil, "", nil
+		}
+		params.FeedSeq = seq
+	case strings.HasPrefix(stream, readerLabelPrefix):
+		// gator has no folders, so labels have no items
+		return nil, "", nil
+	default:
+		v.add("s", "must be the reading list, starred items, a feed or a label")
+	}
+	for _, tag := range r.Form["xt"] {
+		if readerStreamID(tag) != readerRead {
+			v.add("xt", "can only exclude read items")
+		}
+		params.Unread = true
+	}
+	for _, tag := range r.Form["it"] {
+		if readerStreamID(tag) != readerStarred {
+			v.add("it", "can only include starred items")
+		}
+		params.Saved = true
+	}
+	if r.Form.Has("ot") {
+		params.CreatedAfter = sql.NullTime{Time: time.Unix(formInt(&v, r, "ot"), 0).UTC(), Valid: true}
+	}
+	if r.Form.Has("nt") {
+		params.CreatedBefore = sql.NullTime{Time: time.Unix(formInt(&v, r, "nt"), 0).UTC(), Valid: true}
+	}
+	if r.Form.Has("c") {
+		if params.Descending {
+			params.BeforeSeq = formInt(&v, r, "c")
+		} else {
+			params.AfterSeq = formInt(&v, r, "c")
+		}
+	}
+	// Clients ask for more than any server returns, e.g. n=10000, so n is capped
+	// rather than rejected
+	limit := int64(readerDefaultItems)
+	if r.Form.Has("n") {
+		limit = min(max(formInt(&v, r, "n"), 1), readerMaxItems)
+	}
+	if err := v.err(); err != nil {
+		return nil, "", err
+	}
+
+	// One more than the page tells whether there is another
+	params.Limit = int32(limit + 1)
+	rows, err := s.db.GetUserItems(r.Context(), params)
+	if err != nil {
+		return nil, "", &requestError{status: http.StatusInternalServerError, message: "unable to get items", err: err}
+	}
+	if int64(len(rows)) <= limit {
+		return rows, "", nil
+	}
+	rows = rows[:limit]
+	return rows, strconv.FormatInt(rows[limit-1].Seq, 10), nil
+}
+
+// readerFeeds returns the user's followed feeds by seq
+func (s *state) readerFeeds(ctx context.Context, userID uuid.UUID) (map[int64]database.Feed, error) {
+	feeds, err := s.db.GetFollowedFeeds(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	bySeq := make(map[int64]database.Feed, len(feeds))
+	for _, feed := range feeds {
+		bySeq[feed.Seq] = feed
+	}
+	return bySeq, nil
+}
+
+// readerItemSeqs parses the item ids in i, recording an error in v when there are
+// none, too many or they are malformed
+func readerItemSeqs(v *validator, r *http.Request) []int64 {
+	ids := r.Form["i"]
+	if len(ids) == 0 {
+		v.add("i", "is required")
+		return nil
+	}
+	if len(ids) > readerMaxItems {
+		v.add("i", fmt.Sprintf("must list at most %d items", readerMaxItems))
+		return nil
+	}
+	seqs := make([]int64, len(ids))
+	for i, id := range ids {
+		seq, err := readerItemSeq(id)
+		if err != nil {
+			v.add("i", "must be item ids")
+			return nil
+		}
+		seqs[i] = seq
+	}
+	return seqs
+}
+
+// readerItemSeq parses an item id in the long form, tag:google.com,2005:reader/item/
+// followed by 16 hex digits, or the short decimal form
+func readerItemSeq(id string) (int64, error) {
+	if digits, ok := strings.CutPrefix(id, readerItemPrefix); ok {
+		seq, err := strconv.ParseUint(digits, 16, 64)
+		return int64(seq), err
+	}
+	return strconv.ParseInt(id, 10, 64)
+}
+
+func readerItemID(seq int64) string {
+	return fmt.Sprintf("%v%016x", readerItemPrefix, seq)
+}
+
+func readerFeedID(seq int64) string {
+	return readerFeedPrefix + strconv.FormatInt(seq, 10)
+}
+
+// readerFeedSeq returns the seq of a feed/<seq> stream. Streams with the feed's
+// URL in place of the seq, as some clients send for feeds they don't know yet,
+// aren't followed feeds.
+func readerFeedSeq(stream string) (int64, bool) {
+	seq, err := strconv.ParseInt(strings.TrimPrefix(stream, readerFeedPrefix), 10, 64)
+	return seq, err == nil && seq > 0
+}
+
+// readerStreamID replaces the user ID in state and label streams with "-", the
+// form gator's own stream ids take
+func readerStreamID(id string) string {
+	if rest, ok := strings.CutPrefix(id, "user/"); ok {
+		if _, tail, ok := strings.Cut(rest, "/"); ok {
+			return "user/-/" + tail
+		}
+	}
+	return id
+}
+
+func newReaderItem(row database.GetUserItemsRow, feed database.Feed) ReaderItem {
+	published := row.CreatedAt
+	if row.PublishedAt.Valid {
+		published = row.PublishedAt.Time
+	}
+	categories := []string{readerReadingList}
+	if row.ReadAt.Valid {
+		categories = append(categories, readerRead)
+	}
+	if row.SavedAt.Valid {
+		categories = append(categories, readerStarred)
+	}
+	return ReaderItem{
+		ID:            readerItemID(row.Seq),
+		CrawlTimeMsec: strconv.FormatInt(row.CreatedAt.UnixMilli(), 10),
+		TimestampUsec: strconv.FormatInt(row.CreatedAt.UnixMicro(), 10),
+		Published:     published.Unix(),
+		Updated:       row.UpdatedAt.Unix(),
+		Title:         row.Title,
+		Canonical:     []ReaderLink{{Href: row.Url}},
+		Alternate:     []ReaderLink{{Href: row.Url, Type: "text/html"}},
+		Summary:       ReaderContent{Direction: "ltr", Content: row.Description.String},
+		Categories:    categories,
+		Origin: ReaderOrigin{
+			StreamID: readerFeedID(row.FeedSeq),
+			Title:    feed.Title,
+			HtmlUrl:  siteURL(feed.Url),
+		},
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// readerLogin logs name in with ClientLogin and returns the Auth token
+func readerLogin(t *testing.T, baseURL, name, password string) string {
+	t.Helper()
+	res, err := http.PostForm(baseURL+"/accounts/ClientLogin", url.Values{"Email": {name}, "Passwd": {password}})
+	if err != nil {
+		t.Fatalf("ClientLogin: %v", err)
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("ClientLogin: got status %d (body %s)", res.StatusCode, data)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if token, ok := strings.CutPrefix(line, "Auth="); ok {
+			return token
+		}
+	}
+	t.Fatalf("ClientLogin: no Auth line in %q", data)
+	return ""
+}
+
+// reader calls the Reader API with token, sending form as the query of a GET or
+// the body of a POST, and returns the status and body
+func reader(t *testing.T, baseURL, token, method, path string, form url.Values) (int, []byte) {
+	t.Helper()
+	target := baseURL + "/reader/api/0/" + path
+	var body io.Reader
+	if method == http.MethodGet {
+		target += "?" + form.Encode()
+	} else {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if token != "" {
+		req.Header.Set("Authorization", "GoogleLogin auth="+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%v %v: %v", method, path, err)
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+	return res.StatusCode, data
+}
+
+// readerJSON calls the Reader API and decodes its JSON response, failing unless
+// it is a 200
+func readerJSON(t *testing.T, baseURL, token, method, path string, form url.Values) map[string]any {
+	t.Helper()
+	status, data := reader(t, baseURL, token, method, path, form)
+	if status != http.StatusOK {
+		t.Fatalf("%v %v: got status %d (body %s)", method, path, status, data)
+	}
+	body := map[string]any{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("%v %v: response is not JSON: %s", method, path, data)
+	}
+	return body
+}
+
+func readerItemRefs(t *testing.T, baseURL, token string, query url.Values) ([]string, string) {
+	t.Helper()
+	res := readerJSON(t, baseURL, token, http.MethodGet, "stream/items/ids", query)
+	var ids []string
+	for _, ref := range res["itemRefs"].([]any) {
+		r := ref.(map[string]any)
+		requireKeys(t, "itemRef", r, "id", "directStreamIds", "timestampUsec")
+		ids = append(ids, r["id"].(string))
+	}
+	continuation, _ := res["continuation"].(string)
+	return ids, continuation
+}
+
+func TestReader(t *testing.T) {
+	api := &apiClient{t: t, baseURL: newTestServer(t).URL}
+	feeds := newFeedServer(t)
+
+	user := api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusCreated)
+	login := api.do(http.MethodPost, "/api/login", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusOK)
+	api.token = login["token"].(string)
+	api.do(http.MethodPost, "/api/feeds", map[string]any{"title": "RSS", "url": feeds.URL + "/rss.xml"}, http.StatusCreated)
+	api.do(http.MethodPost, "/api/feeds", map[string]any{"title": "Atom", "url": feeds.URL + "/atom.xml"}, http.StatusCreated)
+	api.do(http.MethodPost, "/api/agg", nil, http.StatusOK)
+	api.do(http.MethodPost, "/api/agg", nil, http.StatusOK)
+
+	res, err := http.PostForm(api.baseURL+"/accounts/ClientLogin", url.Values{"Email": {"alice"}, "Passwd": {"wrong-password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ClientLogin with the wrong password: got %d, want 401", res.StatusCode)
+	}
+	token := readerLogin(t, api.baseURL, "alice", "hunter22")
+	if status, _ := reader(t, api.baseURL, api.token, http.MethodGet, "subscription/list", nil); status != http.StatusUnauthorized {
+		t.Errorf("access token on the Reader API: got %d, want 401", status)
+	}
+
+	info := readerJSON(t, api.baseURL, token, http.MethodGet, "user-info", nil)
+	if info["userName"] != "alice" || info["userId"] != user["id"] {
+		t.Errorf("user-info: got %v", info)
+	}
+
+	subscriptions := readerJSON(t, api.baseURL, token, http.MethodGet, "subscription/list", url.Values{"output": {"json"}})["subscriptions"].([]any)
+	if len(subscriptions) != 2 {
+		t.Fatalf("subscriptions: got %d, want 2", len(subscriptions))
+	}
+	rss := subscriptions[0].(map[string]any)
+	requireKeys(t, "subscription", rss, "id", "title", "categories", "url", "htmlUrl", "iconUrl")
+	atom := subscriptions[1].(map[string]any)
+	if rss["title"] != "RSS" || rss["htmlUrl"] != feeds.URL || !strings.HasPrefix(rss["id"].(string), "feed/") {
+		t.Errorf("subscription: got %v", rss)
+	}
+
+	// Pages of item ids, newest first unless r=o
+	all := url.Values{"s": {readerReadingList}, "n": {"2"}}
+	first, c := readerItemRefs(t, api.baseURL, token, all)
+	all.Set("c", c)
+	second, c := readerItemRefs(t, api.baseURL, token, all)
+	all.Set("c", c)
+	third, c := readerItemRefs(t, api.baseURL, token, all)
+	ids := append(append(first, second...), third...)
+	if len(ids) != 5 || c != "" {
+		t.Fatalf("item ids: got %v, continuation %q, want 5 ids and no continuation", ids, c)
+	}
+	oldest, _ := readerItemRefs(t, api.baseURL, token, url.Values{"s": {readerReadingList}, "r": {"o"}, "n": {"1"}})
+	if len(oldest) != 1 || oldest[0] != ids[4] {
+		t.Errorf("oldest item: got %v, want %v", oldest, ids[4])
+	}
+	if feedIDs, _ := readerItemRefs(t, api.baseURL, token, url.Values{"s": {atom["id"].(string)}}); len(feedIDs) != 2 {
+		t.Errorf("atom feed items: got %v, want 2", feedIDs)
+	}
+
+	// Tag one item read by its short id and star another by its long id
+	status, body := reader(t, api.baseURL, token, http.MethodPost, "edit-tag", url.Values{"i": {ids[0]}, "a": {readerRead}, "T": {"token"}})
+	if status != http.StatusOK || string(body) != "OK" {
+		t.Fatalf("edit-tag: got %d %s", status, body)
+	}
+	starredID := readerJSON(t, api.baseURL, token, http.MethodPost, "stream/items/contents", url.Values{"i": {ids[1]}})["items"].([]any)[0].(map[string]any)["id"].(string)
+	if !strings.HasPrefix(starredID, readerItemPrefix) {
+		t.Errorf("long item id: got %v", starredID)
+	}
+	reader(t, api.baseURL, token, http.MethodPost, "edit-tag", url.Values{"i": {starredID}, "a": {"user/1234/state/com.google/starred", "user/-/label/Tech"}})
+
+	if unread, _ := readerItemRefs(t, api.baseURL, token, url.Values{"s": {readerReadingList}, "xt": {readerRead}}); len(unread) != 4 {
+		t.Errorf("unread items: got %v, want 4", unread)
+	}
+	starred := readerJSON(t, api.baseURL, token, http.MethodGet, "stream/contents/"+readerStarred, nil)
+	items := starred["items"].([]any)
+	if len(items) != 1 || starred["id"] != readerStarred {
+		t.Fatalf("starred stream: got %v", starred)
+	}
+	item := items[0].(map[string]any)
+	requireKeys(t, "item", item, "id", "crawlTimeMsec", "timestampUsec", "published", "updated", "title", "canonical", "alternate", "summary", "author", "categories", "origin")
+	if item["id"] != starredID || !strings.Contains(strings.Join(toStrings(item["categories"]), " "), readerStarred) {
+		t.Errorf("starred item: got %v", item)
+	}
+
+	reader(t, api.baseURL, token, http.MethodPost, "edit-tag", url.Values{"i": {ids[0]}, "r": {readerRead}})
+	if unread, _ := readerItemRefs(t, api.baseURL, token, url.Values{"s": {readerReadingList}, "xt": {readerRead}}); len(unread) != 5 {
+		t.Errorf("unread items after marking unread: got %v, want 5", unread)
+	}
+
+	// Marking a feed read leaves the other feed's items unread
+	reader(t, api.baseURL, token, http.MethodPost, "mark-all-as-read", url.Values{"s": {rss["id"].(string)}})
+	if unread, _ := readerItemRefs(t, api.baseURL, token, url.Values{"s": {readerReadingList}, "xt": {readerRead}}); len(unread) != 2 {
+		t.Errorf("unread items after marking the RSS feed read: got %v, want 2", unread)
+	}
+
+	// Unsubscribing and subscribing again by URL
+	form := url.Values{"ac": {"unsubscribe"}, "s": {atom["id"].(string)}}
+	if status, body := reader(t, api.baseURL, token, http.MethodPost, "subscription/edit", form); status != http.StatusOK {
+		t.Fatalf("unsubscribe: got %d %s", status, body)
+	}
+	if subscriptions := readerJSON(t, api.baseURL, token, http.MethodGet, "subscription/list", nil)["subscriptions"].([]any); len(subscriptions) != 1 {
+		t.Errorf("subscriptions after unsubscribing: got %d, want 1", len(subscriptions))
+	}
+	form = url.Values{"ac": {"subscribe"}, "s": {"feed/" + atom["url"].(string)}}
+	if status, body := reader(t, api.baseURL, token, http.MethodPost, "subscription/edit", form); status != http.StatusOK {
+		t.Fatalf("subscribe: got %d %s", status, body)
+	}
+	added := readerJSON(t, api.baseURL, token, http.MethodPost, "subscription/quickadd?quickadd="+url.QueryEscape(feeds.URL+"/broken.xml"), nil)
+	if added["numResults"] != float64(1) || !strings.HasPrefix(added["streamId"].(string), "feed/") {
+		t.Errorf("quickadd: got %v", added)
+	}
+	subscriptions = readerJSON(t, api.baseURL, token, http.MethodGet, "subscription/list", nil)["subscriptions"].([]any)
+	if len(subscriptions) != 3 || subscriptions[1].(map[string]any)["id"] != atom["id"] {
+		t.Errorf("subscriptions after subscribing: got %v", subscriptions)
+	}
+
+	// Changing the password logs Reader clients out
+	api.do(http.MethodPut, "/api/users/"+user["id"].(string)+"/password", map[string]any{"current_password": "hunter22", "new_password": "hunter23"}, http.StatusNoContent)
+	if status, _ := reader(t, api.baseURL, token, http.MethodGet, "subscription/list", nil); status != http.StatusUnauthorized {
+		t.Errorf("token after a password change: got %d, want 401", status)
+	}
+}
+
+func TestReaderRejects(t *testing.T) {
+	_, api := newTestAPI(t)
+	api.do(http.MethodPost, "/api/users", map[string]any{"name": "alice", "password": "hunter22"}, http.StatusCreated)
+	token := readerLogin(t, api.baseURL, "alice", "hunter22")
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		form   url.Values
+		want   int
+	}{
+		{"no token", "", http.MethodGet, "subscription/list", nil, http.StatusUnauthorized},
+		{"bad token", "not-a-token", http.MethodGet, "subscription/list", nil, http.StatusUnauthorized},
+		{"unknown stream", token, http.MethodGet, "stream/items/ids", url.Values{"s": {"user/-/state/com.google/broadcast"}}, http.StatusBadRequest},
+		{"unsupported exclude", token, http.MethodGet, "stream/items/ids", url.Values{"s": {readerReadingList}, "xt": {readerStarred}}, http.StatusBadRequest},
+		{"malformed count", token, http.MethodGet, "stream/items/ids", url.Values{"s": {readerReadingList}, "n": {"many"}}, http.StatusBadRequest},
+		{"edit-tag without items", token, http.MethodPost, "edit-tag", url.Values{"a": {readerRead}}, http.StatusBadRequest},
+		{"malformed item id", token, http.MethodPost, "edit-tag", url.Values{"i": {"item-1"}, "a": {readerRead}}, http.StatusBadRequest},
+		{"unknown action", token, http.MethodPost, "subscription/edit", url.Values{"ac": {"follow"}, "s": {"feed/1"}}, http.StatusBadRequest},
+		{"subscribe to a non-URL", token, http.MethodPost, "subscription/edit", url.Values{"ac": {"subscribe"}, "s": {"feed/example"}}, http.StatusBadRequest},
+		{"unsubscribe from an unfollowed feed", token, http.MethodPost, "subscription/edit", url.Values{"ac": {"unsubscribe"}, "s": {"feed/1"}}, http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if status, body := reader(t, api.baseURL, tc.token, tc.method, tc.path, tc.form); status != tc.want {
+				t.Errorf("got status %d, want %d (body %s)", status, tc.want, body)
+			}
+		})
+	}
+}
+
+func toStrings(v any) []string {
+	var s []string
+	for _, e := range v.([]any) {
+		s = append(s, e.(string))
+	}
+	return s
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/database"
+)
+
+const defaultRecommendationsLimit = 10
+
+// Recommendation is a feed the user doesn't follow that people who follow the
+// same feeds do. Score adds up how similar the feed is to each feed the user
+// follows; the explanation names the most similar one.
+type Recommendation struct {
+	Feed            Feed      `json:"feed"`
+	Score           float64   `json:"score"`
+	Explanation     string    `json:"explanation"`
+	BecauseFeedID   uuid.UUID `json:"because_feed_id"`
+	CommonFollowers int64     `json:"common_followers"`
+}
+
+func (s *state) handlerGetFeedRecommendations(w http.ResponseWriter, r *http.Request) {
+	v := validator{}
+	limit := v.queryInt(r.URL.Query(), "limit", defaultRecommendationsLimit)
+	v.between("limit", limit, 1, maxFeedsLimit)
+	if err := v.queryErr(); err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	userID, err := s.authenticate(r, scopeManageFollows)
+	if err != nil {
+		respondWithRequestError(w, err)
+		return
+	}
+
+	rows, err := s.db.ListFeedRecommendations(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get recommendations", err)
+		return
+	}
+
+	// A feed is recommended once for each followed feed it's similar to, most
+	// similar first
+	recommendations := []Recommendation{}
+	index := map[uuid.UUID]int{}
+	for _, row := range rows {
+		if i, ok := index[row.ID]; ok {
+			recommendations[i].Score += row.Score
+			continue
+		}
+		people := "people who follow"
+		if row.CommonFollowers == 1 {
+			people = "person who follows"
+		}
+		index[row.ID] = len(recommendations)
+		recommendations = append(recommendations, Recommendation{
+			Feed: newFeed(database.Feed{
+				ID:                    row.ID,
+				CreatedAt:             row.CreatedAt,
+				UpdatedAt:             row.UpdatedAt,
+				Title:                 row.Title,
+				Url:                   row.Url,
+				UserID:                row.UserID,
+				LastFetchedAt:         row.LastFetchedAt,
+				Seq:                   row.Seq,
+				LastSuccessfulFetchAt: row.LastSuccessfulFetchAt,
+			}),
+			Score:           row.Score,
+			Explanation:     fmt.Sprintf("followed by %d %v %v", row.CommonFollowers, people, row.BecauseFeedTitle),
+			BecauseFeedID:   row.BecauseFeedID,
+			CommonFollowers: row.CommonFollowers,
+		})
+	}
+	slices.SortStableFunc(recommendations, func(a, b Recommendation) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+
+	type response struct {
+		Recommendations []Recommendation `json:"recommendations"`
+	}
+	respondWithJSON(w, http.StatusOK, response{Recommendations: recommendations})
+}
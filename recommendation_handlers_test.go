@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestHandlerGetFeedRecommendations(t *testing.T) {
+	s, api := newTestAPI(t)
+	urls := map[string]string{
+		"Go Blog":   "https://go.dev/blog/feed.atom",
+		"Rust Blog": "https://blog.rust-lang.org/feed.xml",
+		"Changelog": "https://changelog.com/feed",
+	}
+	tokens := map[string]string{}
+	for _, user := range []struct {
+		name    string
+		adds    []string
+		follows []string
+	}{
+		{"alice", []string{"Go Blog", "Rust Blog"}, nil},
+		{"bob", []string{"Changelog"}, []string{"Go Blog", "Rust Blog"}},
+		{"carol", nil, []string{"Go Blog", "Changelog"}},
+		{"dave", nil, []string{"Go Blog"}},
+		{"erin", nil, []string{"Go Blog", "Rust Blog"}},
+	} {
+		api.token = ""
+		api.do(http.MethodPost, "/api/users", map[string]any{"name": user.name, "password": "hunter22"}, http.StatusCreated)
+		api.token = api.do(http.MethodPost, "/api/login", map[string]any{"name": user.name, "password": "hunter22"}, http.StatusOK)["token"].(string)
+		tokens[user.name] = api.token
+		for _, title := range user.adds {
+			api.do(http.MethodPost, "/api/feeds", map[string]any{"title": title, "url": urls[title]}, http.StatusCreated)
+		}
+		for _, title := range user.follows {
+			api.do(http.MethodPost, "/api/follows", map[string]any{"url": urls[title]}, http.StatusCreated)
+		}
+	}
+
+	// Nothing is recommended until the job has run
+	api.token = tokens["dave"]
+	res := api.do(http.MethodGet, "/api/recommendations/feeds", nil, http.StatusOK)
+	if got := res["recommendations"].([]any); len(got) != 0 {
+		t.Errorf("before refresh: got %v, want none", got)
+	}
+	if err := s.refreshRecommendations(context.Background()); err != nil {
+		t.Fatalf("refreshRecommendations: %v", err)
+	}
+
+	// Go Blog's five followers share three with Rust Blog's three and two with
+	// Changelog's two
+	res = api.do(http.MethodGet, "/api/recommendations/feeds", nil, http.StatusOK)
+	got := res["recommendations"].([]any)
+	if len(got) != 2 {
+		t.Fatalf("dave: got %v, want Rust Blog and Changelog", got)
+	}
+	first := got[0].(map[string]any)
+	if first["feed"].(map[string]any)["title"] != "Rust Blog" || first["score"] != 0.6 || first["common_followers"] != 3.0 {
+		t.Errorf("dave's first recommendation: got %v, want Rust Blog scored 0.6", first)
+	}
+	if want := "followed by 3 people who follow Go Blog"; first["explanation"] != want {
+		t.Errorf("explanation: got %q, want %q", first["explanation"], want)
+	}
+	res = api.do(http.MethodGet, "/api/recommendations/feeds?limit=1", nil, http.StatusOK)
+	if got := res["recommendations"].([]any); len(got) != 1 {
+		t.Errorf("limit=1: got %v, want 1 recommendation", got)
+	}
+
+	// Similarity to every followed feed adds up, and followed feeds are left out
+	api.token = tokens["carol"]
+	res = api.do(http.MethodGet, "/api/recommendations/feeds", nil, http.StatusOK)
+	got = res["recommendations"].([]any)
+	if len(got) != 1 {
+		t.Fatalf("carol: got %v, want only Rust Blog", got)
+	}
+	if score := got[0].(map[string]any)["score"].(float64); math.Abs(score-0.85) > 1e-9 {
+		t.Errorf("carol's score for Rust Blog: got %v, want 0.6 + 0.25", score)
+	}
+
+	res = api.do(http.MethodGet, "/api/recommendations/feeds?limit=0", nil, http.StatusBadRequest)
+	requireErrorCode(t, res, "validation_failed")
+	api.token = ""
+	api.do(http.MethodGet, "/api/recommendations/feeds", nil, http.StatusUnauthorized)
+}
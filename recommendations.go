@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/imeltsner/gator-api/internal/database"
+)
+
+// maxSimilarFeeds is how many of its most similar feeds are kept for each feed
+const maxSimilarFeeds = 20
+
+// refreshRecommendations scores how similar every pair of feeds is by how many
+// followers they share and replaces the previous scores. Recommendations switch
+// to the new scores as soon as they are saved.
+func (s *state) refreshRecommendations(ctx context.Context) error {
+	// Postgres keeps microseconds, so the new scores must not sort before computedAt
+	computedAt := time.Now().UTC().Truncate(time.Microsecond)
+	count, err := s.db.CreateFeedSimilarities(ctx, database.CreateFeedSimilaritiesParams{
+		ComputedAt: computedAt,
+		PerFeed:    maxSimilarFeeds,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to compute feed similarities: %v", err)
+	}
+
+	err = s.db.DeleteFeedSimilaritiesBefore(ctx, computedAt)
+	if err != nil {
+		return fmt.Errorf("unable to delete old feed similarities: %v", err)
+	}
+	slog.InfoContext(ctx, "refreshed feed recommendations", slog.Int64("similarities", count))
+	return nil
+}
+
+// runRecommender refreshes recommendations right away and then every interval
+// until ctx is cancelled
+func (s *state) runRecommender(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := s.refreshRecommendations(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("unable to refresh recommendations", slog.Any("error", err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxRequestBodyBytes = 1 << 20
+	// matches the VARCHAR(50) name column
+	maxUserNameLength = 50
+	maxPostsLimit     = 100
+	defaultPostsLimit = 10
+	maxFeedsLimit     = 100
+	defaultFeedsLimit = 20
+	maxFeedTags       = 10
+	maxFeedTagLength  = 30
+	minPasswordLength = 8
+	// bcrypt ignores anything after the first 72 bytes
+	maxPasswordBytes = 72
+)
+
+// Machine readable error codes that aren't derived from the status code
+const (
+	codeInvalidJSON  = "invalid_json"
+	codeValidation   = "validation_failed"
+	codeBodyTooLarge = "body_too_large"
+	// the account has no usable password and must be reset by an operator
+	codePasswordResetRequired = "password_reset_required"
+)
+
+// Passwords that are rejected outright however long they are
+var commonPasswords = map[string]bool{
+	"password":    true,
+	"password1":   true,
+	"password123": true,
+	"12345678":    true,
+	"123456789":   true,
+	"1234567890":  true,
+	"qwertyuiop":  true,
+	"qwerty123":   true,
+	"iloveyou":    true,
+	"sunshine":    true,
+	"football":    true,
+	"baseball":    true,
+	"princess":    true,
+	"superman":    true,
+	"trustno1":    true,
+	"letmein1":    true,
+	"welcome1":    true,
+	"abc12345":    true,
+	"11111111":    true,
+	"00000000":    true,
+}
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// requestError is a client error that is reported to the caller as is
+type requestError struct {
+	status  int
+	code    string
+	message string
+	details []fieldError
+	err     error
+	// retryAfter is sent in a Retry-After header when set
+	retryAfter time.Duration
+}
+
+func (e *requestError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("%v: %v", e.message, e.err)
+	}
+	return e.message
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// decodeJSON decodes a single JSON object from the request body into dst. The body
+// is limited to maxRequestBodyBytes and fields dst doesn't declare are rejected.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err == nil {
+		if decoder.More() {
+			return &requestError{status: http.StatusBadRequest, code: codeInvalidJSON, message: "request body must contain a single JSON object"}
+		}
+		if _, err := decoder.Token(); err != io.EOF {
+			return &requestError{status: http.StatusBadRequest, code: codeInvalidJSON, message: "request body must contain a single JSON object"}
+		}
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, io.EOF):
+		return &requestError{status: http.StatusBadRequest, code: codeInvalidJSON, message: "request body must not be empty"}
+	case errors.As(err, &maxBytesErr):
+		return &requestError{
+			status:  http.StatusRequestEntityTooLarge,
+			code:    codeBodyTooLarge,
+			message: fmt.Sprintf("request body must not be larger than %d bytes", maxBytesErr.Limit),
+		}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return &requestError{status: http.StatusBadRequest, code: codeInvalidJSON, message: "request body is not valid JSON", err: err}
+	case errors.As(err, &typeErr):
+		return &requestError{
+			status:  http.StatusBadRequest,
+			code:    codeValidation,
+			message: "request body is invalid",
+			details: []fieldError{{Field: typeErr.Field, Message: fmt.Sprintf("must be a %v", jsonTypeName(typeErr.Type.Kind()))}},
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &requestError{
+			status:  http.StatusBadRequest,
+			code:    codeValidation,
+			message: "request body is invalid",
+			details: []fieldError{{Field: field, Message: "unknown field"}},
+		}
+	default:
+		return &requestError{status: http.StatusBadRequest, code: codeInvalidJSON, message: "unable to decode request body", err: err}
+	}
+}
+
+func jsonTypeName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return kind.String()
+}
+
+// pathID parses the named path value as a UUID
+func pathID(r *http.Request, name string) (uuid.UUID, error) {
+	id, err := uuid.Parse(r.PathValue(name))
+	if err != nil {
+		return uuid.Nil, &requestError{
+			status:  http.StatusBadRequest,
+			code:    codeValidation,
+			message: "invalid path parameter",
+			details: []fieldError{{Field: name, Message: "must be a UUID"}},
+			err:     err,
+		}
+	}
+	return id, nil
+}
+
+// validator collects field errors so a request reports all of them at once
+type validator struct {
+	errs []fieldError
+}
+
+func (v *validator) add(field, msg string) {
+	v.errs = append(v.errs, fieldError{Field: field, Message: msg})
+}
+
+func (v *validator) required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required")
+		return false
+	}
+	return true
+}
+
+func (v *validator) maxLength(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		v.add(field, fmt.Sprintf("must be at most %d characters", max))
+	}
+}
+
+func (v *validator) userName(field, value string) {
+	if v.required(field, value) {
+		v.maxLength(field, value, maxUserNameLength)
+	}
+}
+
+// password enforces the password policy. userName may be empty when it isn't known.
+func (v *validator) password(field, value, userName string) {
+	if !v.required(field, value) {
+		return
+	}
+	switch {
+	case utf8.RuneCountInString(value) < minPasswordLength:
+		v.add(field, fmt.Sprintf("must be at least %d characters", minPasswordLength))
+	case len(value) > maxPasswordBytes:
+		v.add(field, fmt.Sprintf("must be at most %d bytes", maxPasswordBytes))
+	case commonPasswords[strings.ToLower(value)]:
+		v.add(field, "is too common")
+	case userName != "" && strings.Contains(strings.ToLower(value), strings.ToLower(userName)):
+		v.add(field, "must not contain the user name")
+	}
+}
+
+// feedURL accepts absolute http and https URLs
+func (v *validator) feedURL(field, value string) {
+	if !v.required(field, value) {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		v.add(field, "must be an absolute URL")
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		v.add(field, "must use the http or https scheme")
+	}
+}
+
+// feedTag returns value lowercased and trimmed, which must then be made of letters,
+// digits and hyphens
+func (v *validator) feedTag(field, value string) string {
+	tag := strings.ToLower(strings.TrimSpace(value))
+	switch {
+	case tag == "":
+		v.add(field, "tags must not be empty")
+	case utf8.RuneCountInString(tag) > maxFeedTagLength:
+		v.add(field, fmt.Sprintf("tags must be at most %d characters", maxFeedTagLength))
+	case strings.IndexFunc(tag, func(r rune) bool { return r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) }) >= 0:
+		v.add(field, "tags must only contain letters, digits and hyphens")
+	}
+	return tag
+}
+
+func (v *validator) between(field string, value, min, max int) {
+	if value < min || value > max {
+		v.add(field, fmt.Sprintf("must be between %d and %d", min, max))
+	}
+}
+
+// queryInt parses the named query parameter, returning def when it's absent
+func (v *validator) queryInt(query url.Values, field string, def int) int {
+	if !query.Has(field) {
+		return def
+	}
+	n, err := strconv.Atoi(query.Get(field))
+	if err != nil {
+		v.add(field, "must be an integer")
+		return def
+	}
+	return n
+}
+
+func (v *validator) oneOf(field, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		v.add(field, "must be one of "+strings.Join(allowed, ", "))
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &requestError{
+		status:  http.StatusBadRequest,
+		code:    codeValidation,
+		message: "request body is invalid",
+		details: v.errs,
+	}
+}
+
+// queryErr is err for requests whose parameters come from the query string
+func (v *validator) queryErr() error {
+	err := v.err()
+	if reqErr, ok := err.(*requestError); ok {
+		reqErr.message = "query parameters are invalid"
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type parameters struct {
+		Name  string `json:"name"`
+		Limit int    `json:"limit"`
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+		wantFields []string
+	}{
+		{"valid", `{"name":"alice","limit":3}`, 0, "", nil},
+		{"empty body", ``, http.StatusBadRequest, codeInvalidJSON, nil},
+		{"malformed", `{"name":`, http.StatusBadRequest, codeInvalidJSON, nil},
+		{"syntax error", `{"name" "alice"}`, http.StatusBadRequest, codeInvalidJSON, nil},
+		{"trailing data", `{"name":"alice"} {}`, http.StatusBadRequest, codeInvalidJSON, nil},
+		{"unknown field", `{"name":"alice","admin":true}`, http.StatusBadRequest, codeValidation, []string{"admin"}},
+		{"wrong type", `{"limit":"ten"}`, http.StatusBadRequest, codeValidation, []string{"limit"}},
+		{"too large", `{"name":"` + strings.Repeat("a", maxRequestBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, codeBodyTooLarge, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			params := parameters{}
+			err := decodeJSON(w, req, &params)
+			if tc.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("decodeJSON: %v", err)
+				}
+				return
+			}
+
+			reqErr, ok := err.(*requestError)
+			if !ok {
+				t.Fatalf("decodeJSON: got %v, want *requestError", err)
+			}
+			if reqErr.status != tc.wantStatus || reqErr.code != tc.wantCode {
+				t.Errorf("decodeJSON: got %d %q, want %d %q", reqErr.status, reqErr.code, tc.wantStatus, tc.wantCode)
+			}
+			var fields []string
+			for _, detail := range reqErr.details {
+				fields = append(fields, detail.Field)
+			}
+			if !slices.Equal(fields, tc.wantFields) {
+				t.Errorf("detail fields: got %q, want %q", fields, tc.wantFields)
+			}
+		})
+	}
+}
+
+func TestValidator(t *testing.T) {
+	v := validator{}
+	v.userName("name", strings.Repeat("é", maxUserNameLength))
+	v.feedURL("url", "https://example.com/rss")
+	v.between("limit", 10, 1, maxPostsLimit)
+	v.password("password", "correct horse battery", "alice")
+	if err := v.err(); err != nil {
+		t.Fatalf("valid fields: got %v", err)
+	}
+
+	v = validator{}
+	v.userName("name", strings.Repeat("a", maxUserNameLength+1))
+	v.required("title", "  ")
+	v.feedURL("url", "ftp://example.com/rss")
+	v.feedURL("link", "/relative")
+	v.between("limit", 0, 1, maxPostsLimit)
+	v.password("short", "hunter2", "alice")
+	v.password("long", strings.Repeat("é", 37), "alice")
+	v.password("common", "Password123", "alice")
+	v.password("contains_name", "xxALICExx", "alice")
+	want := []string{"name", "title", "url", "link", "limit", "short", "long", "common", "contains_name"}
+	var got []string
+	for _, detail := range v.errs {
+		got = append(got, detail.Field)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("invalid fields: got %q, want %q", got, want)
+	}
+}
+
+func TestRespondWithRequestError(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set(requestIDHeader, "req-1")
+	v := validator{}
+	v.required("name", "")
+	respondWithRequestError(w, v.err())
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status: got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	var res errorResponse
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := errorResponse{
+		Error:     errorBody{Code: codeValidation, Message: "request body is invalid", Details: []fieldError{{Field: "name", Message: "is required"}}},
+		RequestID: "req-1",
+	}
+	if res.Error.Code != want.Error.Code || res.Error.Message != want.Error.Message || res.RequestID != want.RequestID || !slices.Equal(res.Error.Details, want.Error.Details) {
+		t.Errorf("body: got %+v, want %+v", res, want)
+	}
+
+	w = httptest.NewRecorder()
+	respondWithError(w, http.StatusNotFound, "feed not found", nil)
+	res = errorResponse{}
+	json.NewDecoder(w.Body).Decode(&res)
+	if res.Error.Code != "not_found" || res.Error.Message != "feed not found" {
+		t.Errorf("body: got %+v, want not_found code", res)
+	}
+}
@@ -2,15 +2,18 @@ package main
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	data, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marhshalling JSON: %s", err)
+		slog.Error("unable to marshal JSON", slog.Any("error", err))
 		w.WriteHeader(500)
 		return
 	}
@@ -18,17 +21,75 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Write(data)
 }
 
+type errorBody struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []fieldError `json:"details,omitempty"`
+}
+
+type errorResponse struct {
+	Error     errorBody `json:"error"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// respondWithError responds with msg and a code derived from the status, e.g.
+// not_found for a 404
 func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
+	writeError(w, code, errorBody{Code: statusCode(code), Message: msg}, err)
+}
+
+// respondWithRequestError reports a requestError to the caller, and anything else
+// as a bad request
+func respondWithRequestError(w http.ResponseWriter, err error) {
+	var reqErr *requestError
+	if !errors.As(err, &reqErr) {
+		respondWithError(w, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+	code := reqErr.code
+	if code == "" {
+		code = statusCode(reqErr.status)
+	}
+	if reqErr.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(reqErr.retryAfter)))
+	}
+	writeError(w, reqErr.status, errorBody{Code: code, Message: reqErr.message, Details: reqErr.details}, err)
+}
+
+func writeError(w http.ResponseWriter, code int, body errorBody, err error) {
+	requestID := w.Header().Get(requestIDHeader)
+	attrs := []any{
+		slog.Int("status", code),
+		slog.String("code", body.Code),
+		slog.String("reason", body.Message),
+		slog.String("request_id", requestID),
+	}
 	if err != nil {
-		log.Println(err)
+		attrs = append(attrs, slog.Any("error", err))
 	}
 	if code > 499 {
-		log.Printf("Responding with 5XX error: %s", msg)
-	}
-	type errorResponse struct {
-		Error string `json:"error"`
+		slog.Error("responding with 5XX error", attrs...)
+	} else {
+		slog.Debug("responding with error", attrs...)
 	}
+
 	respondWithJSON(w, code, errorResponse{
-		Error: msg,
+		Error:     body,
+		RequestID: requestID,
 	})
 }
+
+// statusCode turns a status into a snake case error code, e.g. 404 into not_found
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+func respondWithText(w http.ResponseWriter, code int, text string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write([]byte(text))
+}
@@ -7,8 +7,8 @@ import (
 	"fmt"
 	"html"
 	"io"
+	"log/slog"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -31,6 +31,10 @@ type RSSItem struct {
 	PubDate     string `xml:"pubDate"`
 }
 
+// maxFeedBytes caps how much of a feed is read, so a huge or endless response
+// can't exhaust memory
+const maxFeedBytes = 10 << 20
+
 func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
 	if err != nil {
@@ -44,22 +48,110 @@ func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 		return nil, fmt.Errorf("unable to get response: %v", err)
 	}
 	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %v", res.Status)
+	}
 
-	content, err := io.ReadAll(res.Body)
+	content, err := io.ReadAll(io.LimitReader(res.Body, maxFeedBytes+1))
 	if err != nil {
 		return nil, fmt.Errorf("unable to read response body: %v", err)
 	}
+	if len(content) > maxFeedBytes {
+		return nil, fmt.Errorf("feed is larger than %d bytes", maxFeedBytes)
+	}
 
-	rssFeed := RSSFeed{}
-	err = xml.Unmarshal(content, &rssFeed)
+	var root struct {
+		XMLName xml.Name
+	}
+	err = xml.Unmarshal(content, &root)
 	if err != nil {
 		return nil, fmt.Errorf("unable to unmarshal xml %v", err)
 	}
+
+	rssFeed := RSSFeed{}
+	if root.XMLName.Local == "feed" {
+		atom := atomFeed{}
+		err = xml.Unmarshal(content, &atom)
+		if err != nil {
+			return nil, fmt.Errorf("unable to unmarshal atom xml %v", err)
+		}
+		rssFeed = atom.toRSS()
+	} else {
+		err = xml.Unmarshal(content, &rssFeed)
+		if err != nil {
+			return nil, fmt.Errorf("unable to unmarshal xml %v", err)
+		}
+	}
 	rssFeed.unescape()
 
 	return &rssFeed, nil
 }
 
+type atomFeed struct {
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Summary   string     `xml:"summary"`
+	Content   string     `xml:"content"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+}
+
+// alternateLink returns the link a reader would open, preferring rel="alternate"
+func alternateLink(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
+
+// toRSS maps an Atom feed onto the RSS structure the rest of the aggregator uses
+func (feed atomFeed) toRSS() RSSFeed {
+	rssFeed := RSSFeed{}
+	rssFeed.Channel.Title = feed.Title
+	rssFeed.Channel.Link = alternateLink(feed.Links)
+	rssFeed.Channel.Description = feed.Subtitle
+
+	for _, entry := range feed.Entries {
+		description := entry.Summary
+		if description == "" {
+			description = entry.Content
+		}
+		published := entry.Published
+		if published == "" {
+			published = entry.Updated
+		}
+		pubDate := ""
+		if t, err := time.Parse(time.RFC3339, published); err == nil {
+			pubDate = t.Format(time.RFC1123Z)
+		}
+
+		rssFeed.Channel.Item = append(rssFeed.Channel.Item, RSSItem{
+			Title:       entry.Title,
+			Link:        alternateLink(entry.Links),
+			Description: description,
+			PubDate:     pubDate,
+		})
+	}
+	return rssFeed
+}
+
 func (feed *RSSFeed) unescape() {
 	feed.Channel.Title = html.UnescapeString(feed.Channel.Title)
 	feed.Channel.Description = html.UnescapeString(feed.Channel.Description)
@@ -71,50 +163,86 @@ func (feed *RSSFeed) unescape() {
 	}
 }
 
-func (s *state) scrapeFeeds() error {
-	nextFeed, err := s.db.GetNextFeedToFetch(context.Background())
+func (s *state) scrapeFeeds(ctx context.Context) error {
+	nextFeed, err := s.db.GetNextFeedToFetch(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to get next feed: %v", err)
 	}
 
+	err = s.markFeedFetched(ctx, nextFeed)
+	if err != nil {
+		return err
+	}
+
+	return s.scrapeFeed(ctx, nextFeed)
+}
+
+func (s *state) markFeedFetched(ctx context.Context, feed database.Feed) error {
 	feedFetchedParams := database.MarkFeedFetchedParams{
 		LastFetchedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
 		UpdatedAt:     time.Now().UTC(),
-		ID:            nextFeed.ID,
+		ID:            feed.ID,
 	}
-	err = s.db.MarkFeedFetched(context.Background(), feedFetchedParams)
+	err := s.db.MarkFeedFetched(ctx, feedFetchedParams)
 	if err != nil {
 		return fmt.Errorf("unable to mark feed fetched: %v", err)
 	}
+	return nil
+}
 
-	rssFeed, err := fetchFeed(context.Background(), nextFeed.Url)
+func (s *state) scrapeFeed(ctx context.Context, dbFeed database.Feed) error {
+	start := time.Now()
+	fetchCtx, cancel := context.WithTimeout(ctx, s.fetchTimeout)
+	defer cancel()
+	rssFeed, err := fetchFeed(fetchCtx, dbFeed.Url)
 	if err != nil {
-		return fmt.Errorf("unable to fetch feed from %v: %v", nextFeed.Url, err)
+		observeFeedFetch("error", time.Since(start))
+		return fmt.Errorf("unable to fetch feed from %v: %v", dbFeed.Url, err)
 	}
-	fmt.Printf("Feed at url %v fetched successfully\n", rssFeed.Channel.Link)
+	observeFeedFetch("success", time.Since(start))
+	slog.InfoContext(ctx, "feed fetched", slog.String("url", dbFeed.Url), slog.Int("items", len(rssFeed.Channel.Item)))
 
-	err = s.saveFeed(*rssFeed, nextFeed)
+	err = s.db.MarkFeedFetchSucceeded(ctx, database.MarkFeedFetchSucceededParams{
+		LastSuccessfulFetchAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		ID:                    dbFeed.ID,
+	})
 	if err != nil {
-		return err
+		return fmt.Errorf("unable to mark feed fetch succeeded: %v", err)
 	}
-	return nil
+
+	return s.saveFeed(ctx, *rssFeed, dbFeed)
 }
 
-func (s *state) saveFeed(feed RSSFeed, dbFeed database.Feed) error {
+func (s *state) saveFeed(ctx context.Context, feed RSSFeed, dbFeed database.Feed) error {
+	created := 0
+	defer func() {
+		slog.InfoContext(ctx, "feed saved", slog.String("feed_id", dbFeed.ID.String()), slog.Int("posts_created", created))
+		if created > 0 {
+			s.newPosts.notify()
+		}
+	}()
+
 	for _, item := range feed.Channel.Item {
 		postParams := generatePostParams(item, dbFeed)
-		post, err := s.db.CreatePost(context.Background(), postParams)
-		if err != nil && strings.Contains(err.Error(), "duplicate key value") {
-			return nil
+		post, err := s.db.CreatePost(ctx, postParams)
+		if database.IsUniqueViolation(err) {
+			postsSaved.WithLabelValues("duplicate").Inc()
+			continue
 		} else if err != nil {
 			return fmt.Errorf("unable to create post %v: %v", item.Title, err)
 		}
-		fmt.Printf("Successfully created post %v\n", post.Title)
+		slog.DebugContext(ctx, "post created", slog.String("post_id", post.ID.String()), slog.String("title", post.Title))
+		postsSaved.WithLabelValues("inserted").Inc()
+		created++
 	}
 
 	return nil
 }
 
+// pubDateLayouts are the RFC 822 date forms feeds use. Dates in none of them are
+// left unset rather than guessed.
+var pubDateLayouts = []string{time.RFC1123Z, time.RFC1123, time.RFC822Z}
+
 func generatePostParams(item RSSItem, feed database.Feed) database.CreatePostParams {
 	var description sql.NullString
 	if item.Description == "" {
@@ -124,14 +252,11 @@ func generatePostParams(item RSSItem, feed database.Feed) database.CreatePostPar
 	}
 
 	var pubDate sql.NullTime
-	if item.PubDate != "" {
-		parsedPubdate, err := time.Parse(time.RFC1123Z, item.PubDate)
-		if err != nil {
-			pubDate = sql.NullTime{}
+	for _, layout := range pubDateLayouts {
+		if parsedPubdate, err := time.Parse(layout, item.PubDate); err == nil {
+			pubDate = sql.NullTime{Time: parsedPubdate, Valid: true}
+			break
 		}
-		pubDate = sql.NullTime{Time: parsedPubdate, Valid: true}
-	} else {
-		pubDate = sql.NullTime{}
 	}
 
 	return database.CreatePostParams{
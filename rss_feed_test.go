@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imeltsner/gator-api/internal/database"
+	"github.com/imeltsner/gator-api/internal/database/memory"
+)
+
+// newTestFeed adds a feed followed by a new user and returns both
+func newTestFeed(t *testing.T, s *state) (database.User, database.Feed) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	user, err := s.db.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Name: "alice", HashedPassword: "hash"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	feed, err := s.db.CreateFeed(ctx, database.CreateFeedParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Title: "Blog", Url: "https://blog.example.com/rss", UserID: user.ID})
+	if err != nil {
+		t.Fatalf("CreateFeed: %v", err)
+	}
+	_, err = s.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, UserID: user.ID, FeedID: feed.ID})
+	if err != nil {
+		t.Fatalf("CreateFeedFollow: %v", err)
+	}
+	return user, feed
+}
+
+func TestSaveFeedSkipsDuplicates(t *testing.T) {
+	s := &state{db: memory.New()}
+	ctx := context.Background()
+	user, feed := newTestFeed(t, s)
+
+	rssFeed := func(links ...string) RSSFeed {
+		feed := RSSFeed{}
+		for _, link := range links {
+			feed.Channel.Item = append(feed.Channel.Item, RSSItem{Title: link, Link: link})
+		}
+		return feed
+	}
+	if err := s.saveFeed(ctx, rssFeed("https://blog.example.com/2"), feed); err != nil {
+		t.Fatalf("saveFeed: %v", err)
+	}
+
+	// Items after one that was already saved are still saved
+	err := s.saveFeed(ctx, rssFeed("https://blog.example.com/3", "https://blog.example.com/2", "https://blog.example.com/1"), feed)
+	if err != nil {
+		t.Fatalf("saveFeed: %v", err)
+	}
+
+	posts, err := s.db.GetPostsForUser(ctx, database.GetPostsForUserParams{UserID: user.ID, Limit: maxPostsLimit})
+	if err != nil {
+		t.Fatalf("GetPostsForUser: %v", err)
+	}
+	var links []string
+	for _, post := range posts {
+		links = append(links, post.Url)
+	}
+	slices.Sort(links)
+	if want := []string{"https://blog.example.com/1", "https://blog.example.com/2", "https://blog.example.com/3"}; !slices.Equal(links, want) {
+		t.Errorf("posts: got %q, want %q", links, want)
+	}
+}
+
+func TestGeneratePostParamsPubDate(t *testing.T) {
+	feeds := newFeedServer(t)
+	rssFeed, err := fetchFeed(context.Background(), feeds.URL+"/dates.xml")
+	if err != nil {
+		t.Fatalf("fetchFeed: %v", err)
+	}
+
+	want := []time.Time{
+		time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC),
+		{},
+		{},
+	}
+	if len(rssFeed.Channel.Item) != len(want) {
+		t.Fatalf("items: got %d, want %d", len(rssFeed.Channel.Item), len(want))
+	}
+	for i, item := range rssFeed.Channel.Item {
+		got := generatePostParams(item, database.Feed{}).PublishedAt
+		if got.Valid != !want[i].IsZero() || !got.Time.Equal(want[i]) {
+			t.Errorf("%v: got %v, want %v", item.Title, got, want[i])
+		}
+	}
+}
+
+func TestFetchFeedErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /error.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("<rss><channel><title>Internal Server Error</title></channel></rss>"))
+	})
+	mux.HandleFunc("GET /huge.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<rss><channel><title>"))
+		w.Write(bytes.Repeat([]byte("a"), maxFeedBytes))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	for _, path := range []string{"/missing.xml", "/error.xml", "/huge.xml"} {
+		if _, err := fetchFeed(context.Background(), server.URL+path); err == nil {
+			t.Errorf("%v: fetched without an error", path)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/imeltsner/gator-api/internal/database"
+)
+
+// scheduler periodically queues feeds that are due for a fetch and scrapes them
+// with a fixed pool of workers
+type scheduler struct {
+	s          *state
+	interval   time.Duration
+	workers    int
+	queue      chan database.Feed
+	wg         sync.WaitGroup
+	running    atomic.Bool
+	workCtx    context.Context
+	cancelWork context.CancelFunc
+}
+
+func newScheduler(s *state, interval time.Duration, workers int) *scheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	return &scheduler{
+		s:        s,
+		interval: interval,
+		workers:  workers,
+		queue:    make(chan database.Feed, workers*2),
+	}
+}
+
+func (sched *scheduler) queueDepth() int {
+	return len(sched.queue)
+}
+
+// isRunning reports whether the dispatcher is still queueing feeds
+func (sched *scheduler) isRunning() bool {
+	return sched.running.Load()
+}
+
+// start launches the dispatcher and workers. Once ctx is cancelled no more feeds
+// are queued, but fetches already in progress continue until shutdown gives up on them
+func (sched *scheduler) start(ctx context.Context) {
+	sched.workCtx, sched.cancelWork = context.WithCancel(context.WithoutCancel(ctx))
+	for range sched.workers {
+		sched.wg.Add(1)
+		go sched.work(ctx)
+	}
+
+	sched.running.Store(true)
+	sched.wg.Add(1)
+	go sched.dispatch(ctx)
+}
+
+// shutdown waits for the dispatcher and workers to return after the context passed
+// to start is cancelled. If ctx expires first, in-flight fetches are cancelled.
+func (sched *scheduler) shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		sched.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		sched.cancelWork()
+		return nil
+	case <-ctx.Done():
+		sched.cancelWork()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (sched *scheduler) dispatch(ctx context.Context) {
+	defer sched.wg.Done()
+	defer close(sched.queue)
+	defer sched.running.Store(false)
+
+	ticker := time.NewTicker(sched.interval)
+	defer ticker.Stop()
+
+	for {
+		sched.enqueueDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (sched *scheduler) enqueueDue(ctx context.Context) {
+	cutoff := sql.NullTime{Time: time.Now().UTC().Add(-sched.interval), Valid: true}
+	feeds, err := sched.s.db.GetNextFeedsToFetch(ctx, database.GetNextFeedsToFetchParams{
+		LastFetchedAt: cutoff,
+		Limit:         int32(cap(sched.queue) - len(sched.queue)),
+	})
+	if err != nil {
+		slog.Error("unable to get feeds to fetch", slog.Any("error", err))
+		return
+	}
+
+	for _, feed := range feeds {
+		err := sched.s.markFeedFetched(ctx, feed)
+		if err != nil {
+			slog.Error("unable to queue feed", slog.String("feed_id", feed.ID.String()), slog.Any("error", err))
+			continue
+		}
+		select {
+		case sched.queue <- feed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (sched *scheduler) work(ctx context.Context) {
+	defer sched.wg.Done()
+
+	for feed := range sched.queue {
+		if ctx.Err() != nil {
+			continue
+		}
+		err := sched.s.scrapeFeed(sched.workCtx, feed)
+		if err != nil {
+			slog.Error("unable to scrape feed", slog.String("feed_id", feed.ID.String()), slog.Any("error", err))
+		}
+	}
+}
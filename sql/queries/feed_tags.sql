@@ -0,0 +1,28 @@
+-- name: CreateFeedTag :exec
+INSERT INTO feed_tags (feed_id, tag)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteFeedTags :exec
+DELETE FROM feed_tags WHERE feed_id = $1;
+
+-- name: SetFeedTags :exec
+WITH deleted AS (
+    DELETE FROM feed_tags
+    WHERE feed_id = sqlc.arg(feed_id)
+        AND tag <> ALL(COALESCE(sqlc.arg(tags)::text[], '{}'))
+)
+INSERT INTO feed_tags (feed_id, tag)
+SELECT sqlc.arg(feed_id)::uuid, unnest(sqlc.arg(tags)::text[])
+ON CONFLICT DO NOTHING;
+
+-- name: GetTagsForFeeds :many
+SELECT * FROM feed_tags
+WHERE feed_id = ANY(sqlc.arg(feed_ids)::uuid[])
+ORDER BY tag;
+
+-- name: ListTags :many
+SELECT tag, COUNT(*) AS feed_count
+FROM feed_tags
+GROUP BY tag
+ORDER BY feed_count DESC, tag;
\ No newline at end of file
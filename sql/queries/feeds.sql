@@ -24,7 +24,55 @@ UPDATE feeds
 SET last_fetched_at = $1, updated_at = $2
 WHERE feeds.id = $3;
 
+-- name: MarkFeedFetchSucceeded :exec
+UPDATE feeds
+SET last_successful_fetch_at = $1
+WHERE feeds.id = $2;
+
 -- name: GetNextFeedToFetch :one
 SELECT * FROM feeds
 ORDER BY last_fetched_at NULLS FIRST
 LIMIT 1;
+
+-- name: GetNextFeedsToFetch :many
+SELECT * FROM feeds
+WHERE last_fetched_at IS NULL OR last_fetched_at < $1
+ORDER BY last_fetched_at NULLS FIRST
+LIMIT $2;
+
+-- name: GetFeedsByIDs :many
+SELECT * FROM feeds
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
+
+-- name: ListFeeds :many
+SELECT feeds.*, users.name AS user_name,
+    COALESCE(follows.follower_count, 0)::bigint AS follower_count,
+    COALESCE(follows.recent_follower_count, 0)::bigint AS recent_follower_count,
+    COALESCE(feed_posts.post_count, 0)::bigint AS recent_post_count
+FROM feeds
+INNER JOIN users ON users.id = feeds.user_id
+LEFT JOIN (
+    SELECT feed_id, COUNT(*) AS follower_count,
+        COUNT(CASE WHEN created_at > sqlc.arg(since) THEN 1 END) AS recent_follower_count
+    FROM feed_follows
+    GROUP BY feed_id
+) AS follows ON follows.feed_id = feeds.id
+LEFT JOIN (
+    SELECT feed_id, COUNT(CASE WHEN created_at > sqlc.arg(since) THEN 1 END) AS post_count,
+        MAX(COALESCE(published_at, created_at)) AS latest_post_at
+    FROM posts
+    GROUP BY feed_id
+) AS feed_posts ON feed_posts.feed_id = feeds.id
+WHERE (sqlc.arg(search)::text = ''
+        OR strpos(lower(feeds.title), lower(sqlc.arg(search))) > 0
+        OR strpos(lower(feeds.url), lower(sqlc.arg(search))) > 0)
+    AND (sqlc.arg(tag)::text = ''
+        OR EXISTS (SELECT 1 FROM feed_tags WHERE feed_tags.feed_id = feeds.id AND feed_tags.tag = sqlc.arg(tag)))
+ORDER BY
+    CASE WHEN sqlc.arg(sort)::text = 'popular' THEN COALESCE(follows.recent_follower_count, 0) END DESC,
+    CASE WHEN sqlc.arg(sort)::text IN ('followers', 'popular') THEN COALESCE(follows.follower_count, 0) END DESC,
+    CASE WHEN sqlc.arg(sort)::text = 'updated' THEN feed_posts.latest_post_at END DESC NULLS LAST,
+    feeds.created_at DESC,
+    feeds.seq DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- name: SetFeverAPIKey :exec
+INSERT INTO fever_api_keys (user_id, created_at, api_key)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE SET created_at = EXCLUDED.created_at, api_key = EXCLUDED.api_key;
+
+-- name: GetUserIDByFeverAPIKey :one
+SELECT user_id FROM fever_api_keys
+WHERE api_key = $1;
+
+-- name: DeleteFeverAPIKey :execrows
+DELETE FROM fever_api_keys
+WHERE user_id = $1;
\ No newline at end of file
@@ -0,0 +1,26 @@
+-- name: SetItemRead :execrows
+INSERT INTO post_states (user_id, post_id, read_at)
+SELECT feed_follows.user_id, posts.id, sqlc.narg(read_at)::timestamp
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id) AND posts.seq = sqlc.arg(seq)
+ON CONFLICT (user_id, post_id) DO UPDATE SET read_at = EXCLUDED.read_at;
+
+-- name: SetItemSaved :execrows
+INSERT INTO post_states (user_id, post_id, saved_at)
+SELECT feed_follows.user_id, posts.id, sqlc.narg(saved_at)::timestamp
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id) AND posts.seq = sqlc.arg(seq)
+ON CONFLICT (user_id, post_id) DO UPDATE SET saved_at = EXCLUDED.saved_at;
+
+-- name: MarkItemsRead :exec
+INSERT INTO post_states (user_id, post_id, read_at)
+SELECT feed_follows.user_id, posts.id, sqlc.arg(read_at)::timestamp
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+INNER JOIN feeds ON feeds.id = posts.feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+    AND (sqlc.arg(feed_seq)::bigint = 0 OR feeds.seq = sqlc.arg(feed_seq))
+    AND posts.created_at < sqlc.arg(created_before)
+ON CONFLICT (user_id, post_id) DO UPDATE SET read_at = COALESCE(post_states.read_at, EXCLUDED.read_at);
\ No newline at end of file
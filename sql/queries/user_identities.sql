@@ -0,0 +1,10 @@
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (id, created_at, user_id, issuer, subject)
+VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE issuer = $1 AND subject = $2;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN seq BIGSERIAL UNIQUE;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN seq;
\ No newline at end of file
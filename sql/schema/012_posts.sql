@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN seq BIGSERIAL UNIQUE;
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN seq;
\ No newline at end of file
@@ -0,0 +1,29 @@
+-- name: CreateFeedFollow :exec
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+VALUES (
+    ?, ?, ?, ?, ?
+);
+
+-- name: GetFeedFollowWithNames :one
+SELECT feed_follows.*, feeds.title AS feed_title, users.name AS user_name
+FROM feed_follows
+INNER JOIN users ON feed_follows.user_id = users.id
+INNER JOIN feeds ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.id = ?;
+
+-- name: GetFeedFollowsForUser :many
+SELECT feed_follows.*, feeds.title AS feed_title, users.name AS user_name
+FROM feed_follows
+INNER JOIN feeds ON feed_follows.feed_id = feeds.id
+INNER JOIN users ON feeds.user_id = users.id
+WHERE feed_follows.user_id = ?;
+
+-- name: DeleteFeedFollow :exec
+DELETE FROM feed_follows
+WHERE user_id = ? AND feed_id = ?;
+
+-- name: GetFollowedFeeds :many
+SELECT feeds.* FROM feeds
+INNER JOIN feed_follows ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = ?
+ORDER BY feeds.seq;
\ No newline at end of file
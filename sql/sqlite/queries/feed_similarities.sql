@@ -0,0 +1,44 @@
+-- name: CreateFeedSimilarities :execrows
+WITH followers AS (
+    SELECT feed_id, COUNT(*) AS follower_count
+    FROM feed_follows
+    GROUP BY feed_id
+), pairs AS (
+    SELECT a.feed_id, b.feed_id AS similar_feed_id, COUNT(*) AS common_followers
+    FROM feed_follows a
+    JOIN feed_follows b ON b.user_id = a.user_id AND b.feed_id <> a.feed_id
+    GROUP BY a.feed_id, b.feed_id
+), ranked AS (
+    SELECT pairs.feed_id, pairs.similar_feed_id, pairs.common_followers,
+        CAST(pairs.common_followers AS REAL) / (f.follower_count + s.follower_count - pairs.common_followers) AS score,
+        ROW_NUMBER() OVER (
+            PARTITION BY pairs.feed_id
+            ORDER BY CAST(pairs.common_followers AS REAL) / (f.follower_count + s.follower_count - pairs.common_followers) DESC,
+                pairs.common_followers DESC, pairs.similar_feed_id
+        ) AS feed_rank
+    FROM pairs
+    JOIN followers f ON f.feed_id = pairs.feed_id
+    JOIN followers s ON s.feed_id = pairs.similar_feed_id
+)
+INSERT INTO feed_similarities (feed_id, similar_feed_id, common_followers, score, computed_at)
+SELECT feed_id, similar_feed_id, common_followers, score, sqlc.arg(computed_at)
+FROM ranked
+WHERE feed_rank <= sqlc.arg(per_feed);
+
+-- name: DeleteFeedSimilaritiesBefore :exec
+DELETE FROM feed_similarities WHERE computed_at < ?;
+
+-- name: ListFeedRecommendations :many
+SELECT feeds.*, feed_similarities.common_followers, feed_similarities.score,
+    followed.id AS because_feed_id, followed.title AS because_feed_title
+FROM feed_follows
+JOIN feed_similarities ON feed_similarities.feed_id = feed_follows.feed_id
+JOIN feeds followed ON followed.id = feed_similarities.feed_id
+JOIN feeds ON feeds.id = feed_similarities.similar_feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+    AND feed_similarities.computed_at = (SELECT MAX(computed_at) FROM feed_similarities)
+    AND NOT EXISTS (
+        SELECT 1 FROM feed_follows mine
+        WHERE mine.user_id = sqlc.arg(user_id) AND mine.feed_id = feeds.id
+    )
+ORDER BY feed_similarities.score DESC, feed_similarities.common_followers DESC, followed.title, followed.id;
\ No newline at end of file
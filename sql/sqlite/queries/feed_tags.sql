@@ -0,0 +1,18 @@
+-- name: CreateFeedTag :exec
+INSERT INTO feed_tags (feed_id, tag)
+VALUES (?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteFeedTags :exec
+DELETE FROM feed_tags WHERE feed_id = ?;
+
+-- name: GetTagsForFeeds :many
+SELECT * FROM feed_tags
+WHERE feed_id IN (sqlc.slice(feed_ids))
+ORDER BY tag;
+
+-- name: ListTags :many
+SELECT tag, COUNT(*) AS feed_count
+FROM feed_tags
+GROUP BY tag
+ORDER BY feed_count DESC, tag;
\ No newline at end of file
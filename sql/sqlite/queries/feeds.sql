@@ -0,0 +1,78 @@
+-- name: CreateFeed :one
+INSERT INTO feeds (id, created_at, updated_at, title, url, user_id, seq)
+VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?
+)
+RETURNING *;
+
+-- name: GetFeeds :many
+SELECT * FROM feeds;
+
+-- name: GetFeedByID :one
+SELECT * FROM feeds WHERE id = ?;
+
+-- name: GetFeedByURL :one
+SELECT * FROM feeds WHERE url = ?;
+
+-- name: MarkFeedFetched :exec
+UPDATE feeds
+SET last_fetched_at = ?, updated_at = ?
+WHERE feeds.id = ?;
+
+-- name: MarkFeedFetchSucceeded :exec
+UPDATE feeds
+SET last_successful_fetch_at = ?
+WHERE feeds.id = ?;
+
+-- name: GetNextFeedToFetch :one
+SELECT * FROM feeds
+ORDER BY last_fetched_at NULLS FIRST
+LIMIT 1;
+
+-- name: GetNextFeedsToFetch :many
+SELECT * FROM feeds
+WHERE last_fetched_at IS NULL OR last_fetched_at < ?
+ORDER BY last_fetched_at NULLS FIRST
+LIMIT ?;
+
+-- name: GetFeedsByIDs :many
+SELECT * FROM feeds
+WHERE id IN (sqlc.slice(ids));
+
+-- name: ListFeeds :many
+SELECT feeds.*, users.name AS user_name,
+    COALESCE(follows.follower_count, 0) AS follower_count,
+    COALESCE(follows.recent_follower_count, 0) AS recent_follower_count,
+    COALESCE(feed_posts.post_count, 0) AS recent_post_count
+FROM feeds
+INNER JOIN users ON users.id = feeds.user_id
+LEFT JOIN (
+    SELECT feed_id, COUNT(*) AS follower_count,
+        COUNT(CASE WHEN created_at > sqlc.arg(since) THEN 1 END) AS recent_follower_count
+    FROM feed_follows
+    GROUP BY feed_id
+) AS follows ON follows.feed_id = feeds.id
+LEFT JOIN (
+    SELECT feed_id, COUNT(CASE WHEN created_at > sqlc.arg(since) THEN 1 END) AS post_count,
+        MAX(COALESCE(published_at, created_at)) AS latest_post_at
+    FROM posts
+    GROUP BY feed_id
+) AS feed_posts ON feed_posts.feed_id = feeds.id
+WHERE (CAST(sqlc.arg(search) AS TEXT) = ''
+        OR instr(lower(feeds.title), lower(sqlc.arg(search))) > 0
+        OR instr(lower(feeds.url), lower(sqlc.arg(search))) > 0)
+    AND (CAST(sqlc.arg(tag) AS TEXT) = ''
+        OR EXISTS (SELECT 1 FROM feed_tags WHERE feed_tags.feed_id = feeds.id AND feed_tags.tag = sqlc.arg(tag)))
+ORDER BY
+    CASE WHEN CAST(sqlc.arg(sort) AS TEXT) = 'popular' THEN COALESCE(follows.recent_follower_count, 0) END DESC,
+    CASE WHEN CAST(sqlc.arg(sort) AS TEXT) IN ('followers', 'popular') THEN COALESCE(follows.follower_count, 0) END DESC,
+    CASE WHEN CAST(sqlc.arg(sort) AS TEXT) = 'updated' THEN feed_posts.latest_post_at END DESC NULLS LAST,
+    feeds.created_at DESC,
+    feeds.seq DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
\ No newline at end of file
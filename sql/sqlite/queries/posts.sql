@@ -0,0 +1,57 @@
+-- name: CreatePost :one
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, seq)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetPostsForUser :many
+SELECT posts.* FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = ?
+ORDER BY posts.published_at DESC NULLS FIRST, posts.id
+LIMIT ? OFFSET ?;
+
+-- name: CountUserItems :one
+SELECT COUNT(*) FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = ?;
+
+-- name: GetUserItem :one
+SELECT posts.*, feeds.seq AS feed_seq, post_states.read_at, post_states.saved_at
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+INNER JOIN feeds ON feeds.id = posts.feed_id
+LEFT JOIN post_states ON post_states.user_id = feed_follows.user_id AND post_states.post_id = posts.id
+WHERE feed_follows.user_id = ? AND posts.seq = ?;
+
+-- name: GetUserItems :many
+SELECT posts.*, feeds.seq AS feed_seq, post_states.read_at, post_states.saved_at
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+INNER JOIN feeds ON feeds.id = posts.feed_id
+LEFT JOIN post_states ON post_states.user_id = feed_follows.user_id AND post_states.post_id = posts.id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+    AND posts.seq > sqlc.arg(after_seq)
+    AND (sqlc.arg(before_seq) = 0 OR posts.seq < sqlc.arg(before_seq))
+    AND (sqlc.arg(feed_seq) = 0 OR feeds.seq = sqlc.arg(feed_seq))
+    AND (NOT sqlc.arg(unread) OR post_states.read_at IS NULL)
+    AND (NOT sqlc.arg(saved) OR post_states.saved_at IS NOT NULL)
+    AND (sqlc.narg(created_after) IS NULL OR posts.created_at > sqlc.narg(created_after))
+    AND (sqlc.narg(created_before) IS NULL OR posts.created_at < sqlc.narg(created_before))
+ORDER BY
+    CASE WHEN sqlc.arg(descending) THEN posts.seq END DESC,
+    posts.seq
+LIMIT sqlc.arg('limit');
+
+-- name: GetUnreadItemSeqs :many
+SELECT posts.seq FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_states ON post_states.user_id = feed_follows.user_id AND post_states.post_id = posts.id
+WHERE feed_follows.user_id = ? AND post_states.read_at IS NULL
+ORDER BY posts.seq;
+
+-- name: GetSavedItemSeqs :many
+SELECT posts.seq FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+INNER JOIN post_states ON post_states.user_id = feed_follows.user_id AND post_states.post_id = posts.id
+WHERE feed_follows.user_id = ? AND post_states.saved_at IS NOT NULL
+ORDER BY posts.seq;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- name: NextSeq :one
+UPDATE seqs
+SET value = value + 1
+WHERE name = ?
+RETURNING value;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (id, created_at, user_id, issuer, subject)
+VALUES (
+    ?, ?, ?, ?, ?
+)
+RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE issuer = ? AND subject = ?;
\ No newline at end of file
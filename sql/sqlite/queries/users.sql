@@ -0,0 +1,46 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, name, hashed_password)
+VALUES (
+    ?,
+    ?,
+    ?,
+    ?,
+    ?
+)
+RETURNING *;
+
+-- name: GetUserByName :one
+SELECT * FROM users
+WHERE name = ?;
+
+-- name: DeleteUsers :exec
+DELETE FROM users;
+
+-- name: GetUsers :many
+SELECT * FROM users;
+
+-- name: GetUserNameByID :one
+SELECT name FROM users
+WHERE id = ?;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = ?;
+
+-- name: DeleteUser :exec
+DELETE from users
+WHERE id = ?;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = ?, updated_at = ?, password_version = password_version + 1
+WHERE id = ?;
+
+-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = ?, updated_at = ?
+WHERE id = ?;
+
+-- name: GetUsersByIDs :many
+SELECT * FROM users
+WHERE id IN (sqlc.slice(ids));
\ No newline at end of file
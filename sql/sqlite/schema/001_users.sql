@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE users (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    name VARCHAR(50) UNIQUE NOT NULL CHECK (length(name) <= 50)
+);
+
+
+-- +goose Down
+DROP TABLE users;
\ No newline at end of file
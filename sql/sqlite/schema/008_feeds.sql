@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE UNIQUE INDEX unique_url ON feeds (url);
+
+-- +goose Down
+DROP INDEX unique_url;
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE user_identities (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    CONSTRAINT user_identity_subject UNIQUE (issuer, subject)
+);
+
+-- +goose Down
+DROP TABLE user_identities;
\ No newline at end of file
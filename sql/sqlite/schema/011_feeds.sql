@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN seq INTEGER NOT NULL DEFAULT 0;
+
+UPDATE feeds SET seq = rowid;
+
+CREATE UNIQUE INDEX feeds_seq ON feeds (seq);
+
+-- SQLite has no sequences, so the last seq handed out for each table is kept
+-- here and never goes back down when rows are deleted
+CREATE TABLE seqs (
+    name TEXT PRIMARY KEY,
+    value INTEGER NOT NULL
+);
+
+INSERT INTO seqs (name, value)
+SELECT 'feeds', COALESCE(MAX(seq), 0) FROM feeds;
+
+-- +goose Down
+DROP TABLE seqs;
+
+DROP INDEX feeds_seq;
+
+ALTER TABLE feeds
+DROP COLUMN seq;
\ No newline at end of file
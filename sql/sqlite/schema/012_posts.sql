@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN seq INTEGER NOT NULL DEFAULT 0;
+
+UPDATE posts SET seq = rowid;
+
+CREATE UNIQUE INDEX posts_seq ON posts (seq);
+
+INSERT INTO seqs (name, value)
+SELECT 'posts', COALESCE(MAX(seq), 0) FROM posts;
+
+-- +goose Down
+DELETE FROM seqs WHERE name = 'posts';
+
+DROP INDEX posts_seq;
+
+ALTER TABLE posts
+DROP COLUMN seq;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE fever_api_keys (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    api_key TEXT NOT NULL UNIQUE
+);
+
+-- +goose Down
+DROP TABLE fever_api_keys;
\ No newline at end of file
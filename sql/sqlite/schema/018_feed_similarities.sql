@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE feed_similarities (
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    similar_feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    common_followers BIGINT NOT NULL,
+    score REAL NOT NULL,
+    computed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (computed_at, feed_id, similar_feed_id)
+);
+
+-- +goose Down
+DROP TABLE feed_similarities;
\ No newline at end of file
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/imeltsner/gator-api/internal/database"
+	"github.com/imeltsner/gator-api/internal/database/sqlite"
+	"github.com/pressly/goose/v3"
+)
+
+// storage is an open database together with the queries and migrations for its backend
+type storage struct {
+	db         *sql.DB
+	queries    database.Querier
+	dialect    goose.Dialect
+	schemaPath string
+}
+
+// openStorage picks the backend from the DSN scheme: sqlite:// (or sqlite:) for
+// SQLite, anything else for Postgres
+func openStorage(dsn string) (storage, error) {
+	if path, ok := sqlitePath(dsn); ok {
+		db, err := sqlite.Open(path)
+		if err != nil {
+			return storage{}, fmt.Errorf("unable to open sqlite db: %v", err)
+		}
+		return storage{
+			db:         db,
+			queries:    sqlite.NewStore(db),
+			dialect:    goose.DialectSQLite3,
+			schemaPath: "sql/sqlite/schema",
+		}, nil
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return storage{}, fmt.Errorf("unable to connect to db: %v", err)
+	}
+	return storage{
+		db:         db,
+		queries:    database.New(db),
+		dialect:    goose.DialectPostgres,
+		schemaPath: "sql/schema",
+	}, nil
+}
+
+func sqlitePath(dsn string) (string, bool) {
+	for _, prefix := range []string{"sqlite://", "sqlite:"} {
+		if path, ok := strings.CutPrefix(dsn, prefix); ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func (st storage) newMigrationProvider() (*goose.Provider, error) {
+	schema, err := fs.Sub(embedMigrations, st.schemaPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open embedded migrations: %v", err)
+	}
+
+	provider, err := goose.NewProvider(st.dialect, st.db, schema)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create migration provider: %v", err)
+	}
+	return provider, nil
+}
@@ -1,18 +1,22 @@
 package main
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/imeltsner/gator-api/internal/auth"
 	"github.com/imeltsner/gator-api/internal/database"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// unsetPassword is the placeholder hash of accounts created before passwords were
+// added. They can't log in until an operator issues a password reset.
+const unsetPassword = "unset"
+
 type User struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
@@ -20,6 +24,77 @@ type User struct {
 	Name      string    `json:"name"`
 }
 
+// createUser validates the name and password of a new user and adds them. It is
+// shared by every API that can sign users up.
+func (s *state) createUser(ctx context.Context, name, password string) (database.User, error) {
+	v := validator{}
+	v.userName("name", name)
+	v.password("password", password, name)
+	if err := v.err(); err != nil {
+		return database.User{}, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
+	if err != nil {
+		return database.User{}, &requestError{status: http.StatusInternalServerError, message: "unable to hash password", err: err}
+	}
+
+	user, err := s.db.CreateUser(ctx, database.CreateUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      time.Now().UTC(),
+		UpdatedAt:      time.Now().UTC(),
+		Name:           name,
+		HashedPassword: string(hashedPassword),
+	})
+	if database.IsUniqueViolation(err) {
+		return database.User{}, &requestError{status: http.StatusConflict, message: "name already exists in db", err: err}
+	} else if err != nil {
+		return database.User{}, &requestError{status: http.StatusInternalServerError, message: "unable to create user", err: err}
+	}
+
+	slog.InfoContext(ctx, "user created", slog.String("user_id", user.ID.String()), slog.String("name", user.Name))
+	return user, nil
+}
+
+// checkLogin returns the user called name if password is theirs. It is shared by
+// every API that logs users in: failures count towards the name's lockout, and
+// hashes with an outdated cost are upgraded on success.
+func (s *state) checkLogin(ctx context.Context, name, password string) (database.User, error) {
+	if wait := s.logins.locked(name); wait > 0 {
+		return database.User{}, &requestError{status: http.StatusTooManyRequests, message: "too many failed logins, try again later", retryAfter: wait}
+	}
+
+	user, err := s.db.GetUserByName(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logins.fail(name)
+		return database.User{}, &requestError{status: http.StatusNotFound, message: "user not found", err: err}
+	} else if err != nil {
+		return database.User{}, &requestError{status: http.StatusInternalServerError, message: "unable to get user", err: err}
+	}
+
+	if user.HashedPassword == unsetPassword {
+		return database.User{}, errPasswordResetRequired
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(password))
+	if err != nil {
+		s.logins.fail(name)
+		return database.User{}, &requestError{status: http.StatusUnauthorized, message: "incorrect password", err: err}
+	}
+	s.logins.succeed(name)
+	s.rehashPassword(ctx, user, password)
+	return user, nil
+}
+
+// loginExpiry is how long an access token lasts when expiresIn seconds are asked
+// for: an hour, unless less is asked for
+func loginExpiry(expiresIn int) time.Duration {
+	if expiresIn <= 0 || expiresIn > 3600 {
+		return time.Hour
+	}
+	return time.Duration(expiresIn) * time.Second
+}
+
 func (s *state) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Name      string `json:"name"`
@@ -27,34 +102,31 @@ func (s *state) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		ExpiresIn int    `json:"expires_in_seconds"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err := decodeJSON(w, r, &params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "couldn't decode parameters", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
-	dbUser, err := s.db.GetUserByName(r.Context(), params.Name)
-	if err != nil {
-		respondWithError(w, http.StatusNotFound, "user not found", err)
+	v := validator{}
+	v.required("name", params.Name)
+	v.required("password", params.Password)
+	if params.ExpiresIn < 0 {
+		v.add("expires_in_seconds", "must not be negative")
+	}
+	if err := v.err(); err != nil {
+		respondWithRequestError(w, err)
 		return
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(dbUser.HashedPassword), []byte(params.Password))
+	dbUser, err := s.checkLogin(r.Context(), params.Name, params.Password)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "incorrect password", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
-	var expirationTime time.Duration
-	if params.ExpiresIn > 0 && params.ExpiresIn > 3600 {
-		expirationTime = time.Hour
-	} else {
-		expirationTime = time.Duration(params.ExpiresIn) * time.Second
-	}
-
-	token, err := auth.MakeJWT(dbUser.ID, s.jwtSecret, expirationTime)
+	token, err := s.keys.MakeJWT(dbUser.ID, loginExpiry(params.ExpiresIn))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "unable to make jwt", err)
 		return
@@ -82,42 +154,19 @@ func (s *state) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err := decodeJSON(w, r, &params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to decode params", err)
-		return
-	}
-	if params.Password == "" {
-		respondWithError(w, http.StatusBadRequest, "password is required", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcrypt.MinCost)
+	dbUser, err := s.createUser(r.Context(), params.Name, params.Password)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to hash password", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
-	user := database.CreateUserParams{
-		ID:             uuid.New(),
-		CreatedAt:      time.Now().UTC(),
-		UpdatedAt:      time.Now().UTC(),
-		Name:           params.Name,
-		HashedPassword: string(hashedPassword),
-	}
-
-	dbUser, err := s.db.CreateUser(r.Context(), user)
-	if err != nil && strings.Contains(err.Error(), "duplicate key value") {
-		respondWithError(w, http.StatusConflict, "name already exists in db", err)
-		return
-	} else if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to create user", err)
-		return
-	}
-
-	log.Printf("DB user with name %v and ID %v created at %v\n", dbUser.Name, dbUser.ID, dbUser.CreatedAt)
 	respondWithJSON(w, http.StatusCreated, User{
 		ID:        dbUser.ID,
 		CreatedAt: dbUser.CreatedAt,
@@ -127,34 +176,30 @@ func (s *state) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *state) handlerGetUser(w http.ResponseWriter, r *http.Request) {
-	idString := r.PathValue("id")
-	id, err := uuid.Parse(idString)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to parse id", err)
-		return
-	}
-
-	authToken, err := auth.GetBearerToken(r.Header)
+	id, err := pathID(r, "id")
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "unable to parse auth header", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
-	authID, err := auth.ValidateJWT(authToken, s.jwtSecret)
+	authID, err := s.authenticate(r, "")
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "unable to validate jwt", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
 	if authID != id {
-		respondWithError(w, http.StatusUnauthorized, "mismatched id", err)
+		respondWithError(w, http.StatusForbidden, "mismatched id", nil)
 		return
 	}
 
 	user, err := s.db.GetUserByID(r.Context(), id)
-	if err != nil {
+	if errors.Is(err, sql.ErrNoRows) {
 		respondWithError(w, http.StatusNotFound, "user not found", err)
 		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get user", err)
+		return
 	}
 
 	respondWithJSON(w, http.StatusOK, User{
@@ -192,33 +237,26 @@ func (s *state) handlerGetUsers(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *state) handlerDeleteUser(w http.ResponseWriter, r *http.Request) {
-	idString := r.PathValue("id")
-	id, err := uuid.Parse(idString)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to parse id", err)
-		return
-	}
-
-	authToken, err := auth.GetBearerToken(r.Header)
+	id, err := pathID(r, "id")
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to parse auth header", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
-	authID, err := auth.ValidateJWT(authToken, s.jwtSecret)
+	authID, err := s.authenticate(r, "")
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "unable to validate jwt", err)
+		respondWithRequestError(w, err)
 		return
 	}
 
 	if authID != id {
-		respondWithError(w, http.StatusUnauthorized, "mismatched id", err)
+		respondWithError(w, http.StatusForbidden, "mismatched id", nil)
 		return
 	}
 
 	err = s.db.DeleteUser(r.Context(), id)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "unable to delete user", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to delete user", err)
 		return
 	}
 
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/imeltsner/gator-api/internal/auth"
+	"github.com/imeltsner/gator-api/internal/config"
+	"github.com/imeltsner/gator-api/internal/database/memory"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testJWTSecret = "test-secret-that-is-at-least-32-chars"
+
+func newTestKeySet() *auth.KeySet {
+	cfg := config.Default()
+	cfg.JWTSecret = testJWTSecret
+	keys, err := newKeySet(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return keys
+}
+
+func newTestState() *state {
+	return &state{
+		db:         memory.New(),
+		keys:       newTestKeySet(),
+		bcryptCost: bcrypt.MinCost,
+	}
+}
+
+func TestHandlerCreateUser(t *testing.T) {
+	s := newTestState()
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"created", `{"name":"alice","password":"hunter22"}`, http.StatusCreated},
+		{"duplicate name", `{"name":"alice","password":"hunter22"}`, http.StatusConflict},
+		{"missing password", `{"name":"bob"}`, http.StatusBadRequest},
+		{"empty name", `{"name":"","password":"hunter22"}`, http.StatusBadRequest},
+		{"name too long", `{"name":"` + strings.Repeat("a", 51) + `","password":"hunter22"}`, http.StatusBadRequest},
+		{"malformed body", `{"name":"bob",`, http.StatusBadRequest},
+		{"unknown field", `{"name":"bob","password":"hunter22","admin":true}`, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			s.handlerCreateUser(w, req)
+			if w.Code != tc.want {
+				t.Errorf("status: got %d, want %d (body %s)", w.Code, tc.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestHandlerLoginAndGetUser(t *testing.T) {
+	s := newTestState()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(`{"name":"alice","password":"hunter22"}`))
+	w := httptest.NewRecorder()
+	s.handlerCreateUser(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create user: got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"name":"alice","password":"hunter22","expires_in_seconds":60}`))
+	w = httptest.NewRecorder()
+	s.handlerLogin(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: got %d (body %s)", w.Code, w.Body)
+	}
+	var login struct {
+		User
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&login); err != nil {
+		t.Fatalf("decode login response: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/users/"+login.ID.String(), nil)
+	req.SetPathValue("id", login.ID.String())
+	req.Header.Set("Authorization", "Bearer "+login.Token)
+	w = httptest.NewRecorder()
+	s.handlerGetUser(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get user: got %d (body %s)", w.Code, w.Body)
+	}
+	var user User
+	if err := json.NewDecoder(w.Body).Decode(&user); err != nil {
+		t.Fatalf("decode user: %v", err)
+	}
+	if user.Name != "alice" {
+		t.Errorf("name: got %q, want alice", user.Name)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"name":"alice","password":"wrong"}`))
+	w = httptest.NewRecorder()
+	s.handlerLogin(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("login with wrong password: got %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}